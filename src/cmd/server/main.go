@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"syscall"
@@ -10,6 +12,7 @@ import (
 	"github.com/kevingruber/gradle-cache/internal/telemetry"
 
 	"github.com/kevingruber/gradle-cache/internal/config"
+	"github.com/kevingruber/gradle-cache/internal/middleware"
 	"github.com/kevingruber/gradle-cache/internal/server"
 	"github.com/kevingruber/gradle-cache/internal/storage"
 	"github.com/rs/zerolog"
@@ -18,8 +21,30 @@ import (
 func main() {
 	// Parse command line flags
 	configPath := flag.String("config", "", "Path to configuration file")
+	genToken := flag.Bool("gen-token", false, "Generate a bearer token and its hash for auth.tokens, then exit")
+	genKey := flag.Bool("gen-encryption-key", false, "Generate a master key for cache.encryption.keys, then exit")
 	flag.Parse()
 
+	if *genKey {
+		key, err := storage.GenerateEncryptionKey()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(key)
+		return
+	}
+
+	if *genToken {
+		plain, hash, err := middleware.GenerateToken()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("token: %s\nhash:  %s\n", plain, hash)
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.Load(*configPath)
 	if err != nil {
@@ -40,11 +65,7 @@ func main() {
 	}
 	defer cleanup()
 
-	store, err := storage.NewRedisStorage(storage.RedisConfig{
-		Addr:     cfg.Storage.Addr,
-		Password: cfg.Storage.Password,
-	})
-
+	store, closers, err := newStorage(cfg, logger)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("failed to create storage")
 	}
@@ -70,10 +91,171 @@ func main() {
 	if err := srv.Run(ctx); err != nil {
 		logger.Fatal().Err(err).Msg("server error")
 	}
+	if err := srv.Close(); err != nil {
+		logger.Warn().Err(err).Msg("failed to close server")
+	}
+	closeAll(closers, logger)
 
 	logger.Info().Msg("server stopped")
 }
 
+// newStorage creates the configured backend and layers the optional storage
+// features on top of it. It also returns the layers that run background
+// work, from the bottom up, to be closed on shutdown.
+func newStorage(cfg *config.Config, logger zerolog.Logger) (storage.Storage, []io.Closer, error) {
+	expiry := storage.ExpiryPolicy{
+		TTL:     cfg.Cache.TTL,
+		Sliding: cfg.Cache.SlidingExpiry,
+	}
+	backend, err := newBackend(cfg.Storage, expiry, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	store := backend
+	var closers []io.Closer
+	if c, ok := backend.(io.Closer); ok {
+		closers = append(closers, c)
+	}
+
+	if enc := cfg.Cache.Encryption; enc.Enabled {
+		keys := make([]storage.EncryptionKey, 0, len(enc.Keys))
+		for _, k := range enc.Keys {
+			secret, err := k.Secret()
+			if err != nil {
+				return nil, nil, err
+			}
+			keys = append(keys, storage.EncryptionKey{ID: k.ID, Secret: secret})
+		}
+		store, err = storage.NewEncryptionStorage(store, storage.EncryptionConfig{
+			ActiveKey: enc.ActiveKey,
+			Keys:      keys,
+		}, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Compression must come before encryption, since encrypted data does
+	// not compress.
+	if comp := cfg.Cache.Compression; comp.Enabled {
+		store, err = storage.NewCompressionStorage(store, storage.CompressionConfig{
+			Algorithm: comp.Algorithm,
+			Level:     comp.Level,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if cfg.Cache.Dedup.Enabled {
+		dedup, err := storage.NewDedupStorage(store, storage.DedupConfig{
+			GCDelay: cfg.Cache.Dedup.GCDelay,
+			Expiry:  expiry,
+		}, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		closers = append(closers, dedup)
+		store = dedup
+	}
+
+	// The checksum layer stays in place when verification is off, so that
+	// checksums written while it was on are still stripped on read.
+	store, err = storage.NewChecksumStorage(store, storage.ChecksumConfig{
+		Verify: cfg.Cache.VerifyChecksums,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if tier := cfg.Cache.MemoryTier; tier.Enabled {
+		store, err = storage.NewTieredStorage(store, storage.TieredConfig{
+			MaxBytes:     tier.MaxSizeMB * 1024 * 1024,
+			MaxEntries:   tier.MaxEntries,
+			MaxEntrySize: tier.MaxEntrySizeKB * 1024,
+			TTL:          expiry.TTL,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// The capacity manager wraps everything else so that it sees every
+	// access, including those answered by the memory tier.
+	if cfg.Cache.MaxTotalSizeMB > 0 || cfg.Namespaces.HasQuotas() {
+		quotas := make(map[string]storage.Quota, len(cfg.Namespaces.QuotaOverrides))
+		for _, o := range cfg.Namespaces.QuotaOverrides {
+			quotas[o.Namespace] = newQuota(o.QuotaConfig)
+		}
+		scanner, _ := backend.(storage.Scanner)
+		capacity, err := storage.NewCapacityManager(store, storage.CapacityConfig{
+			MaxBytes:     cfg.Cache.MaxTotalSizeMB * 1024 * 1024,
+			TTL:          expiry.TTL,
+			DefaultQuota: newQuota(cfg.Namespaces.Quota),
+			Quotas:       quotas,
+			Scanner:      scanner,
+			ScanInterval: cfg.Cache.CapacityScanInterval,
+		}, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		closers = append(closers, capacity)
+		store = capacity
+	}
+
+	return store, closers, nil
+}
+
+// closeAll closes closers in reverse order, so that every storage layer
+// stops before the layers beneath it.
+func closeAll(closers []io.Closer, logger zerolog.Logger) {
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].Close(); err != nil {
+			logger.Warn().Err(err).Msg("failed to close storage")
+		}
+	}
+}
+
+func newQuota(cfg config.QuotaConfig) storage.Quota {
+	return storage.Quota{
+		MaxBytes:    cfg.MaxSizeMB * 1024 * 1024,
+		MaxEntries:  cfg.MaxEntries,
+		EvictOldest: cfg.EvictOldest,
+	}
+}
+
+// newBackend creates the storage backend selected by storage.type.
+func newBackend(cfg config.StorageConfig, expiry storage.ExpiryPolicy, logger zerolog.Logger) (storage.Storage, error) {
+	switch cfg.Type {
+	case "filesystem":
+		return storage.NewFilesystemStorage(storage.FilesystemConfig{
+			Dir:    cfg.Filesystem.Dir,
+			Expiry: expiry,
+		})
+	case "s3":
+		return storage.NewS3Storage(storage.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Bucket:    cfg.S3.Bucket,
+			Region:    cfg.S3.Region,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+			UseSSL:    cfg.S3.UseSSL,
+			PathStyle: cfg.S3.PathStyle,
+			Prefix:    cfg.S3.Prefix,
+			Expiry:    expiry,
+		}, logger)
+	case "redis":
+		return storage.NewRedisStorage(storage.RedisConfig{
+			Addr:      cfg.Addr,
+			Password:  cfg.Password,
+			ChunkSize: cfg.ChunkSizeKB * 1024,
+			Expiry:    expiry,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage type %q", cfg.Type)
+	}
+}
+
 func setupLogger(cfg config.LoggingConfig) zerolog.Logger {
 	// Set log level
 	level, err := zerolog.ParseLevel(cfg.Level)
@@ -0,0 +1,157 @@
+// Package audit records write and admin operations to a dedicated log
+// stream, separate from the request log.
+package audit
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kevingruber/gradle-cache/internal/config"
+	"github.com/kevingruber/gradle-cache/internal/middleware"
+	"github.com/rs/zerolog"
+)
+
+// Results recorded for an operation.
+const (
+	ResultSuccess  = "success"
+	ResultDenied   = "denied"
+	ResultRejected = "rejected"
+	ResultError    = "error"
+)
+
+// Logger writes one audit record per audited request.
+type Logger struct {
+	logger zerolog.Logger
+}
+
+// New creates an audit logger writing JSON records to cfg.Output.
+func New(cfg config.AuditConfig) (*Logger, error) {
+	var out io.Writer
+	switch cfg.Output {
+	case "", "stdout":
+		out = os.Stdout
+	case "stderr":
+		out = os.Stderr
+	default:
+		f, err := os.OpenFile(cfg.Output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		out = f
+	}
+	return &Logger{
+		logger: zerolog.New(out).With().Timestamp().Str("log", "audit").Logger(),
+	}, nil
+}
+
+// Middleware creates a Gin middleware that records the request as action.
+// It must run before authentication so that rejected attempts are recorded
+// as well.
+func (l *Logger) Middleware(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body *countingBody
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			body = &countingBody{ReadCloser: c.Request.Body}
+			c.Request.Body = body
+		}
+
+		c.Next()
+
+		r := Record{
+			Action:    action,
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			ClientIP:  c.ClientIP(),
+			Status:    c.Writer.Status(),
+			Identity:  middleware.GetIdentity(c),
+			Namespace: c.GetString(middleware.NamespaceKey),
+			Key:       middleware.CacheKey(c),
+			Size:      -1,
+		}
+		if body != nil {
+			r.Size = body.n.Load()
+		}
+		if len(c.Errors) > 0 {
+			r.Error = c.Errors.String()
+		}
+		l.Record(r)
+	}
+}
+
+// Record describes one audited operation.
+type Record struct {
+	Action   string
+	Method   string
+	Path     string
+	ClientIP string
+	// Status is the HTTP status of the operation, or its HTTP equivalent
+	// for other protocols.
+	Status    int
+	Identity  *middleware.Identity
+	Namespace string
+	Key       string
+	// Size is the number of bytes uploaded, or negative if there was no
+	// request body.
+	Size  int64
+	Error string
+}
+
+// Record writes r to the audit log.
+func (l *Logger) Record(r Record) {
+	// Records are logged without a level so that the global log level
+	// never suppresses them.
+	event := l.logger.Log().
+		Str("action", r.Action).
+		Str("method", r.Method).
+		Str("path", r.Path).
+		Str("client_ip", r.ClientIP).
+		Int("status", r.Status).
+		Str("result", result(r.Status))
+
+	if r.Identity != nil {
+		event.Str("user", r.Identity.Username).
+			Str("role", string(r.Identity.Role()))
+	}
+	if r.Namespace != "" {
+		event.Str("namespace", r.Namespace)
+	}
+	if r.Key != "" {
+		event.Str("key", r.Key)
+	}
+	if r.Size >= 0 {
+		event.Int64("size", r.Size)
+	}
+	if r.Error != "" {
+		event.Str("error", r.Error)
+	}
+	event.Send()
+}
+
+func result(status int) string {
+	switch {
+	case status >= 500:
+		return ResultError
+	case status == http.StatusUnauthorized || status == http.StatusForbidden ||
+		status == http.StatusTooManyRequests:
+		return ResultDenied
+	case status >= 400:
+		return ResultRejected
+	}
+	return ResultSuccess
+}
+
+// countingBody counts the request body bytes the handler consumed.
+type countingBody struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,240 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kevingruber/gradle-cache/internal/config"
+	"github.com/kevingruber/gradle-cache/internal/middleware"
+	"github.com/rs/zerolog"
+)
+
+// newTestLogger returns a logger writing to a file and a function reading
+// the records written so far.
+func newTestLogger(t *testing.T) (*Logger, func() []map[string]any) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := New(config.AuditConfig{Enabled: true, Output: path})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return l, func() []map[string]any {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var records []map[string]any
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			var r map[string]any
+			if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+				t.Fatalf("invalid record %q: %v", scanner.Text(), err)
+			}
+			records = append(records, r)
+		}
+		return records
+	}
+}
+
+// checkFields fails the test unless record holds want, where a nil value
+// requires the field to be absent.
+func checkFields(t *testing.T, record map[string]any, want map[string]any) {
+	t.Helper()
+	for field, value := range want {
+		got, ok := record[field]
+		switch {
+		case value == nil && ok:
+			t.Errorf("%s = %v, want it absent", field, got)
+		case value != nil && got != value:
+			t.Errorf("%s = %v (%T), want %v", field, got, got, value)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	authn, err := middleware.NewAuthenticator(config.AuthConfig{
+		Users: []config.UserAuth{
+			{Username: "reader", Password: "read", Roles: []string{config.RoleRead}},
+			{Username: "writer", Password: "write", Roles: []string{config.RoleWrite}},
+		},
+	}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewAuthenticator: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		body     string
+		// handle answers requests that pass authentication.
+		handle func(c *gin.Context)
+		want   map[string]any
+	}{
+		{
+			name:     "authenticated upload",
+			username: "writer", password: "write",
+			body: "artifact",
+			handle: func(c *gin.Context) {
+				io.Copy(io.Discard, c.Request.Body)
+				c.Status(http.StatusCreated)
+			},
+			want: map[string]any{
+				"action": "cache.put", "method": "PUT", "path": "/ns/team/cache/abc",
+				"status": 201.0, "result": ResultSuccess,
+				"user": "writer", "role": "write", "namespace": "team", "key": "abc",
+				"size": 8.0, "error": nil, "log": "audit",
+			},
+		},
+		{
+			name: "missing credentials",
+			body: "artifact",
+			want: map[string]any{
+				"status": 401.0, "result": ResultDenied,
+				"user": nil, "role": nil, "namespace": "team", "key": "abc", "size": 0.0,
+			},
+		},
+		{
+			name:     "wrong password",
+			username: "writer", password: "guess",
+			body: "artifact",
+			want: map[string]any{"status": 401.0, "result": ResultDenied, "user": nil},
+		},
+		{
+			name:     "insufficient role",
+			username: "reader", password: "read",
+			body: "artifact",
+			want: map[string]any{"status": 403.0, "result": ResultDenied, "user": "reader", "role": "read"},
+		},
+		{
+			name:     "rejected upload",
+			username: "writer", password: "write",
+			body: strings.Repeat("x", 100),
+			handle: func(c *gin.Context) {
+				io.CopyN(io.Discard, c.Request.Body, 10)
+				c.Status(http.StatusRequestEntityTooLarge)
+			},
+			want: map[string]any{"status": 413.0, "result": ResultRejected, "user": "writer", "size": 10.0},
+		},
+		{
+			name:     "backend failure",
+			username: "writer", password: "write",
+			body: "artifact",
+			handle: func(c *gin.Context) {
+				io.Copy(io.Discard, c.Request.Body)
+				c.Error(errors.New("backend unavailable"))
+				c.Status(http.StatusInternalServerError)
+			},
+			want: map[string]any{"status": 500.0, "result": ResultError, "error": "Error #01: backend unavailable\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, records := newTestLogger(t)
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			handle := tt.handle
+			if handle == nil {
+				handle = func(c *gin.Context) {
+					t.Error("request passed authentication")
+				}
+			}
+			r.PUT("/ns/:namespace/cache/:key", middleware.Namespace(), l.Middleware("cache.put"),
+				middleware.CacheAuth(authn, middleware.RoleWrite), handle)
+
+			req := httptest.NewRequest(http.MethodPut, "/ns/team/cache/abc", strings.NewReader(tt.body))
+			if tt.username != "" {
+				req.SetBasicAuth(tt.username, tt.password)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			got := records()
+			if len(got) != 1 {
+				t.Fatalf("wrote %d records, want 1", len(got))
+			}
+			checkFields(t, got[0], tt.want)
+		})
+	}
+}
+
+func TestMiddlewareWithoutBody(t *testing.T) {
+	l, records := newTestLogger(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/admin/quotas", l.Middleware("admin"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/admin/quotas", nil))
+
+	got := records()
+	if len(got) != 1 {
+		t.Fatalf("wrote %d records, want 1", len(got))
+	}
+	checkFields(t, got[0], map[string]any{"action": "admin", "result": ResultSuccess, "size": nil, "key": nil})
+}
+
+func TestRecord(t *testing.T) {
+	writer := &middleware.Identity{Username: "ci", Roles: []middleware.Role{middleware.RoleRead, middleware.RoleWrite}}
+
+	tests := []struct {
+		name   string
+		record Record
+		want   map[string]any
+	}{
+		{
+			name: "grpc upload",
+			record: Record{
+				Action: "bazel.cas.write", Method: "ByteStream.Write", Path: "/uploads/x/blobs/abc/3",
+				ClientIP: "192.0.2.1", Status: http.StatusOK, Identity: writer,
+				Namespace: "team", Key: "abc", Size: 3,
+			},
+			want: map[string]any{
+				"action": "bazel.cas.write", "method": "ByteStream.Write", "client_ip": "192.0.2.1",
+				"status": 200.0, "result": ResultSuccess, "user": "ci", "role": "write",
+				"namespace": "team", "key": "abc", "size": 3.0,
+			},
+		},
+		{
+			name:   "anonymous without body",
+			record: Record{Action: "admin", Status: http.StatusNoContent, Size: -1},
+			want:   map[string]any{"result": ResultSuccess, "user": nil, "role": nil, "namespace": nil, "key": nil, "size": nil},
+		},
+		{name: "unauthorized", record: Record{Status: http.StatusUnauthorized, Size: -1}, want: map[string]any{"result": ResultDenied}},
+		{name: "forbidden", record: Record{Status: http.StatusForbidden, Size: -1}, want: map[string]any{"result": ResultDenied}},
+		{name: "rate limited", record: Record{Status: http.StatusTooManyRequests, Size: -1}, want: map[string]any{"result": ResultDenied}},
+		{name: "bad request", record: Record{Status: http.StatusBadRequest, Size: -1}, want: map[string]any{"result": ResultRejected}},
+		{name: "quota exceeded", record: Record{Status: http.StatusInsufficientStorage, Size: -1}, want: map[string]any{"result": ResultError}},
+		{name: "conflict", record: Record{Status: http.StatusConflict, Size: -1}, want: map[string]any{"result": ResultRejected}},
+		{
+			name:   "failure",
+			record: Record{Status: http.StatusInternalServerError, Size: -1, Error: "disk full"},
+			want:   map[string]any{"result": ResultError, "error": "disk full"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, records := newTestLogger(t)
+			l.Record(tt.record)
+
+			got := records()
+			if len(got) != 1 {
+				t.Fatalf("wrote %d records, want 1", len(got))
+			}
+			if _, ok := got[0]["time"]; !ok {
+				t.Error("record has no timestamp")
+			}
+			checkFields(t, got[0], tt.want)
+		})
+	}
+}
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
+	"os"
+	"path"
+	"regexp"
 	"strings"
 	"time"
 
@@ -9,13 +13,17 @@ import (
 )
 
 type Config struct {
-	Server  ServerConfig  `mapstructure:"server"`
-	Storage StorageConfig `mapstructure:"storage"`
-	Cache   CacheConfig   `mapstructure:"cache"`
-	Auth    AuthConfig    `mapstructure:"auth"`
-	Metrics MetricsConfig `mapstructure:"metrics"`
-	Logging LoggingConfig `mapstructure:"logging"`
-	Sentry  SentryConfig  `mapstructure:"sentry"`
+	Server     ServerConfig     `mapstructure:"server"`
+	Storage    StorageConfig    `mapstructure:"storage"`
+	Cache      CacheConfig      `mapstructure:"cache"`
+	Auth       AuthConfig       `mapstructure:"auth"`
+	Namespaces NamespacesConfig `mapstructure:"namespaces"`
+	RateLimit  RateLimitConfig  `mapstructure:"rate_limit"`
+	Protocols  ProtocolsConfig  `mapstructure:"protocols"`
+	Metrics    MetricsConfig    `mapstructure:"metrics"`
+	Logging    LoggingConfig    `mapstructure:"logging"`
+	Audit      AuditConfig      `mapstructure:"audit"`
+	Sentry     SentryConfig     `mapstructure:"sentry"`
 }
 
 type ServerConfig struct {
@@ -23,33 +31,377 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
 	WriteTimeout time.Duration `mapstructure:"write_timeout"`
 	TLS          TLSConfig     `mapstructure:"tls"`
+	// TrustedProxies lists the proxy addresses or CIDRs whose
+	// X-Forwarded-For header is used for the client IP. If unset, the
+	// header is ignored and the peer address is the client IP.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
 type TLSConfig struct {
 	Enabled  bool   `mapstructure:"enabled"`
 	CertFile string `mapstructure:"cert_file"`
 	KeyFile  string `mapstructure:"key_file"`
+	// ClientCAFile holds the CA certificates client certificates are verified against.
+	ClientCAFile string `mapstructure:"client_ca_file"`
+	// ClientAuth is "none", "optional" or "required".
+	ClientAuth string `mapstructure:"client_auth"`
 }
 
+// Client certificate modes.
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequired = "required"
+)
+
 type StorageConfig struct {
+	// Type selects the storage backend: "redis", "filesystem" or "s3".
+	Type     string `mapstructure:"type"`
 	Addr     string `mapstructure:"addr"`
 	Password string `mapstructure:"password"`
 	DB       int    `mapstructure:"db"`
+	// ChunkSizeKB is the largest value stored in a single Redis key.
+	// Bigger entries are split into chunks and streamed.
+	ChunkSizeKB int64                   `mapstructure:"chunk_size_kb"`
+	Filesystem  FilesystemStorageConfig `mapstructure:"filesystem"`
+	S3          S3StorageConfig         `mapstructure:"s3"`
+}
+
+type FilesystemStorageConfig struct {
+	Dir string `mapstructure:"dir"`
+}
+
+type S3StorageConfig struct {
+	Endpoint  string `mapstructure:"endpoint"`
+	Bucket    string `mapstructure:"bucket"`
+	Region    string `mapstructure:"region"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	UseSSL    bool   `mapstructure:"use_ssl"`
+	PathStyle bool   `mapstructure:"path_style"`
+	Prefix    string `mapstructure:"prefix"`
 }
 
 type CacheConfig struct {
 	MaxEntrySizeMB int64 `mapstructure:"max_entry_size_mb"`
+	// TTL is the lifetime of a cache entry. Zero keeps entries until the
+	// backend evicts them.
+	TTL time.Duration `mapstructure:"ttl"`
+	// SlidingExpiry restarts the TTL on every read or existence check hit.
+	SlidingExpiry bool `mapstructure:"sliding_expiry"`
+	// MaxTotalSizeMB caps the total size of all entries. When exceeded, the
+	// least recently used entries are evicted. Zero disables the limit.
+	MaxTotalSizeMB int64 `mapstructure:"max_total_size_mb"`
+	// CapacityScanInterval is how often the backend is listed to reconcile
+	// the usage behind the size limit and namespace quotas.
+	CapacityScanInterval time.Duration    `mapstructure:"capacity_scan_interval"`
+	MemoryTier           MemoryTierConfig `mapstructure:"memory_tier"`
+	// VerifyChecksums stores a SHA-256 with every entry and verifies it on
+	// read. Checksums of existing entries are stripped either way.
+	VerifyChecksums bool              `mapstructure:"verify_checksums"`
+	Dedup           DedupConfig       `mapstructure:"dedup"`
+	Compression     CompressionConfig `mapstructure:"compression"`
+	Encryption      EncryptionConfig  `mapstructure:"encryption"`
+}
+
+// EncryptionConfig configures encryption of stored entries.
+type EncryptionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// ActiveKey is the ID of the key new entries are encrypted with. The
+	// other keys are only used to read entries written before a rotation.
+	ActiveKey string          `mapstructure:"active_key"`
+	Keys      []EncryptionKey `mapstructure:"keys"`
+}
+
+// EncryptionKey is a base64 encoded 32 byte master key, given inline or read
+// from KeyFile. Inline keys can reference environment variables as "${VAR}".
+type EncryptionKey struct {
+	ID      string `mapstructure:"id"`
+	Key     string `mapstructure:"key"`
+	KeyFile string `mapstructure:"key_file"`
+}
+
+// Secret returns the decoded key.
+func (k EncryptionKey) Secret() ([]byte, error) {
+	secret, err := base64.StdEncoding.DecodeString(strings.TrimSpace(k.Key))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key %q is not valid base64: %w", k.ID, err)
+	}
+	if len(secret) != 32 {
+		return nil, fmt.Errorf("encryption key %q must be 32 bytes", k.ID)
+	}
+	return secret, nil
+}
+
+// CompressionConfig configures compression of stored entries.
+type CompressionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Algorithm is "zstd" or "gzip".
+	Algorithm string `mapstructure:"algorithm"`
+	// Level is "fastest", "default", "better" or "best".
+	Level string `mapstructure:"level"`
+}
+
+// DedupConfig configures content-addressed storage of identical entries.
+type DedupConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// GCDelay is how long a blob no key refers to is kept before deletion.
+	GCDelay time.Duration `mapstructure:"gc_delay"`
+}
+
+// MemoryTierConfig configures the in-process LRU in front of the storage backend.
+type MemoryTierConfig struct {
+	Enabled        bool  `mapstructure:"enabled"`
+	MaxSizeMB      int64 `mapstructure:"max_size_mb"`
+	MaxEntries     int   `mapstructure:"max_entries"`
+	MaxEntrySizeKB int64 `mapstructure:"max_entry_size_kb"`
 }
 
 type AuthConfig struct {
-	Enabled bool     `mapstructure:"enabled"`
-	Reader  UserAuth `mapstructure:"reader"`
-	Writer  UserAuth `mapstructure:"writer"`
+	Enabled bool `mapstructure:"enabled"`
+	// Reader and Writer are the legacy fixed credentials. They act as users
+	// with the read and write role respectively.
+	Reader UserAuth   `mapstructure:"reader"`
+	Writer UserAuth   `mapstructure:"writer"`
+	Users  []UserAuth `mapstructure:"users"`
+	// Tokens are bearer tokens, stored only as salted hashes.
+	Tokens []TokenAuth `mapstructure:"tokens"`
+	// TokensFile is a YAML file with a "tokens" list in the same format as
+	// Tokens. Its entries are added to Tokens on load.
+	TokensFile string `mapstructure:"tokens_file"`
+	// JWT accepts bearer JWTs signed by an identity provider.
+	JWT JWTConfig `mapstructure:"jwt"`
+	// HtpasswdFile is an Apache-style htpasswd file with bcrypt or {SHA}
+	// hashes. It is reloaded when it changes. Users above without a password
+	// take theirs from this file; all other entries get HtpasswdRoles.
+	HtpasswdFile  string   `mapstructure:"htpasswd_file"`
+	HtpasswdRoles []string `mapstructure:"htpasswd_roles"`
+	// Lockout throttles clients after repeated authentication failures.
+	Lockout LockoutConfig `mapstructure:"lockout"`
+	// Certificates maps verified TLS client certificates to roles. The first
+	// matching rule wins.
+	Certificates []CertificateRule `mapstructure:"certificates"`
+}
+
+// CertificateRule matches a client certificate by subject common name or
+// SAN. Every set field must match; values are glob patterns as in path.Match.
+type CertificateRule struct {
+	CommonName string   `mapstructure:"common_name"`
+	DNSName    string   `mapstructure:"dns_name"`
+	URI        string   `mapstructure:"uri"`
+	Email      string   `mapstructure:"email"`
+	Roles      []string `mapstructure:"roles"`
+	// Namespaces restricts matching clients to these namespaces. Empty allows all.
+	Namespaces []string `mapstructure:"namespaces"`
+}
+
+// Patterns returns the rule's set patterns.
+func (r CertificateRule) Patterns() []string {
+	var patterns []string
+	for _, p := range []string{r.CommonName, r.DNSName, r.URI, r.Email} {
+		if p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// LockoutConfig configures brute-force protection. Failures are tracked per
+// client IP and per username and client IP; once either reaches MaxFailures
+// it is locked out for BaseDelay, doubling with every further failure up to
+// MaxDelay.
+type LockoutConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	MaxFailures int           `mapstructure:"max_failures"`
+	BaseDelay   time.Duration `mapstructure:"base_delay"`
+	MaxDelay    time.Duration `mapstructure:"max_delay"`
+	// ResetAfter forgets the failures of a client that stayed quiet this long.
+	ResetAfter time.Duration `mapstructure:"reset_after"`
+	// MaxTracked bounds how many clients and usernames are tracked at once.
+	// Locked out entries are kept beyond it until their lockout ends.
+	MaxTracked int `mapstructure:"max_tracked"`
+}
+
+// JWTConfig configures validation of JWTs against a JWKS.
+type JWTConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// JWKSFile or JWKSURL is the source of the signing keys.
+	JWKSFile string `mapstructure:"jwks_file"`
+	JWKSURL  string `mapstructure:"jwks_url"`
+	// RefreshInterval is how often the JWKS is reloaded.
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+	// Issuer and Audience are checked against the iss and aud claims if set.
+	Issuer   string `mapstructure:"issuer"`
+	Audience string `mapstructure:"audience"`
+	// RoleClaim holds the cache role, as a string or a list of strings.
+	RoleClaim string `mapstructure:"role_claim"`
+	// NamespaceClaim holds the namespaces the token is restricted to, as a
+	// string, a number or a list of them. Tokens without it are not restricted.
+	NamespaceClaim string `mapstructure:"namespace_claim"`
+	// UsernameClaim names the client in logs. Falls back to sub.
+	UsernameClaim string `mapstructure:"username_claim"`
 }
 
 type UserAuth struct {
 	Username string `mapstructure:"username"`
 	Password string `mapstructure:"password"`
+	// Roles granted to the user: "read", "write" and "admin".
+	Roles []string `mapstructure:"roles"`
+	// Namespaces restricts the user to these namespaces. Empty allows all.
+	Namespaces []string `mapstructure:"namespaces"`
+}
+
+// TokenAuth describes a bearer token.
+type TokenAuth struct {
+	// Name identifies the token in logs.
+	Name string `mapstructure:"name"`
+	// Hash is the salted token hash as printed by -gen-token.
+	Hash string `mapstructure:"hash"`
+	Role string `mapstructure:"role"`
+	// Namespaces restricts the token to these namespaces. Empty allows all.
+	Namespaces []string `mapstructure:"namespaces"`
+	// ExpiresAt is an optional RFC 3339 timestamp after which the token is rejected.
+	ExpiresAt string `mapstructure:"expires_at"`
+}
+
+// Expiry returns the parsed expiry time, or the zero time if none is set.
+func (t TokenAuth) Expiry() (time.Time, error) {
+	if t.ExpiresAt == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, t.ExpiresAt)
+}
+
+// Roles understood by the server. Each role includes the ones before it.
+const (
+	RoleRead  = "read"
+	RoleWrite = "write"
+	RoleAdmin = "admin"
+)
+
+// AllUsers returns the configured users including the legacy reader and
+// writer accounts.
+func (a AuthConfig) AllUsers() []UserAuth {
+	users := make([]UserAuth, 0, len(a.Users)+2)
+	if a.Reader.Username != "" {
+		reader := a.Reader
+		reader.Roles = []string{RoleRead}
+		users = append(users, reader)
+	}
+	if a.Writer.Username != "" {
+		writer := a.Writer
+		writer.Roles = []string{RoleWrite}
+		users = append(users, writer)
+	}
+	return append(users, a.Users...)
+}
+
+// NamespacesConfig controls per-namespace cache isolation over HTTP.
+type NamespacesConfig struct {
+	// Enabled exposes the cache under /ns/:namespace/cache/:key in addition
+	// to the default namespace under /cache/:key.
+	Enabled bool `mapstructure:"enabled"`
+	// Fallback lists namespaces that namespaced GET and HEAD requests read,
+	// in order, when an entry is missing. Writes never go to them.
+	Fallback []string `mapstructure:"fallback"`
+	// Quota applies to every namespace without an override.
+	Quota          QuotaConfig            `mapstructure:"quota"`
+	QuotaOverrides []NamespaceQuotaConfig `mapstructure:"quota_overrides"`
+}
+
+// QuotaConfig limits the entries of a namespace. Zero values mean unlimited.
+type QuotaConfig struct {
+	MaxSizeMB  int64 `mapstructure:"max_size_mb"`
+	MaxEntries int   `mapstructure:"max_entries"`
+	// EvictOldest evicts the namespace's least recently used entries to make
+	// room instead of rejecting the upload with 507.
+	EvictOldest bool `mapstructure:"evict_oldest"`
+}
+
+func (q QuotaConfig) Limited() bool {
+	return q.MaxSizeMB > 0 || q.MaxEntries > 0
+}
+
+type NamespaceQuotaConfig struct {
+	Namespace   string `mapstructure:"namespace"`
+	QuotaConfig `mapstructure:",squash"`
+}
+
+// HasQuotas reports whether any namespace quota is configured.
+func (n NamespacesConfig) HasQuotas() bool {
+	return n.Quota.Limited() || len(n.QuotaOverrides) > 0
+}
+
+// RateLimitConfig limits request rates and concurrent uploads of cache
+// clients. Limits apply per authenticated user (or client IP when auth is
+// disabled) and per namespace.
+type RateLimitConfig struct {
+	Enabled      bool      `mapstructure:"enabled"`
+	PerUser      RateLimit `mapstructure:"per_user"`
+	PerNamespace RateLimit `mapstructure:"per_namespace"`
+	// MaxTracked bounds how many users and namespaces are tracked at once.
+	MaxTracked int `mapstructure:"max_tracked"`
+}
+
+// RateLimit is a token bucket plus a bound on in-flight uploads. Zero values
+// disable the respective limit.
+type RateLimit struct {
+	RequestsPerSecond float64 `mapstructure:"requests_per_second"`
+	Burst             int     `mapstructure:"burst"`
+	MaxUploads        int     `mapstructure:"max_uploads"`
+}
+
+func (l RateLimit) validate(name string) error {
+	if l.RequestsPerSecond < 0 || l.MaxUploads < 0 {
+		return fmt.Errorf("rate_limit.%s limits must not be negative", name)
+	}
+	if l.RequestsPerSecond > 0 && l.Burst <= 0 {
+		return fmt.Errorf("rate_limit.%s.burst must be positive when requests_per_second is set", name)
+	}
+	return nil
+}
+
+// ProtocolsConfig enables cache protocols besides the Gradle one. They
+// share the storage, auth, rate limits and namespaces of the Gradle cache.
+type ProtocolsConfig struct {
+	Bazel  BazelConfig  `mapstructure:"bazel"`
+	Turbo  TurboConfig  `mapstructure:"turbo"`
+	Nx     NxConfig     `mapstructure:"nx"`
+	WebDAV WebDAVConfig `mapstructure:"webdav"`
+}
+
+type BazelConfig struct {
+	// Enabled serves Bazel's HTTP remote cache protocol under /ac and /cas.
+	Enabled bool            `mapstructure:"enabled"`
+	GRPC    BazelGRPCConfig `mapstructure:"grpc"`
+}
+
+// BazelGRPCConfig serves the cache services of the Remote Execution API on a
+// separate gRPC port, for clients using --remote_cache=grpc://. Instance
+// names select the namespace.
+type BazelGRPCConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	Port    int  `mapstructure:"port"`
+	// MaxBatchSizeMB bounds batch requests and gRPC messages. Larger blobs
+	// are transferred with ByteStream.
+	MaxBatchSizeMB int64 `mapstructure:"max_batch_size_mb"`
+}
+
+type TurboConfig struct {
+	// Enabled serves Turborepo's remote cache API under /v8/artifacts. The
+	// team or slug query parameter selects the namespace.
+	Enabled bool `mapstructure:"enabled"`
+}
+
+type NxConfig struct {
+	// Enabled serves Nx's self-hosted remote cache API under /v1/cache.
+	Enabled bool `mapstructure:"enabled"`
+}
+
+type WebDAVConfig struct {
+	// Enabled serves a minimal WebDAV store under /dav for sccache and
+	// ccache remote storage.
+	Enabled bool `mapstructure:"enabled"`
 }
 
 type MetricsConfig struct {
@@ -61,6 +413,13 @@ type LoggingConfig struct {
 	Format string `mapstructure:"format"`
 }
 
+// AuditConfig configures the audit log of write and admin operations.
+type AuditConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Output is "stdout", "stderr" or the path of a file to append to.
+	Output string `mapstructure:"output"`
+}
+
 type SentryConfig struct {
 	Dsn     string `mapstructure:"dsn"`
 	Enabled bool   `mapstructure:"enabled"`
@@ -76,14 +435,66 @@ func Load(configPath string) (*Config, error) {
 	v.SetDefault("server.tls.enabled", false)
 	v.SetDefault("server.tls.cert_file", "/etc/certs/tls.crt")
 	v.SetDefault("server.tls.key_file", "/etc/certs/tls.key")
+	v.SetDefault("server.tls.client_auth", ClientAuthNone)
 
+	v.SetDefault("storage.type", "redis")
 	v.SetDefault("storage.addr", "localhost:6379")
 	v.SetDefault("storage.password", "")
 	v.SetDefault("storage.db", 0)
+	v.SetDefault("storage.chunk_size_kb", 1024)
+	v.SetDefault("storage.filesystem.dir", "/var/lib/gradle-cache")
+	v.SetDefault("storage.s3.use_ssl", true)
+	v.SetDefault("storage.s3.path_style", false)
 
 	v.SetDefault("cache.max_entry_size_mb", 100)
+	v.SetDefault("cache.ttl", "0s")
+	v.SetDefault("cache.sliding_expiry", false)
+	v.SetDefault("cache.max_total_size_mb", 0)
+	v.SetDefault("cache.capacity_scan_interval", "10m")
+	v.SetDefault("cache.verify_checksums", false)
+	v.SetDefault("cache.dedup.enabled", false)
+	v.SetDefault("cache.dedup.gc_delay", "1m")
+	v.SetDefault("cache.encryption.enabled", false)
+	v.SetDefault("cache.compression.enabled", false)
+	v.SetDefault("cache.compression.algorithm", "zstd")
+	v.SetDefault("cache.compression.level", "default")
+	v.SetDefault("cache.memory_tier.enabled", false)
+	v.SetDefault("cache.memory_tier.max_size_mb", 128)
+	v.SetDefault("cache.memory_tier.max_entries", 10000)
+	v.SetDefault("cache.memory_tier.max_entry_size_kb", 1024)
 
 	v.SetDefault("auth.enabled", true)
+	v.SetDefault("auth.htpasswd_roles", []string{RoleRead})
+	v.SetDefault("auth.lockout.enabled", false)
+	v.SetDefault("auth.lockout.max_failures", 5)
+	v.SetDefault("auth.lockout.base_delay", "1s")
+	v.SetDefault("auth.lockout.max_delay", "15m")
+	v.SetDefault("auth.lockout.reset_after", "1h")
+	v.SetDefault("auth.lockout.max_tracked", 10000)
+	v.SetDefault("auth.jwt.enabled", false)
+	v.SetDefault("auth.jwt.refresh_interval", "5m")
+	v.SetDefault("auth.jwt.role_claim", "cache_role")
+	v.SetDefault("auth.jwt.namespace_claim", "exercise_id")
+	v.SetDefault("auth.jwt.username_claim", "preferred_username")
+
+	v.SetDefault("namespaces.enabled", false)
+
+	v.SetDefault("rate_limit.enabled", false)
+	v.SetDefault("rate_limit.per_user.requests_per_second", 50)
+	v.SetDefault("rate_limit.per_user.burst", 100)
+	v.SetDefault("rate_limit.per_user.max_uploads", 4)
+	v.SetDefault("rate_limit.per_namespace.requests_per_second", 0)
+	v.SetDefault("rate_limit.per_namespace.burst", 0)
+	v.SetDefault("rate_limit.per_namespace.max_uploads", 0)
+	v.SetDefault("rate_limit.max_tracked", 10000)
+
+	v.SetDefault("protocols.bazel.enabled", false)
+	v.SetDefault("protocols.bazel.grpc.enabled", false)
+	v.SetDefault("protocols.bazel.grpc.port", 9092)
+	v.SetDefault("protocols.bazel.grpc.max_batch_size_mb", 4)
+	v.SetDefault("protocols.turbo.enabled", false)
+	v.SetDefault("protocols.nx.enabled", false)
+	v.SetDefault("protocols.webdav.enabled", false)
 
 	v.SetDefault("metrics.enabled", true)
 
@@ -92,6 +503,9 @@ func Load(configPath string) (*Config, error) {
 	v.SetDefault("logging.level", "info")
 	v.SetDefault("logging.format", "json")
 
+	v.SetDefault("audit.enabled", false)
+	v.SetDefault("audit.output", "stdout")
+
 	// Read from config file if provided
 	if configPath != "" {
 		v.SetConfigFile(configPath)
@@ -106,6 +520,8 @@ func Load(configPath string) (*Config, error) {
 
 	// Bind specific environment variables
 	v.BindEnv("storage.password", "REDIS_PASSWORD")
+	v.BindEnv("storage.s3.access_key", "S3_ACCESS_KEY")
+	v.BindEnv("storage.s3.secret_key", "S3_SECRET_KEY")
 
 	v.BindEnv("auth.reader.password", "CACHE_READER_PASSWORD")
 	v.BindEnv("auth.writer.password", "CACHE_WRITER_PASSWORD")
@@ -117,19 +533,131 @@ func Load(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	// Allow user passwords to reference environment variables, e.g. "${CACHE_PASSWORD}"
+	for i := range cfg.Auth.Users {
+		cfg.Auth.Users[i].Password = expandEnv(cfg.Auth.Users[i].Password)
+	}
+
+	for i, k := range cfg.Cache.Encryption.Keys {
+		if k.KeyFile != "" {
+			if k.Key != "" {
+				return nil, fmt.Errorf("encryption key %q must set either key or key_file", k.ID)
+			}
+			data, err := os.ReadFile(k.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read encryption key file: %w", err)
+			}
+			cfg.Cache.Encryption.Keys[i].Key = string(data)
+		} else {
+			cfg.Cache.Encryption.Keys[i].Key = expandEnv(k.Key)
+		}
+	}
+
+	if cfg.Auth.TokensFile != "" {
+		tokens, err := loadTokensFile(cfg.Auth.TokensFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Auth.Tokens = append(cfg.Auth.Tokens, tokens...)
+	}
+
 	return &cfg, nil
 }
 
 func (c *Config) Validate() error {
-	if c.Storage.Addr == "" {
-		return fmt.Errorf("storage.addr is required")
+	switch c.Storage.Type {
+	case "redis":
+		if c.Storage.Addr == "" {
+			return fmt.Errorf("storage.addr is required")
+		}
+	case "filesystem":
+		if c.Storage.Filesystem.Dir == "" {
+			return fmt.Errorf("storage.filesystem.dir is required when storage.type is filesystem")
+		}
+	case "s3":
+		if c.Storage.S3.Endpoint == "" || c.Storage.S3.Bucket == "" {
+			return fmt.Errorf("storage.s3.endpoint and storage.s3.bucket are required when storage.type is s3")
+		}
+	default:
+		return fmt.Errorf("unknown storage.type %q", c.Storage.Type)
 	}
 	if c.Auth.Enabled {
-		if c.Auth.Reader.Username == "" || c.Auth.Reader.Password == "" {
-			return fmt.Errorf("auth.reader.username and auth.reader.password are required when auth is enabled")
+		if err := c.validateUsers(); err != nil {
+			return err
+		}
+	}
+	if c.Cache.TTL < 0 {
+		return fmt.Errorf("cache.ttl must not be negative")
+	}
+	if c.RateLimit.Enabled {
+		if err := c.RateLimit.PerUser.validate("per_user"); err != nil {
+			return err
+		}
+		if err := c.RateLimit.PerNamespace.validate("per_namespace"); err != nil {
+			return err
+		}
+		if c.RateLimit.MaxTracked <= 0 {
+			return fmt.Errorf("rate_limit.max_tracked must be positive")
+		}
+	}
+	if c.Cache.MaxTotalSizeMB < 0 {
+		return fmt.Errorf("cache.max_total_size_mb must not be negative")
+	}
+	if c.Cache.MaxTotalSizeMB > 0 && c.Cache.MaxTotalSizeMB < c.Cache.MaxEntrySizeMB {
+		return fmt.Errorf("cache.max_total_size_mb must be at least cache.max_entry_size_mb")
+	}
+	if c.Cache.MaxTotalSizeMB > 0 || c.Namespaces.HasQuotas() {
+		if c.Cache.CapacityScanInterval <= 0 {
+			return fmt.Errorf("cache.capacity_scan_interval must be positive")
+		}
+	}
+	if c.Cache.MemoryTier.Enabled {
+		if c.Cache.MemoryTier.MaxSizeMB <= 0 || c.Cache.MemoryTier.MaxEntries <= 0 {
+			return fmt.Errorf("cache.memory_tier.max_size_mb and cache.memory_tier.max_entries must be positive")
+		}
+	}
+	if c.Cache.Dedup.Enabled && c.Cache.Dedup.GCDelay < 0 {
+		return fmt.Errorf("cache.dedup.gc_delay must not be negative")
+	}
+	if enc := c.Cache.Encryption; enc.Enabled {
+		if err := enc.validate(); err != nil {
+			return err
+		}
+		// Blobs are shared across namespaces, which would defeat the
+		// per-namespace keys.
+		if c.Cache.Dedup.Enabled {
+			return fmt.Errorf("cache.encryption and cache.dedup cannot be enabled together")
+		}
+	}
+	if comp := c.Cache.Compression; comp.Enabled {
+		switch comp.Algorithm {
+		case "zstd", "gzip":
+		default:
+			return fmt.Errorf("cache.compression.algorithm must be zstd or gzip")
+		}
+		switch comp.Level {
+		case "fastest", "default", "better", "best":
+		default:
+			return fmt.Errorf("cache.compression.level must be fastest, default, better or best")
+		}
+	}
+	if g := c.Protocols.Bazel.GRPC; g.Enabled {
+		if g.Port <= 0 || g.Port == c.Server.Port {
+			return fmt.Errorf("protocols.bazel.grpc.port must be positive and differ from server.port")
+		}
+		if g.MaxBatchSizeMB <= 0 {
+			return fmt.Errorf("protocols.bazel.grpc.max_batch_size_mb must be positive")
 		}
-		if c.Auth.Writer.Username == "" || c.Auth.Writer.Password == "" {
-			return fmt.Errorf("auth.writer.username and auth.writer.password are required when auth is enabled")
+	}
+	if len(c.Namespaces.Fallback) > 0 && !c.Namespaces.Enabled {
+		return fmt.Errorf("namespaces.fallback requires namespaces.enabled")
+	}
+	if c.Namespaces.HasQuotas() && !c.Namespaces.Enabled {
+		return fmt.Errorf("namespace quotas require namespaces.enabled")
+	}
+	for _, o := range c.Namespaces.QuotaOverrides {
+		if o.Namespace == "" {
+			return fmt.Errorf("namespaces.quota_overrides entries require a namespace")
 		}
 	}
 	if c.Server.TLS.Enabled {
@@ -140,9 +668,183 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("server.tls.key_file is required when TLS is enabled")
 		}
 	}
+	switch c.Server.TLS.ClientAuth {
+	case ClientAuthNone:
+	case ClientAuthOptional, ClientAuthRequired:
+		if !c.Server.TLS.Enabled {
+			return fmt.Errorf("server.tls.client_auth requires TLS to be enabled")
+		}
+		if c.Server.TLS.ClientCAFile == "" {
+			return fmt.Errorf("server.tls.client_ca_file is required when client_auth is %q", c.Server.TLS.ClientAuth)
+		}
+	default:
+		return fmt.Errorf("unknown server.tls.client_auth %q", c.Server.TLS.ClientAuth)
+	}
+	return nil
+}
+
+func (c EncryptionConfig) validate() error {
+	if len(c.Keys) == 0 {
+		return fmt.Errorf("cache.encryption.keys is required when encryption is enabled")
+	}
+	seen := make(map[string]bool, len(c.Keys))
+	for _, k := range c.Keys {
+		if k.ID == "" || len(k.ID) > 255 {
+			return fmt.Errorf("cache.encryption.keys require an id of at most 255 bytes")
+		}
+		if seen[k.ID] {
+			return fmt.Errorf("encryption key %q is defined more than once", k.ID)
+		}
+		seen[k.ID] = true
+		if _, err := k.Secret(); err != nil {
+			return err
+		}
+	}
+	if !seen[c.ActiveKey] {
+		return fmt.Errorf("cache.encryption.active_key %q is not one of cache.encryption.keys", c.ActiveKey)
+	}
+	return nil
+}
+
+// envReference matches the "${VAR}" references expandEnv replaces.
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces "${VAR}" references with the value of the environment
+// variable. Unlike os.ExpandEnv it leaves every other "$" alone, so literal
+// passwords such as "pa$$word" are kept intact.
+func expandEnv(s string) string {
+	return envReference.ReplaceAllStringFunc(s, func(ref string) string {
+		return os.Getenv(ref[2 : len(ref)-1])
+	})
+}
+
+// loadTokensFile reads bearer token definitions from a YAML file.
+func loadTokensFile(path string) ([]TokenAuth, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read tokens file: %w", err)
+	}
+	var tokens []TokenAuth
+	if err := v.UnmarshalKey("tokens", &tokens); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tokens file: %w", err)
+	}
+	return tokens, nil
+}
+
+func validRole(role string) bool {
+	return role == RoleRead || role == RoleWrite || role == RoleAdmin
+}
+
+func (c *Config) validateUsers() error {
+	users := c.Auth.AllUsers()
+	if len(users) == 0 && len(c.Auth.Tokens) == 0 && !c.Auth.JWT.Enabled &&
+		len(c.Auth.Certificates) == 0 && c.Auth.HtpasswdFile == "" {
+		return fmt.Errorf("at least one user, token, certificate rule or htpasswd file is required when auth is enabled")
+	}
+	for _, role := range c.Auth.HtpasswdRoles {
+		if !validRole(role) {
+			return fmt.Errorf("auth.htpasswd_roles has unknown role %q", role)
+		}
+	}
+	seen := make(map[string]bool, len(users))
+	for _, u := range users {
+		if u.Username == "" {
+			return fmt.Errorf("auth users require a username")
+		}
+		if u.Password == "" && c.Auth.HtpasswdFile == "" {
+			return fmt.Errorf("auth user %q requires a password or auth.htpasswd_file", u.Username)
+		}
+		if seen[u.Username] {
+			return fmt.Errorf("auth user %q is defined more than once", u.Username)
+		}
+		seen[u.Username] = true
+		if len(u.Roles) == 0 {
+			return fmt.Errorf("auth user %q has no roles", u.Username)
+		}
+		for _, role := range u.Roles {
+			if !validRole(role) {
+				return fmt.Errorf("auth user %q has unknown role %q", u.Username, role)
+			}
+		}
+		if len(u.Namespaces) > 0 && !c.Namespaces.Enabled {
+			return fmt.Errorf("auth user %q is restricted to namespaces but namespaces are disabled", u.Username)
+		}
+	}
+
+	names := make(map[string]bool, len(c.Auth.Tokens))
+	for _, t := range c.Auth.Tokens {
+		if t.Name == "" || t.Hash == "" {
+			return fmt.Errorf("auth tokens require a name and hash")
+		}
+		if names[t.Name] {
+			return fmt.Errorf("auth token %q is defined more than once", t.Name)
+		}
+		names[t.Name] = true
+		if !validRole(t.Role) {
+			return fmt.Errorf("auth token %q has unknown role %q", t.Name, t.Role)
+		}
+		if _, err := t.Expiry(); err != nil {
+			return fmt.Errorf("auth token %q has invalid expires_at: %w", t.Name, err)
+		}
+		if len(t.Namespaces) > 0 && !c.Namespaces.Enabled {
+			return fmt.Errorf("auth token %q is restricted to namespaces but namespaces are disabled", t.Name)
+		}
+	}
+
+	if l := c.Auth.Lockout; l.Enabled {
+		if l.MaxFailures <= 0 || l.MaxTracked <= 0 {
+			return fmt.Errorf("auth.lockout.max_failures and auth.lockout.max_tracked must be positive")
+		}
+		if l.BaseDelay <= 0 || l.MaxDelay < l.BaseDelay || l.ResetAfter <= 0 {
+			return fmt.Errorf("auth.lockout requires 0 < base_delay <= max_delay and a positive reset_after")
+		}
+	}
+
+	if jwt := c.Auth.JWT; jwt.Enabled {
+		if (jwt.JWKSFile == "") == (jwt.JWKSURL == "") {
+			return fmt.Errorf("exactly one of auth.jwt.jwks_file and auth.jwt.jwks_url is required")
+		}
+		if jwt.RefreshInterval <= 0 {
+			return fmt.Errorf("auth.jwt.refresh_interval must be positive")
+		}
+		if jwt.RoleClaim == "" {
+			return fmt.Errorf("auth.jwt.role_claim is required")
+		}
+	}
+
+	if len(c.Auth.Certificates) > 0 && c.Server.TLS.ClientAuth == ClientAuthNone {
+		return fmt.Errorf("auth.certificates requires server.tls.client_auth")
+	}
+	for i, rule := range c.Auth.Certificates {
+		patterns := rule.Patterns()
+		if len(patterns) == 0 {
+			return fmt.Errorf("auth certificate rule %d has nothing to match", i)
+		}
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("auth certificate rule %d has invalid pattern %q", i, p)
+			}
+		}
+		if len(rule.Roles) == 0 {
+			return fmt.Errorf("auth certificate rule %d has no roles", i)
+		}
+		for _, role := range rule.Roles {
+			if !validRole(role) {
+				return fmt.Errorf("auth certificate rule %d has unknown role %q", i, role)
+			}
+		}
+		if len(rule.Namespaces) > 0 && !c.Namespaces.Enabled {
+			return fmt.Errorf("auth certificate rule %d is restricted to namespaces but namespaces are disabled", i)
+		}
+	}
 	return nil
 }
 
 func (c *Config) MaxEntrySizeBytes() int64 {
 	return c.Cache.MaxEntrySizeMB * 1024 * 1024
 }
+
+func (b BazelGRPCConfig) MaxBatchSizeBytes() int64 {
+	return b.MaxBatchSizeMB * 1024 * 1024
+}
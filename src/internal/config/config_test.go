@@ -0,0 +1,77 @@
+package config
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig returns a configuration that passes Validate.
+func validConfig() *Config {
+	return &Config{
+		Server:  ServerConfig{Port: 8080, TLS: TLSConfig{ClientAuth: ClientAuthNone}},
+		Storage: StorageConfig{Type: "redis", Addr: "localhost:6379"},
+		Cache: CacheConfig{
+			MaxEntrySizeMB:       100,
+			CapacityScanInterval: 10 * time.Minute,
+		},
+	}
+}
+
+func expectInvalid(t *testing.T, cfg *Config, want string) {
+	t.Helper()
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("Validate() = %v, want an error mentioning %q", err, want)
+	}
+}
+
+func TestValidateCapacityLimits(t *testing.T) {
+	cfg := validConfig()
+	cfg.Cache.MaxTotalSizeMB = 1024
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	cfg.Storage = StorageConfig{Type: "filesystem", Filesystem: FilesystemStorageConfig{Dir: "/tmp"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() with the filesystem backend = %v", err)
+	}
+
+	cfg = validConfig()
+	cfg.Namespaces = NamespacesConfig{Enabled: true, Quota: QuotaConfig{MaxEntries: 10}}
+	cfg.Cache.CapacityScanInterval = 0
+	expectInvalid(t, cfg, "cache.capacity_scan_interval")
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("CACHE_PASSWORD", "s3cret")
+
+	for in, want := range map[string]string{
+		"${CACHE_PASSWORD}":          "s3cret",
+		"pre-${CACHE_PASSWORD}-post": "pre-s3cret-post",
+		"pa$$word":                   "pa$$word",
+		"$CACHE_PASSWORD":            "$CACHE_PASSWORD",
+		"${UNSET_CACHE_VARIABLE}":    "",
+	} {
+		if got := expandEnv(in); got != want {
+			t.Errorf("expandEnv(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestValidateEncryptionWithDedup(t *testing.T) {
+	cfg := validConfig()
+	cfg.Cache.Encryption = EncryptionConfig{
+		Enabled:   true,
+		ActiveKey: "k1",
+		Keys:      []EncryptionKey{{ID: "k1", Key: base64.StdEncoding.EncodeToString(make([]byte, 32))}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	cfg.Cache.Dedup = DedupConfig{Enabled: true, GCDelay: time.Minute}
+	expectInvalid(t, cfg, "cannot be enabled together")
+}
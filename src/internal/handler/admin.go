@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kevingruber/gradle-cache/internal/storage"
+)
+
+// AdminHandler serves administrative endpoints.
+type AdminHandler struct {
+	capacity *storage.CapacityManager
+}
+
+// NewAdminHandler creates a new admin handler. capacity may be nil if
+// neither a size limit nor namespace quotas are configured.
+func NewAdminHandler(capacity *storage.CapacityManager) *AdminHandler {
+	return &AdminHandler{capacity: capacity}
+}
+
+// Quotas reports the usage and quota of every known namespace. Usage is
+// what this replica tracks: its own writes plus what the last scan of the
+// backend found, which last_scan dates. It is null before the first scan.
+func (h *AdminHandler) Quotas(c *gin.Context) {
+	if h.capacity == nil {
+		c.JSON(http.StatusOK, gin.H{"namespaces": []storage.NamespaceUsage{}, "last_scan": nil})
+		return
+	}
+	var lastScan *time.Time
+	if t := h.capacity.LastScan(); !t.IsZero() {
+		lastScan = &t
+	}
+	c.JSON(http.StatusOK, gin.H{"namespaces": h.capacity.Usage(), "last_scan": lastScan})
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/kevingruber/gradle-cache/internal/storage"
+	"github.com/rs/zerolog"
+)
+
+func TestAdminQuotas(t *testing.T) {
+	backend, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	capacity, err := storage.NewCapacityManager(backend, storage.CapacityConfig{
+		Quotas: map[string]storage.Quota{"team": {MaxEntries: 5}},
+	}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, h := range []*AdminHandler{NewAdminHandler(nil), NewAdminHandler(capacity)} {
+		w := serve(h.Quotas, "/admin/quotas", http.MethodGet, "/admin/quotas", nil, 0)
+		var body struct {
+			Namespaces []storage.NamespaceUsage `json:"namespaces"`
+			LastScan   *string                  `json:"last_scan"`
+		}
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &body) != nil {
+			t.Fatalf("response %d %s", w.Code, w.Body)
+		}
+		if body.LastScan != nil {
+			t.Fatalf("last_scan = %q without a scan", *body.LastScan)
+		}
+		if h.capacity != nil && (len(body.Namespaces) != 1 || body.Namespaces[0].MaxEntries != 5) {
+			t.Fatalf("namespaces = %+v", body.Namespaces)
+		}
+	}
+}
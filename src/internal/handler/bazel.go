@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Bazel stores. The action cache maps action digests to serialized
+// ActionResult messages, the content-addressable storage maps the SHA-256
+// of a blob to the blob.
+const (
+	BazelAC  = "ac"
+	BazelCAS = "cas"
+)
+
+var bazelHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ErrDigestMismatch is returned from an upload body whose SHA-256 does not
+// match the expected one.
+var ErrDigestMismatch = errors.New("digest mismatch")
+
+// BazelKey returns the storage key of a Bazel AC or CAS entry. Bazel entries
+// share the storage with Gradle entries, so they are prefixed to keep them
+// apart.
+func BazelKey(store, hash string) string {
+	return "bazel-" + store + "-" + hash
+}
+
+// ValidBazelHash reports whether hash is a lowercase hex SHA-256.
+func ValidBazelHash(hash string) bool {
+	return bazelHashPattern.MatchString(hash)
+}
+
+// BazelHandler implements Bazel's HTTP remote caching protocol on top of a
+// CacheHandler, sharing its storage, size limit and metrics.
+type BazelHandler struct {
+	cache *CacheHandler
+}
+
+// NewBazelHandler creates a Bazel handler serving from cache.
+func NewBazelHandler(cache *CacheHandler) *BazelHandler {
+	return &BazelHandler{cache: cache}
+}
+
+// Get handles GET requests for entries of store.
+// Bazel expects: 200 with body on hit, 404 on miss.
+func (h *BazelHandler) Get(store string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if hash, ok := bazelHash(c); ok {
+			h.cache.get(c, BazelKey(store, hash))
+		}
+	}
+}
+
+// Head handles HEAD requests for entries of store.
+func (h *BazelHandler) Head(store string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if hash, ok := bazelHash(c); ok {
+			h.cache.head(c, BazelKey(store, hash))
+		}
+	}
+}
+
+// Put handles PUT requests for entries of store. CAS uploads must have the
+// SHA-256 they are stored under.
+func (h *BazelHandler) Put(store string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hash, ok := bazelHash(c)
+		if !ok {
+			return
+		}
+		var digest []byte
+		if store == BazelCAS {
+			digest, _ = hex.DecodeString(hash)
+		}
+		h.cache.put(c, BazelKey(store, hash), digest)
+	}
+}
+
+func bazelHash(c *gin.Context) (string, bool) {
+	hash := c.Param("hash")
+	if !ValidBazelHash(hash) {
+		c.Status(http.StatusBadRequest)
+		return "", false
+	}
+	return hash, true
+}
+
+// DigestVerifier passes a body of size bytes through and fails if it does
+// not have the expected SHA-256, so that storage discards the upload. The
+// read that completes the body returns no bytes on a mismatch, since
+// io.ReadFull ignores errors that come with the bytes it asked for.
+type DigestVerifier struct {
+	reader    io.Reader
+	hash      hash.Hash
+	digest    []byte
+	sized     bool
+	remaining int64
+	failed    bool
+}
+
+// NewDigestVerifier verifies that reader returns size bytes with SHA-256
+// digest. A negative size verifies the body once reader reaches EOF.
+func NewDigestVerifier(reader io.Reader, digest []byte, size int64) *DigestVerifier {
+	return &DigestVerifier{reader: reader, hash: sha256.New(), digest: digest, sized: size >= 0, remaining: size}
+}
+
+func (v *DigestVerifier) Read(p []byte) (int, error) {
+	if v.failed {
+		return 0, ErrDigestMismatch
+	}
+	n, err := v.reader.Read(p)
+	v.hash.Write(p[:n])
+	v.remaining -= int64(n)
+	if ((v.sized && v.remaining <= 0) || errors.Is(err, io.EOF)) && !bytes.Equal(v.hash.Sum(nil), v.digest) {
+		v.failed = true
+		return 0, ErrDigestMismatch
+	}
+	return n, err
+}
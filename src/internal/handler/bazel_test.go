@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBazelRoundTrip(t *testing.T) {
+	h, store := newTestCacheHandler(t, 1024)
+	bazel := NewBazelHandler(h)
+	data := []byte("blob")
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	for _, s := range []string{BazelAC, BazelCAS} {
+		route, path := "/"+s+"/:hash", "/"+s+"/"+hash
+		if w := serve(bazel.Get(s), route, http.MethodGet, path, nil, 0); w.Code != http.StatusNotFound {
+			t.Fatalf("%s: GET before upload = %d, want 404", s, w.Code)
+		}
+		if w := serve(bazel.Put(s), route, http.MethodPut, path, data, int64(len(data))); w.Code != http.StatusCreated {
+			t.Fatalf("%s: PUT = %d, want 201", s, w.Code)
+		}
+		w := serve(bazel.Get(s), route, http.MethodGet, path, nil, 0)
+		if w.Code != http.StatusOK || w.Body.String() != "blob" {
+			t.Fatalf("%s: GET = %d %q", s, w.Code, w.Body.String())
+		}
+		if w := serve(bazel.Head(s), route, http.MethodHead, path, nil, 0); w.Code != http.StatusOK {
+			t.Fatalf("%s: HEAD = %d, want 200", s, w.Code)
+		}
+
+		r, _, err := store.Get(t.Context(), BazelKey(s, hash))
+		if err != nil {
+			t.Fatalf("%s: entry not stored under its key: %v", s, err)
+		}
+		r.Close()
+	}
+}
+
+func TestBazelRejectsInvalidHash(t *testing.T) {
+	h, _ := newTestCacheHandler(t, 1024)
+	bazel := NewBazelHandler(h)
+
+	for _, hash := range []string{"abc", strings.Repeat("A", 64), strings.Repeat("0", 63) + "g"} {
+		path := "/cas/" + hash
+		if w := serve(bazel.Get(BazelCAS), "/cas/:hash", http.MethodGet, path, nil, 0); w.Code != http.StatusBadRequest {
+			t.Fatalf("GET %s = %d, want 400", hash, w.Code)
+		}
+		if w := serve(bazel.Put(BazelCAS), "/cas/:hash", http.MethodPut, path, []byte("x"), 1); w.Code != http.StatusBadRequest {
+			t.Fatalf("PUT %s = %d, want 400", hash, w.Code)
+		}
+	}
+}
+
+func TestBazelCASVerifiesDigest(t *testing.T) {
+	h, store := newTestCacheHandler(t, 1024)
+	bazel := NewBazelHandler(h)
+	sum := sha256.Sum256([]byte("expected"))
+	hash := hex.EncodeToString(sum[:])
+
+	for _, size := range []int64{7, -1} {
+		w := serve(bazel.Put(BazelCAS), "/cas/:hash", http.MethodPut, "/cas/"+hash, []byte("another"), size)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("size %d: PUT = %d, want 400", size, w.Code)
+		}
+		if ok, _ := store.Exists(t.Context(), BazelKey(BazelCAS, hash)); ok {
+			t.Fatalf("size %d: mismatching upload was stored", size)
+		}
+	}
+
+	// The action cache holds ActionResults, not content under its digest.
+	w := serve(bazel.Put(BazelAC), "/ac/:hash", http.MethodPut, "/ac/"+hash, []byte("another"), 7)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("AC PUT = %d, want 201", w.Code)
+	}
+}
+
+func TestGradleRejectsReservedKeys(t *testing.T) {
+	h, store := newTestCacheHandler(t, 1024)
+	bazel := NewBazelHandler(h)
+	sum := sha256.Sum256([]byte("blob"))
+	hash := hex.EncodeToString(sum[:])
+	serve(bazel.Put(BazelCAS), "/cas/:hash", http.MethodPut, "/cas/"+hash, []byte("blob"), 4)
+
+	for _, key := range []string{BazelKey(BazelCAS, hash), "turbo-abc", "nx-abc", "dav-abc"} {
+		path := "/cache/" + key
+		if w := serve(h.Get, "/cache/:key", http.MethodGet, path, nil, 0); w.Code != http.StatusBadRequest {
+			t.Fatalf("GET %s = %d, want 400", key, w.Code)
+		}
+		if w := serve(h.Head, "/cache/:key", http.MethodHead, path, nil, 0); w.Code != http.StatusBadRequest {
+			t.Fatalf("HEAD %s = %d, want 400", key, w.Code)
+		}
+		if w := serve(h.Put, "/cache/:key", http.MethodPut, path, []byte("evil"), 4); w.Code != http.StatusBadRequest {
+			t.Fatalf("PUT %s = %d, want 400", key, w.Code)
+		}
+	}
+
+	r, _, err := store.Get(t.Context(), BazelKey(BazelCAS, hash))
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer r.Close()
+	if data, _ := io.ReadAll(r); string(data) != "blob" {
+		t.Fatalf("Bazel entry was overwritten with %q", data)
+	}
+
+	if w := serve(h.Get, "/cache/:key", http.MethodGet, "/cache/bazelish", nil, 0); w.Code != http.StatusNotFound {
+		t.Fatalf("GET of unreserved key = %d, want 404", w.Code)
+	}
+}
@@ -1,22 +1,50 @@
 package handler
 
 import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/kevingruber/gradle-cache/internal/storage"
+	"io"
 	"net/http"
 )
 
 // Get handles GET requests to retrieve cache entries.
 // Gradle expects: 200 with body on hit, 404 on miss.
 func (h *CacheHandler) Get(c *gin.Context) {
-	key := c.Param("key")
+	if key, ok := gradleKey(c); ok {
+		h.get(c, key)
+	}
+}
+
+func (h *CacheHandler) get(c *gin.Context, key string) {
+	h.getWithPrefix(c, key, nil)
+}
+
+// prefixReader consumes the prefix that putWithPrefix stored in front of an
+// entry and returns its length. It may set response headers from the
+// prefix, and returns 0 for entries stored without one.
+type prefixReader func(c *gin.Context, r *bufio.Reader) (int64, error)
+
+// getWithPrefix serves the entry stored under key after readPrefix has
+// consumed its prefix. The stored digest covers the prefix, so it is not
+// advertised. A nil readPrefix serves the whole entry.
+func (h *CacheHandler) getWithPrefix(c *gin.Context, key string, readPrefix prefixReader) {
 	if key == "" {
 		c.Status(http.StatusBadRequest)
 		return
 	}
 
-	reader, size, err := h.storage.Get(c.Request.Context(), key)
+	store, err := h.store(c)
+	if err != nil {
+		h.logger.Error().Err(err).Str("key", key).Msg("failed to resolve cache namespace")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	reader, size, err := store.Get(c.Request.Context(), key)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			h.metrics.CacheMisses.Add(c.Request.Context(), 1)
@@ -29,8 +57,35 @@ func (h *CacheHandler) Get(c *gin.Context) {
 	}
 	defer reader.Close()
 
+	var body io.Reader = reader
+	if readPrefix != nil {
+		buffered := bufio.NewReader(reader)
+		n, err := readPrefix(c, buffered)
+		if err != nil {
+			h.logger.Error().Err(err).Str("key", key).Msg("failed to read cache entry prefix")
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		body, size = buffered, size-n
+	} else if d, ok := reader.(storage.Digester); ok && d.Digest() != nil {
+		setDigestHeaders(c, d.Digest())
+	}
+
 	c.Header("Content-Type", "application/octet-stream")
-	c.Header("Content-Length", string(rune(size)))
 	h.metrics.CacheHits.Add(c.Request.Context(), 1)
-	c.DataFromReader(http.StatusOK, size, "application/octet-stream", reader, nil)
+	c.DataFromReader(http.StatusOK, size, "application/octet-stream", body, nil)
+
+	// A streamed entry that fails verification can only be aborted mid-body.
+	if err := c.Errors.Last(); err != nil {
+		h.logger.Error().Err(err).Str("key", key).Msg("failed to stream cache entry")
+	}
+}
+
+// setDigestHeaders advertises the SHA-256 of the entry as ETag and in both
+// the legacy Digest (RFC 3230) and Content-Digest (RFC 9530) headers.
+func setDigestHeaders(c *gin.Context, digest []byte) {
+	b64 := base64.StdEncoding.EncodeToString(digest)
+	c.Header("ETag", `"`+hex.EncodeToString(digest)+`"`)
+	c.Header("Digest", "sha-256="+b64)
+	c.Header("Content-Digest", "sha-256=:"+b64+":")
 }
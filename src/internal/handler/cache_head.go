@@ -1,29 +1,57 @@
 package handler
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/kevingruber/gradle-cache/internal/storage"
 	"net/http"
 )
 
-// Head handles HEAD requests to check cache entry existence.
+// Head handles HEAD requests to check cache entry existence. Entries with a
+// stored checksum advertise it like GET does.
 func (h *CacheHandler) Head(c *gin.Context) {
-	key := c.Param("key")
+	if key, ok := gradleKey(c); ok {
+		h.head(c, key)
+	}
+}
+
+func (h *CacheHandler) head(c *gin.Context, key string) {
+	h.headEntry(c, key, true)
+}
+
+// headWithPrefix handles HEAD requests for entries stored by
+// putWithPrefix, whose stored digest covers the prefix and is therefore
+// not advertised.
+func (h *CacheHandler) headWithPrefix(c *gin.Context, key string) {
+	h.headEntry(c, key, false)
+}
+
+func (h *CacheHandler) headEntry(c *gin.Context, key string, withDigest bool) {
 	if key == "" {
 		c.Status(http.StatusBadRequest)
 		return
 	}
 
-	exists, err := h.storage.Exists(c.Request.Context(), key)
+	store, err := h.store(c)
 	if err != nil {
-		h.logger.Error().Err(err).Str("key", key).Msg("failed to check cache entry existence")
+		h.logger.Error().Err(err).Str("key", key).Msg("failed to resolve cache namespace")
 		c.Status(http.StatusInternalServerError)
 		return
 	}
 
-	if !exists {
+	info, err := storage.Stat(c.Request.Context(), store, key)
+	if errors.Is(err, storage.ErrNotFound) {
 		c.Status(http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		h.logger.Error().Err(err).Str("key", key).Msg("failed to check cache entry existence")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
 
+	if withDigest && info.Digest != nil {
+		setDigestHeaders(c, info.Digest)
+	}
 	c.Status(http.StatusOK)
 }
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/kevingruber/gradle-cache/internal/storage"
+	"github.com/rs/zerolog"
+)
+
+func TestHeadSendsDigest(t *testing.T) {
+	backend, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := storage.NewChecksumStorage(backend, storage.ChecksumConfig{Verify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewCacheHandler(store, CacheHandlerConfig{MaxEntrySize: 1024}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("entry")
+	sum := sha256.Sum256(data)
+	if err := store.Put(t.Context(), "abc", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	w := serve(h.Head, "/cache/:key", http.MethodHead, "/cache/abc", nil, 0)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if got, want := w.Header().Get("ETag"), `"`+hex.EncodeToString(sum[:])+`"`; got != want {
+		t.Fatalf("ETag = %q, want %q", got, want)
+	}
+	if w.Header().Get("Digest") == "" || w.Header().Get("Content-Digest") == "" {
+		t.Fatal("Digest headers missing")
+	}
+
+	if w := serve(h.Head, "/cache/:key", http.MethodHead, "/cache/missing", nil, 0); w.Code != http.StatusNotFound {
+		t.Fatalf("missing entry: status = %d, want 404", w.Code)
+	}
+}
@@ -1,20 +1,46 @@
 package handler
 
 import (
+	"bytes"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/kevingruber/gradle-cache/internal/middleware"
+	"github.com/kevingruber/gradle-cache/internal/storage"
 	"io"
 	"net/http"
 )
 
 // Put handles PUT requests to store cache entries.
 // Gradle expects: 2xx on success, 413 if too large.
+// Uploads exceeding the namespace quota are rejected with 507.
 func (h *CacheHandler) Put(c *gin.Context) {
-	key := c.Param("key")
+	if key, ok := gradleKey(c); ok {
+		h.put(c, key, nil)
+	}
+}
+
+// put stores the request body under key. If digest is set, the body must
+// have that SHA-256 or the upload is rejected with 400.
+func (h *CacheHandler) put(c *gin.Context, key string, digest []byte) {
+	h.putWithPrefix(c, key, nil, digest)
+}
+
+// putWithPrefix stores prefix followed by the request body under key, for
+// protocols that keep metadata with an entry. The size limit and digest
+// apply to the body alone.
+func (h *CacheHandler) putWithPrefix(c *gin.Context, key string, prefix, digest []byte) {
 	if key == "" {
 		c.Status(http.StatusBadRequest)
 		return
 	}
 
+	store, err := h.store(c)
+	if err != nil {
+		h.logger.Error().Err(err).Str("key", key).Msg("failed to resolve cache namespace")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
 	// Check Content-Length header for size validation
 	contentLength := c.Request.ContentLength
 	if contentLength > h.maxEntrySize {
@@ -30,29 +56,47 @@ func (h *CacheHandler) Put(c *gin.Context) {
 	// Handle Expect: 100-continue
 	// Gin/Go handles this automatically, but we validate size first
 
-	// For chunked transfers or unknown size, we need to handle differently
+	var body io.Reader = c.Request.Body
+	var limited *sizeLimitedReader
 	if contentLength < 0 {
-		// Read with size limit
-		limitedReader := io.LimitReader(c.Request.Body, h.maxEntrySize+1)
-		data, err := io.ReadAll(limitedReader)
-		if err != nil {
-			h.logger.Error().Err(err).Str("key", key).Msg("failed to read request body")
-			c.Status(http.StatusInternalServerError)
-			return
-		}
-
-		if int64(len(data)) > h.maxEntrySize {
-			c.Status(http.StatusRequestEntityTooLarge)
-			return
+		// Bodies of unknown length are streamed and cut off once they
+		// exceed the size limit.
+		limited = &sizeLimitedReader{r: body, remaining: h.maxEntrySize}
+		body = limited
+	}
+	if digest != nil {
+		body = NewDigestVerifier(body, digest, contentLength)
+	}
+	size := contentLength
+	if len(prefix) > 0 {
+		body = io.MultiReader(bytes.NewReader(prefix), body)
+		if size >= 0 {
+			size += int64(len(prefix))
 		}
-
-		contentLength = int64(len(data))
-		c.Request.Body = io.NopCloser(io.NewSectionReader(
-			&bytesReaderAt{data: data}, 0, contentLength,
-		))
 	}
-
-	err := h.storage.Put(c.Request.Context(), key, c.Request.Body, contentLength)
+	err = store.Put(c.Request.Context(), key, body, size)
+	if limited != nil && limited.exceeded() {
+		h.logger.Warn().
+			Str("key", key).
+			Int64("max_size", h.maxEntrySize).
+			Msg("cache entry too large")
+		c.Status(http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, ErrDigestMismatch) {
+		h.logger.Warn().Str("key", key).Msg("upload does not match its digest")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, storage.ErrQuotaExceeded) {
+		h.logger.Warn().
+			Str("key", key).
+			Str("namespace", c.GetString(middleware.NamespaceKey)).
+			Int64("size", contentLength).
+			Msg("namespace quota exceeded")
+		c.Status(http.StatusInsufficientStorage)
+		return
+	}
 	if err != nil {
 		h.logger.Error().Err(err).Str("key", key).Msg("failed to store cache entry")
 		c.Status(http.StatusInternalServerError)
@@ -61,3 +105,32 @@ func (h *CacheHandler) Put(c *gin.Context) {
 
 	c.Status(http.StatusCreated)
 }
+
+// errEntryTooLarge aborts an upload that exceeds the entry size limit.
+var errEntryTooLarge = errors.New("cache entry too large")
+
+// sizeLimitedReader fails with errEntryTooLarge once more than remaining
+// bytes are read.
+type sizeLimitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	if l.exceeded() {
+		return 0, errEntryTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.exceeded() {
+		return 0, errEntryTooLarge
+	}
+	return n, err
+}
+
+func (l *sizeLimitedReader) exceeded() bool {
+	return l.remaining < 0
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kevingruber/gradle-cache/internal/storage"
+	"github.com/rs/zerolog"
+)
+
+func newTestCacheHandler(t *testing.T, maxEntrySize int64) (*CacheHandler, storage.Storage) {
+	t.Helper()
+	store, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewCacheHandler(store, CacheHandlerConfig{MaxEntrySize: maxEntrySize}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, store
+}
+
+// serve runs handler for a request with the given method, path and body.
+// A negative size sends the body without a Content-Length.
+func serve(handler gin.HandlerFunc, route, method, path string, body []byte, size int64) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Handle(method, route, handler)
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	req.ContentLength = size
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestPutStreamsUnknownLength(t *testing.T) {
+	h, store := newTestCacheHandler(t, 16)
+
+	w := serve(h.Put, "/cache/:key", http.MethodPut, "/cache/abc", []byte("streamed body"), -1)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", w.Code)
+	}
+	r, _, err := store.Get(t.Context(), "abc")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer r.Close()
+	if data, _ := io.ReadAll(r); string(data) != "streamed body" {
+		t.Fatalf("stored %q", data)
+	}
+}
+
+func TestPutRejectsOversizedStream(t *testing.T) {
+	h, store := newTestCacheHandler(t, 16)
+
+	w := serve(h.Put, "/cache/:key", http.MethodPut, "/cache/abc", []byte(strings.Repeat("x", 17)), -1)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", w.Code)
+	}
+	if ok, _ := store.Exists(t.Context(), "abc"); ok {
+		t.Fatal("oversized upload was stored")
+	}
+
+	w = serve(h.Put, "/cache/:key", http.MethodPut, "/cache/abc", []byte(strings.Repeat("x", 17)), 17)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status with Content-Length = %d, want 413", w.Code)
+	}
+}
+
+func TestDigestVerifier(t *testing.T) {
+	data := []byte("content")
+	sum := sha256.Sum256(data)
+
+	for _, size := range []int64{int64(len(data)), -1} {
+		got, err := io.ReadAll(NewDigestVerifier(bytes.NewReader(data), sum[:], size))
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("size %d: ReadAll = %q, %v", size, got, err)
+		}
+		_, err = io.ReadAll(NewDigestVerifier(strings.NewReader("tampered"), sum[:], size))
+		if err != ErrDigestMismatch {
+			t.Fatalf("size %d: mismatch error = %v, want ErrDigestMismatch", size, err)
+		}
+	}
+}
@@ -1,46 +1,94 @@
 package handler
 
 import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kevingruber/gradle-cache/internal/middleware"
 	"github.com/kevingruber/gradle-cache/internal/storage"
 	"github.com/rs/zerolog"
-	"io"
 )
 
 // CacheHandler handles Gradle build cache HTTP requests.
 type CacheHandler struct {
-	storage      storage.Storage
-	maxEntrySize int64
-	logger       zerolog.Logger
-	metrics      *Metrics
+	storage            storage.Storage
+	maxEntrySize       int64
+	fallbackNamespaces []string
+	logger             zerolog.Logger
+	metrics            *Metrics
+}
+
+// CacheHandlerConfig holds the settings of a cache handler.
+type CacheHandlerConfig struct {
+	MaxEntrySize int64
+	// FallbackNamespaces are read, in order, when an entry is missing from
+	// the namespace of a request. They are never written to.
+	FallbackNamespaces []string
 }
 
 // NewCacheHandler creates a new cache handler.
-func NewCacheHandler(store storage.Storage, maxEntrySize int64, logger zerolog.Logger) (*CacheHandler, error) {
+func NewCacheHandler(store storage.Storage, cfg CacheHandlerConfig, logger zerolog.Logger) (*CacheHandler, error) {
 	metrics, err := NewMetrics()
 	if err != nil {
 		return nil, err
 	}
 
 	return &CacheHandler{
-		storage:      store,
-		maxEntrySize: maxEntrySize,
-		logger:       logger,
-		metrics:      metrics,
+		storage:            store,
+		maxEntrySize:       cfg.MaxEntrySize,
+		fallbackNamespaces: cfg.FallbackNamespaces,
+		logger:             logger,
+		metrics:            metrics,
 	}, nil
 }
 
-// bytesReaderAt implements io.ReaderAt for a byte slice.
-type bytesReaderAt struct {
-	data []byte
+// store returns the storage scoped to the namespace of the request, or the
+// default storage if the request has no namespace. Namespaced reads fall
+// back to the configured fallback namespaces on a miss.
+func (h *CacheHandler) store(c *gin.Context) (storage.Storage, error) {
+	return ScopedStorage(h.storage, c.GetString(middleware.NamespaceKey), h.fallbackNamespaces)
 }
 
-func (b *bytesReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
-	if off >= int64(len(b.data)) {
-		return 0, io.EOF
+// ScopedStorage returns store scoped to namespace ns, reading from the
+// fallback namespaces on a miss. An empty ns selects the default namespace.
+func ScopedStorage(store storage.Storage, ns string, fallbackNamespaces []string) (storage.Storage, error) {
+	if ns == "" {
+		return store, nil
+	}
+	namespaced, ok := store.(storage.NamespacedStorage)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support namespaces")
+	}
+	primary := namespaced.WithNamespace(ns)
+
+	var fallbacks []storage.Storage
+	for _, fallback := range fallbackNamespaces {
+		if fallback != ns {
+			fallbacks = append(fallbacks, namespaced.WithNamespace(fallback))
+		}
+	}
+	if len(fallbacks) == 0 {
+		return primary, nil
 	}
-	n = copy(p, b.data[off:])
-	if n < len(p) {
-		err = io.EOF
+	return storage.NewFallbackStorage(primary, fallbacks...), nil
+}
+
+// reservedKeyPrefixes are the key prefixes of the other protocols sharing
+// the storage. Gradle keys with these prefixes are rejected, so that Gradle
+// clients cannot read or overwrite their entries.
+var reservedKeyPrefixes = []string{"bazel-", "turbo-", "nx-", "dav-"}
+
+// gradleKey returns the key of a Gradle request, or responds with 400 if the
+// key is reserved for another protocol.
+func gradleKey(c *gin.Context) (string, bool) {
+	key := c.Param("key")
+	for _, prefix := range reservedKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			c.Status(http.StatusBadRequest)
+			return "", false
+		}
 	}
-	return
+	return key, true
 }
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NxKey returns the storage key of an Nx task output.
+func NxKey(hash string) string {
+	return "nx-" + hash
+}
+
+// NxHandler implements Nx's self-hosted remote cache API on top of a
+// CacheHandler, sharing its storage, size limit and metrics.
+type NxHandler struct {
+	cache *CacheHandler
+}
+
+// NewNxHandler creates an Nx handler serving from cache.
+func NewNxHandler(cache *CacheHandler) *NxHandler {
+	return &NxHandler{cache: cache}
+}
+
+// Get handles GET requests for task outputs.
+// Nx expects: 200 with body on hit, 404 on miss.
+func (h *NxHandler) Get(c *gin.Context) {
+	if hash, ok := artifactHash(c); ok {
+		h.cache.get(c, NxKey(hash))
+	}
+}
+
+// Put handles PUT requests for task outputs. Nx does not overwrite records,
+// so uploads of an existing hash are rejected with 409.
+func (h *NxHandler) Put(c *gin.Context) {
+	hash, ok := artifactHash(c)
+	if !ok {
+		return
+	}
+	store, err := h.cache.store(c)
+	if err != nil {
+		h.cache.logger.Error().Err(err).Str("key", NxKey(hash)).Msg("failed to resolve cache namespace")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	exists, err := store.Exists(c.Request.Context(), NxKey(hash))
+	if err != nil {
+		h.cache.logger.Error().Err(err).Str("key", NxKey(hash)).Msg("failed to check cache entry existence")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if exists {
+		c.Status(http.StatusConflict)
+		return
+	}
+	h.cache.put(c, NxKey(hash), nil)
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNxRoundTrip(t *testing.T) {
+	cache, store := newTestCacheHandler(t, 1024)
+	h := NewNxHandler(cache)
+
+	if w := serve(h.Get, "/v1/cache/:hash", http.MethodGet, "/v1/cache/abc", nil, 0); w.Code != http.StatusNotFound {
+		t.Fatalf("GET before upload = %d, want 404", w.Code)
+	}
+	if w := serve(h.Put, "/v1/cache/:hash", http.MethodPut, "/v1/cache/abc", []byte("outputs"), 7); w.Code != http.StatusCreated {
+		t.Fatalf("PUT = %d, want 201", w.Code)
+	}
+	w := serve(h.Get, "/v1/cache/:hash", http.MethodGet, "/v1/cache/abc", nil, 0)
+	if w.Code != http.StatusOK || w.Body.String() != "outputs" {
+		t.Fatalf("GET = %d %q", w.Code, w.Body.String())
+	}
+	if ok, _ := store.Exists(t.Context(), NxKey("abc")); !ok {
+		t.Fatal("task output not stored under its key")
+	}
+}
+
+func TestNxDoesNotOverwrite(t *testing.T) {
+	cache, _ := newTestCacheHandler(t, 1024)
+	h := NewNxHandler(cache)
+
+	serve(h.Put, "/v1/cache/:hash", http.MethodPut, "/v1/cache/abc", []byte("first"), 5)
+	if w := serve(h.Put, "/v1/cache/:hash", http.MethodPut, "/v1/cache/abc", []byte("second"), 6); w.Code != http.StatusConflict {
+		t.Fatalf("second PUT = %d, want 409", w.Code)
+	}
+	if w := serve(h.Get, "/v1/cache/:hash", http.MethodGet, "/v1/cache/abc", nil, 0); w.Body.String() != "first" {
+		t.Fatalf("GET = %q, want the first upload", w.Body.String())
+	}
+}
+
+func TestNxRejectsInvalidHash(t *testing.T) {
+	cache, _ := newTestCacheHandler(t, 1024)
+	h := NewNxHandler(cache)
+
+	for _, hash := range []string{"a.b", "a~b"} {
+		if w := serve(h.Get, "/v1/cache/:hash", http.MethodGet, "/v1/cache/"+hash, nil, 0); w.Code != http.StatusBadRequest {
+			t.Fatalf("GET %s = %d, want 400", hash, w.Code)
+		}
+		if w := serve(h.Put, "/v1/cache/:hash", http.MethodPut, "/v1/cache/"+hash, []byte("x"), 1); w.Code != http.StatusBadRequest {
+			t.Fatalf("PUT %s = %d, want 400", hash, w.Code)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// turboTagHeader carries the signature of an artifact when Turborepo's
+// remote cache signing is enabled. It is stored with the artifact and
+// returned on reads.
+const turboTagHeader = "x-artifact-tag"
+
+// maxTurboTagSize bounds the stored signature of an artifact.
+const maxTurboTagSize = 1024
+
+// Artifacts are stored behind a header holding their signature: the magic,
+// the length of the tag as a big-endian uint16 and the tag, which may be
+// empty. Artifacts stored without the header are served without a tag.
+const (
+	turboTagMagic      = "GCTT\x01"
+	turboTagHeaderSize = len(turboTagMagic) + 2
+)
+
+// artifactHashPattern matches the task hashes of Turborepo and Nx.
+var artifactHashPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// TurboKey returns the storage key of a Turborepo artifact.
+func TurboKey(hash string) string {
+	return "turbo-" + hash
+}
+
+// TurboHandler implements Turborepo's remote cache API on top of a
+// CacheHandler, sharing its storage, size limit and metrics.
+type TurboHandler struct {
+	cache *CacheHandler
+}
+
+// NewTurboHandler creates a Turborepo handler serving from cache.
+func NewTurboHandler(cache *CacheHandler) *TurboHandler {
+	return &TurboHandler{cache: cache}
+}
+
+// Status handles GET /v8/artifacts/status, which Turborepo polls to check
+// that remote caching is enabled.
+func (h *TurboHandler) Status(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "enabled"})
+}
+
+// Events handles POST /v8/artifacts/events. Turborepo reports cache hits
+// and misses there; they are already counted by the cache metrics, so the
+// events are discarded.
+func (h *TurboHandler) Events(c *gin.Context) {
+	c.Status(http.StatusOK)
+}
+
+// Get handles GET requests for artifacts, sending their signature on a
+// hit.
+func (h *TurboHandler) Get(c *gin.Context) {
+	if hash, ok := artifactHash(c); ok {
+		h.cache.getWithPrefix(c, TurboKey(hash), readTurboTag)
+	}
+}
+
+// Head handles HEAD requests for artifacts.
+func (h *TurboHandler) Head(c *gin.Context) {
+	if hash, ok := artifactHash(c); ok {
+		h.cache.headWithPrefix(c, TurboKey(hash))
+	}
+}
+
+// Put handles PUT requests for artifacts. The signature of the artifact,
+// if any, is stored in front of the artifact.
+func (h *TurboHandler) Put(c *gin.Context) {
+	hash, ok := artifactHash(c)
+	if !ok {
+		return
+	}
+	tag := c.GetHeader(turboTagHeader)
+	if len(tag) > maxTurboTagSize {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	h.cache.putWithPrefix(c, TurboKey(hash), turboTagPrefix(tag), nil)
+}
+
+// turboTagPrefix returns the header that stores tag with an artifact.
+func turboTagPrefix(tag string) []byte {
+	prefix := make([]byte, 0, turboTagHeaderSize+len(tag))
+	prefix = append(prefix, turboTagMagic...)
+	prefix = binary.BigEndian.AppendUint16(prefix, uint16(len(tag)))
+	return append(prefix, tag...)
+}
+
+// readTurboTag consumes the header of an artifact and sends its tag.
+func readTurboTag(c *gin.Context, r *bufio.Reader) (int64, error) {
+	header, err := r.Peek(turboTagHeaderSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	if !bytes.HasPrefix(header, []byte(turboTagMagic)) || len(header) < turboTagHeaderSize {
+		return 0, nil
+	}
+	n := int(binary.BigEndian.Uint16(header[len(turboTagMagic):]))
+	r.Discard(turboTagHeaderSize)
+	tag := make([]byte, n)
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return 0, fmt.Errorf("read artifact tag: %w", err)
+	}
+	if n > 0 {
+		c.Header(turboTagHeader, string(tag))
+	}
+	return int64(turboTagHeaderSize + n), nil
+}
+
+func artifactHash(c *gin.Context) (string, bool) {
+	hash := c.Param("hash")
+	if !artifactHashPattern.MatchString(hash) {
+		c.Status(http.StatusBadRequest)
+		return "", false
+	}
+	return hash, true
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kevingruber/gradle-cache/internal/storage"
+	"github.com/rs/zerolog"
+)
+
+// serveTurbo runs a Turborepo request against h, with the given signature
+// if tag is not empty.
+func serveTurbo(h *TurboHandler, method, path string, body []byte, tag string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/v8/artifacts/:hash", h.Get)
+	r.HEAD("/v8/artifacts/:hash", h.Head)
+	r.PUT("/v8/artifacts/:hash", h.Put)
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	if tag != "" {
+		req.Header.Set(turboTagHeader, tag)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestTurboStoresTagWithArtifact(t *testing.T) {
+	cache, store := newTestCacheHandler(t, 1024)
+	h := NewTurboHandler(cache)
+
+	if w := serveTurbo(h, http.MethodPut, "/v8/artifacts/abc", []byte("artifact"), "signature"); w.Code != http.StatusCreated {
+		t.Fatalf("PUT = %d, want 201", w.Code)
+	}
+	w := serveTurbo(h, http.MethodGet, "/v8/artifacts/abc", nil, "")
+	if w.Code != http.StatusOK || w.Body.String() != "artifact" {
+		t.Fatalf("GET = %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get(turboTagHeader); got != "signature" {
+		t.Fatalf("tag = %q, want %q", got, "signature")
+	}
+	if got := w.Header().Get("Content-Length"); got != "8" {
+		t.Fatalf("Content-Length = %q, want the artifact size", got)
+	}
+	if ok, _ := store.Exists(t.Context(), TurboKey("abc")+".tag"); ok {
+		t.Fatal("tag stored as a separate entry")
+	}
+
+	// Uploading again without a signature drops the old one.
+	serveTurbo(h, http.MethodPut, "/v8/artifacts/abc", []byte("unsigned"), "")
+	w = serveTurbo(h, http.MethodGet, "/v8/artifacts/abc", nil, "")
+	if w.Body.String() != "unsigned" || w.Header().Get(turboTagHeader) != "" {
+		t.Fatalf("GET = %q with tag %q, want the unsigned artifact", w.Body.String(), w.Header().Get(turboTagHeader))
+	}
+}
+
+func TestTurboTagOnlyOnHit(t *testing.T) {
+	cache, _ := newTestCacheHandler(t, 1024)
+	h := NewTurboHandler(cache)
+
+	w := serveTurbo(h, http.MethodGet, "/v8/artifacts/missing", nil, "")
+	if w.Code != http.StatusNotFound || w.Header().Get(turboTagHeader) != "" {
+		t.Fatalf("GET of missing artifact = %d with tag %q", w.Code, w.Header().Get(turboTagHeader))
+	}
+}
+
+func TestTurboServesLegacyArtifacts(t *testing.T) {
+	cache, store := newTestCacheHandler(t, 1024)
+	h := NewTurboHandler(cache)
+	for key, data := range map[string]string{"legacy": "\x1f\x8b old artifact", "tiny": "x"} {
+		if err := store.Put(t.Context(), TurboKey(key), bytes.NewReader([]byte(data)), int64(len(data))); err != nil {
+			t.Fatal(err)
+		}
+		w := serveTurbo(h, http.MethodGet, "/v8/artifacts/"+key, nil, "")
+		if w.Code != http.StatusOK || w.Body.String() != data || w.Header().Get(turboTagHeader) != "" {
+			t.Fatalf("GET %s = %d %q with tag %q", key, w.Code, w.Body.String(), w.Header().Get(turboTagHeader))
+		}
+	}
+}
+
+func TestTurboRejectsInvalidRequests(t *testing.T) {
+	cache, _ := newTestCacheHandler(t, 1024)
+	h := NewTurboHandler(cache)
+
+	if w := serveTurbo(h, http.MethodPut, "/v8/artifacts/a.b", []byte("x"), ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("PUT with invalid hash = %d, want 400", w.Code)
+	}
+	tag := string(bytes.Repeat([]byte("t"), maxTurboTagSize+1))
+	if w := serveTurbo(h, http.MethodPut, "/v8/artifacts/abc", []byte("x"), tag); w.Code != http.StatusBadRequest {
+		t.Fatalf("PUT with oversized tag = %d, want 400", w.Code)
+	}
+}
+
+func TestTurboHeadOmitsStoredDigest(t *testing.T) {
+	backend, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := storage.NewChecksumStorage(backend, storage.ChecksumConfig{Verify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := NewCacheHandler(store, CacheHandlerConfig{MaxEntrySize: 1024}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewTurboHandler(cache)
+
+	serveTurbo(h, http.MethodPut, "/v8/artifacts/abc", []byte("artifact"), "signature")
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		w := serveTurbo(h, method, "/v8/artifacts/abc", nil, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s = %d, want 200", method, w.Code)
+		}
+		// The stored digest covers the tag header, not the artifact.
+		if w.Header().Get("ETag") != "" || w.Header().Get("Digest") != "" {
+			t.Fatalf("%s sent the digest of the stored entry", method)
+		}
+	}
+	w := serveTurbo(h, http.MethodGet, "/v8/artifacts/abc", nil, "")
+	if data, _ := io.ReadAll(w.Body); string(data) != "artifact" {
+		t.Fatalf("GET = %q through the checksum layer", data)
+	}
+}
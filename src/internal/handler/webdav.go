@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kevingruber/gradle-cache/internal/storage"
+)
+
+// WebDAV methods besides those of plain HTTP.
+const (
+	MethodPropfind = "PROPFIND"
+	MethodMkcol    = "MKCOL"
+)
+
+// maxWebDAVPathLength bounds the path of a WebDAV file.
+const maxWebDAVPathLength = 1024
+
+// WebDAVKey returns the storage key of the WebDAV file at path p, which is
+// relative to the WebDAV root.
+func WebDAVKey(p string) string {
+	return "dav-" + p
+}
+
+// WebDAVHandler implements the subset of WebDAV that sccache and ccache use
+// for their remote storage on top of a CacheHandler, sharing its storage,
+// size limit and metrics. Files are stored as flat keys; collections are
+// implicit and always exist, so listing them is not supported.
+type WebDAVHandler struct {
+	cache *CacheHandler
+}
+
+// NewWebDAVHandler creates a WebDAV handler serving from cache.
+func NewWebDAVHandler(cache *CacheHandler) *WebDAVHandler {
+	return &WebDAVHandler{cache: cache}
+}
+
+// Options handles OPTIONS requests, which clients use to detect WebDAV.
+func (h *WebDAVHandler) Options(c *gin.Context) {
+	c.Header("DAV", "1")
+	c.Header("Allow", "OPTIONS, GET, HEAD, PUT, PROPFIND, MKCOL")
+	c.Status(http.StatusOK)
+}
+
+// Get handles GET requests for files.
+func (h *WebDAVHandler) Get(c *gin.Context) {
+	if p, ok := webDAVFile(c); ok {
+		h.cache.get(c, WebDAVKey(p))
+	}
+}
+
+// Head handles HEAD requests for files.
+func (h *WebDAVHandler) Head(c *gin.Context) {
+	if p, ok := webDAVFile(c); ok {
+		h.cache.head(c, WebDAVKey(p))
+	}
+}
+
+// Put handles PUT requests for files.
+func (h *WebDAVHandler) Put(c *gin.Context) {
+	if p, ok := webDAVFile(c); ok {
+		h.cache.put(c, WebDAVKey(p), nil)
+	}
+}
+
+// Mkcol handles MKCOL requests. Collections are implicit, so creating one
+// succeeds unless a file already exists at its path.
+func (h *WebDAVHandler) Mkcol(c *gin.Context) {
+	p, _, ok := webDAVPath(c)
+	if !ok {
+		return
+	}
+	if p != "" {
+		store, err := h.cache.store(c)
+		if err != nil {
+			h.cache.logger.Error().Err(err).Str("key", WebDAVKey(p)).Msg("failed to resolve cache namespace")
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		exists, err := store.Exists(c.Request.Context(), WebDAVKey(p))
+		if err != nil {
+			h.cache.logger.Error().Err(err).Str("key", WebDAVKey(p)).Msg("failed to check cache entry")
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		if exists {
+			c.Status(http.StatusMethodNotAllowed)
+			return
+		}
+	}
+	c.Status(http.StatusCreated)
+}
+
+// Propfind handles PROPFIND requests with the properties of a single file
+// or collection. Collections cannot be listed, so requests for them must
+// have a Depth of 0. The size and modification time of files come from
+// storage.Stat, without reading their content.
+func (h *WebDAVHandler) Propfind(c *gin.Context) {
+	p, collection, ok := webDAVPath(c)
+	if !ok {
+		return
+	}
+	var prop davProp
+	if collection {
+		if c.GetHeader("Depth") != "0" {
+			c.Status(http.StatusForbidden)
+			return
+		}
+		prop.ResourceType.Collection = &struct{}{}
+	} else {
+		store, err := h.cache.store(c)
+		if err != nil {
+			h.cache.logger.Error().Err(err).Str("key", WebDAVKey(p)).Msg("failed to resolve cache namespace")
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		info, err := storage.Stat(c.Request.Context(), store, WebDAVKey(p))
+		if errors.Is(err, storage.ErrNotFound) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			h.cache.logger.Error().Err(err).Str("key", WebDAVKey(p)).Msg("failed to stat cache entry")
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		if info.Size >= 0 {
+			prop.ContentLength = strconv.FormatInt(info.Size, 10)
+		}
+		if !info.ModTime.IsZero() {
+			prop.LastModified = info.ModTime.UTC().Format(http.TimeFormat)
+		}
+		prop.ContentType = "application/octet-stream"
+	}
+
+	c.XML(http.StatusMultiStatus, davMultiStatus{Namespace: "DAV:", Responses: []davResponse{{
+		Href: c.Request.URL.EscapedPath(),
+		PropStat: davPropStat{
+			Prop:   prop,
+			Status: "HTTP/1.1 200 OK",
+		},
+	}}})
+}
+
+// webDAVPath returns the path of a request relative to the WebDAV root and
+// whether it names a collection, which it does if it ends with a slash.
+func webDAVPath(c *gin.Context) (string, bool, bool) {
+	raw := c.Param("path")
+	p := strings.TrimPrefix(path.Clean("/"+raw), "/")
+	if len(p) > maxWebDAVPathLength {
+		c.Status(http.StatusBadRequest)
+		return "", false, false
+	}
+	return p, p == "" || strings.HasSuffix(raw, "/"), true
+}
+
+// webDAVFile returns the path of a request for a file, rejecting requests
+// for collections.
+func webDAVFile(c *gin.Context) (string, bool) {
+	p, collection, ok := webDAVPath(c)
+	if !ok {
+		return "", false
+	}
+	if collection {
+		c.Status(http.StatusMethodNotAllowed)
+		return "", false
+	}
+	return p, true
+}
+
+// WebDAV multistatus response, as returned by PROPFIND.
+type davMultiStatus struct {
+	XMLName   xml.Name      `xml:"D:multistatus"`
+	Namespace string        `xml:"xmlns:D,attr"`
+	Responses []davResponse `xml:"D:response"`
+}
+
+type davResponse struct {
+	Href     string      `xml:"D:href"`
+	PropStat davPropStat `xml:"D:propstat"`
+}
+
+type davPropStat struct {
+	Prop   davProp `xml:"D:prop"`
+	Status string  `xml:"D:status"`
+}
+
+type davProp struct {
+	ResourceType struct {
+		Collection *struct{} `xml:"D:collection"`
+	} `xml:"D:resourcetype"`
+	ContentLength string `xml:"D:getcontentlength,omitempty"`
+	ContentType   string `xml:"D:getcontenttype,omitempty"`
+	LastModified  string `xml:"D:getlastmodified,omitempty"`
+}
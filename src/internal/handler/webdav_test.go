@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serveWebDAV(h *WebDAVHandler, method, path string, body []byte, depth string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/dav/*path", h.Get)
+	r.PUT("/dav/*path", h.Put)
+	r.Handle(MethodPropfind, "/dav/*path", h.Propfind)
+	r.Handle(MethodMkcol, "/dav/*path", h.Mkcol)
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	if depth != "" {
+		req.Header.Set("Depth", depth)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestWebDAVRoundTrip(t *testing.T) {
+	cache, store := newTestCacheHandler(t, 1024)
+	h := NewWebDAVHandler(cache)
+
+	if w := serveWebDAV(h, http.MethodPut, "/dav/a/b/obj", []byte("object file"), ""); w.Code != http.StatusCreated {
+		t.Fatalf("PUT status = %d, want 201", w.Code)
+	}
+	w := serveWebDAV(h, http.MethodGet, "/dav/a/b/obj", nil, "")
+	if w.Code != http.StatusOK || w.Body.String() != "object file" {
+		t.Fatalf("GET = %d %q", w.Code, w.Body.String())
+	}
+	if exists, _ := store.Exists(t.Context(), WebDAVKey("a/b/obj")); !exists {
+		t.Fatal("file is not stored under its WebDAV key")
+	}
+	if w := serveWebDAV(h, http.MethodPut, "/dav/a/", []byte("x"), ""); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("PUT of a collection status = %d, want 405", w.Code)
+	}
+}
+
+func TestWebDAVPropfindFile(t *testing.T) {
+	cache, _ := newTestCacheHandler(t, 1024)
+	h := NewWebDAVHandler(cache)
+	serveWebDAV(h, http.MethodPut, "/dav/obj", []byte("object file"), "")
+
+	w := serveWebDAV(h, MethodPropfind, "/dav/obj", nil, "")
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("status = %d, want 207", w.Code)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "<D:getcontentlength>11</D:getcontentlength>") {
+		t.Fatalf("response lacks the content length: %s", body)
+	}
+	start := strings.Index(body, "<D:getlastmodified>")
+	end := strings.Index(body, "</D:getlastmodified>")
+	if start < 0 || end < start {
+		t.Fatalf("response lacks the modification time: %s", body)
+	}
+	modified, err := http.ParseTime(body[start+len("<D:getlastmodified>") : end])
+	if err != nil || time.Since(modified) > time.Minute {
+		t.Fatalf("modification time = %v, %v", modified, err)
+	}
+
+	if w := serveWebDAV(h, MethodPropfind, "/dav/missing", nil, "0"); w.Code != http.StatusNotFound {
+		t.Fatalf("PROPFIND of a missing file status = %d, want 404", w.Code)
+	}
+}
+
+func TestWebDAVPropfindCollectionDepth(t *testing.T) {
+	cache, _ := newTestCacheHandler(t, 1024)
+	h := NewWebDAVHandler(cache)
+
+	tests := []struct {
+		depth string
+		want  int
+	}{
+		{"0", http.StatusMultiStatus},
+		{"1", http.StatusForbidden},
+		{"infinity", http.StatusForbidden},
+		{"", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		w := serveWebDAV(h, MethodPropfind, "/dav/a/", nil, tt.depth)
+		if w.Code != tt.want {
+			t.Fatalf("Depth %q: status = %d, want %d", tt.depth, w.Code, tt.want)
+		}
+		if w.Code == http.StatusMultiStatus {
+			if body := w.Body.String(); !strings.Contains(body, "<D:collection>") || strings.Contains(body, "getlastmodified") {
+				t.Fatalf("Depth %q: unexpected properties: %s", tt.depth, body)
+			}
+		}
+	}
+}
+
+func TestWebDAVMkcol(t *testing.T) {
+	cache, _ := newTestCacheHandler(t, 1024)
+	h := NewWebDAVHandler(cache)
+	serveWebDAV(h, http.MethodPut, "/dav/a/obj", []byte("object file"), "")
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/dav/", http.StatusCreated},
+		{"/dav/a/", http.StatusCreated},
+		{"/dav/b/c/", http.StatusCreated},
+		{"/dav/a/obj", http.StatusMethodNotAllowed},
+		{"/dav/a/obj/", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if w := serveWebDAV(h, MethodMkcol, tt.path, nil, ""); w.Code != tt.want {
+			t.Fatalf("MKCOL %s status = %d, want %d", tt.path, w.Code, tt.want)
+		}
+	}
+}
@@ -2,40 +2,323 @@ package middleware
 
 import (
 	"crypto/subtle"
+	"fmt"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/kevingruber/gradle-cache/internal/config"
+	"github.com/rs/zerolog"
 )
 
-// CacheAuth creates a middleware that validates HTTP Basic Authentication
-func CacheAuth(auth config.AuthConfig, requireWriter bool) gin.HandlerFunc {
+// Context keys set by CacheAuth.
+const (
+	IdentityKey = "identity"
+	UsernameKey = "username"
+)
 
-	return func(c *gin.Context) {
-		username, password, ok := c.Request.BasicAuth()
-		if !ok {
-			c.Header("WWW-Authenticate", `Basic realm="Gradle Build Cache"`)
-			c.AbortWithStatus(http.StatusUnauthorized)
-			return
+// Role is a permission level. Each role includes the permissions of the
+// roles below it: admin > write > read.
+type Role string
+
+const (
+	RoleRead  Role = config.RoleRead
+	RoleWrite Role = config.RoleWrite
+	RoleAdmin Role = config.RoleAdmin
+)
+
+func (r Role) level() int {
+	switch r {
+	case RoleRead:
+		return 1
+	case RoleWrite:
+		return 2
+	case RoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+// Identity describes an authenticated client.
+type Identity struct {
+	Username string
+	Roles    []Role
+	// Namespaces restricts the client to these namespaces. Empty allows all.
+	Namespaces []string
+}
+
+// HasRole reports whether the identity holds role or a role that includes it.
+func (i *Identity) HasRole(role Role) bool {
+	for _, r := range i.Roles {
+		if r.level() >= role.level() {
+			return true
+		}
+	}
+	return false
+}
+
+// Role returns the highest role held by the identity.
+func (i *Identity) Role() Role {
+	var highest Role
+	for _, r := range i.Roles {
+		if r.level() > highest.level() {
+			highest = r
 		}
+	}
+	return highest
+}
 
-		// Check credentials
-		isReader := username == auth.Reader.Username &&
-			subtle.ConstantTimeCompare([]byte(password), []byte(auth.Reader.Password)) == 1
-		isWriter := username == auth.Writer.Username &&
-			subtle.ConstantTimeCompare([]byte(password), []byte(auth.Writer.Password)) == 1
+// AllowsNamespace reports whether the identity may access namespace ns.
+func (i *Identity) AllowsNamespace(ns string) bool {
+	return len(i.Namespaces) == 0 || slices.Contains(i.Namespaces, ns)
+}
 
-		if !isReader && !isWriter {
-			c.Header("WWW-Authenticate", `Basic realm="Gradle Build Cache"`)
-			c.AbortWithStatus(http.StatusUnauthorized)
-			return
+// GetIdentity returns the identity CacheAuth stored in the context, if any.
+func GetIdentity(c *gin.Context) *Identity {
+	if v, ok := c.Get(IdentityKey); ok {
+		return v.(*Identity)
+	}
+	return nil
+}
+
+type user struct {
+	password []byte
+	identity *Identity
+}
+
+// Authenticator verifies client credentials against the configured users,
+// bearer tokens, JWTs and client certificate rules.
+type Authenticator struct {
+	users     map[string]user
+	tokens    []*token
+	jwt       *jwtValidator
+	certRules []*certRule
+	// htpasswd supplies passwords for users configured without one and
+	// authenticates its other entries with htpasswdRoles.
+	htpasswd      *htpasswd
+	htpasswdRoles []Role
+	// lockout throttles clients after repeated failures. Nil if disabled.
+	lockout *lockout
+}
+
+// NewAuthenticator creates an authenticator for the credentials in cfg.
+func NewAuthenticator(cfg config.AuthConfig, logger zerolog.Logger) (*Authenticator, error) {
+	a := &Authenticator{users: make(map[string]user)}
+	for _, u := range cfg.AllUsers() {
+		roles := make([]Role, len(u.Roles))
+		for i, r := range u.Roles {
+			roles[i] = Role(r)
+		}
+		a.users[u.Username] = user{
+			password: []byte(u.Password),
+			identity: &Identity{
+				Username:   u.Username,
+				Roles:      roles,
+				Namespaces: u.Namespaces,
+			},
+		}
+	}
+	for _, t := range cfg.Tokens {
+		salt, digest, err := parseTokenHash(t.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("auth token %q: %w", t.Name, err)
+		}
+		expiresAt, err := t.Expiry()
+		if err != nil {
+			return nil, fmt.Errorf("auth token %q: invalid expires_at: %w", t.Name, err)
+		}
+		a.tokens = append(a.tokens, &token{
+			salt:      salt,
+			digest:    digest,
+			expiresAt: expiresAt,
+			identity: &Identity{
+				Username:   "token:" + t.Name,
+				Roles:      []Role{Role(t.Role)},
+				Namespaces: t.Namespaces,
+			},
+		})
+	}
+	if cfg.HtpasswdFile != "" {
+		h, err := newHtpasswd(cfg.HtpasswdFile, logger)
+		if err != nil {
+			return nil, err
+		}
+		a.htpasswd = h
+		for _, r := range cfg.HtpasswdRoles {
+			a.htpasswdRoles = append(a.htpasswdRoles, Role(r))
+		}
+	}
+	if cfg.Lockout.Enabled {
+		l, err := newLockout(cfg.Lockout)
+		if err != nil {
+			return nil, err
+		}
+		a.lockout = l
+	}
+	for _, rule := range cfg.Certificates {
+		a.certRules = append(a.certRules, newCertRule(rule))
+	}
+	if cfg.JWT.Enabled {
+		validator, err := newJWTValidator(cfg.JWT, logger)
+		if err != nil {
+			return nil, err
+		}
+		a.jwt = validator
+	}
+	return a, nil
+}
+
+// Close stops the periodic reload of the JWT signing keys.
+func (a *Authenticator) Close() error {
+	if a.jwt != nil {
+		a.jwt.keys.close()
+	}
+	return nil
+}
+
+// Authenticate returns the identity for the credentials of r, or nil if
+// they are missing or invalid. HTTP Basic, bearer tokens and JWTs are
+// accepted; requests without an Authorization header may authenticate with
+// a verified TLS client certificate.
+func (a *Authenticator) Authenticate(r *http.Request) *Identity {
+	if r.Header.Get("Authorization") == "" {
+		return a.authenticateCertificate(r)
+	}
+	if presented, ok := bearerToken(r); ok {
+		if a.jwt != nil && looksLikeJWT(presented) {
+			return a.jwt.authenticate(presented)
 		}
+		return a.authenticateToken(presented)
+	}
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil
+	}
+	u, ok := a.users[username]
+	if ok && len(u.password) > 0 {
+		if subtle.ConstantTimeCompare([]byte(password), u.password) != 1 {
+			return nil
+		}
+		return u.identity
+	}
+	if a.htpasswd == nil || !a.htpasswd.verify(username, password) {
+		return nil
+	}
+	if ok {
+		return u.identity
+	}
+	return &Identity{Username: username, Roles: a.htpasswdRoles}
+}
 
-		if requireWriter && !isWriter {
-			c.AbortWithStatus(http.StatusForbidden)
-			return
+// authenticateToken checks every token so the time taken does not reveal
+// which one matched.
+func (a *Authenticator) authenticateToken(presented string) *Identity {
+	var match *token
+	for _, t := range a.tokens {
+		if t.matches(presented) {
+			match = t
 		}
+	}
+	if match == nil || match.expired(time.Now()) {
+		return nil
+	}
+	return match.identity
+}
 
-		c.Next()
+// bearerToken returns the token from an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(auth[len(prefix):]), true
+}
+
+// Decision is the outcome of Authorize.
+type Decision struct {
+	// Identity is the authenticated client, if any.
+	Identity *Identity
+	// Namespace is the namespace the request is served from.
+	Namespace string
+	// Status is http.StatusOK if the request is allowed, or the status to
+	// reject it with.
+	Status int
+	// RetryAfter is set for clients that are locked out.
+	RetryAfter time.Duration
+}
+
+// Authorize authenticates r and checks that the client holds role and may
+// access namespace ns. Clients restricted to namespaces may only access
+// those; requests without a namespace use their first one. Failures count
+// towards the lockout of clientIP and the presented username.
+func (a *Authenticator) Authorize(r *http.Request, clientIP string, role Role, ns string) Decision {
+	var keys []lockoutKey
+	if a.lockout != nil {
+		username, _, _ := r.BasicAuth()
+		keys = lockoutKeys(clientIP, username)
+		if wait := a.lockout.retryAfter(keys); wait > 0 {
+			return Decision{Status: http.StatusTooManyRequests, RetryAfter: wait}
+		}
+	}
+
+	identity := a.Authenticate(r)
+	if a.lockout != nil {
+		// Requests without credentials are not guesses and are not counted.
+		if identity == nil && r.Header.Get("Authorization") != "" {
+			a.lockout.fail(r.Context(), keys)
+		} else if identity != nil {
+			a.lockout.succeed(keys)
+		}
+	}
+	if identity == nil {
+		return Decision{Status: http.StatusUnauthorized}
+	}
+	if !identity.HasRole(role) {
+		return Decision{Identity: identity, Status: http.StatusForbidden}
+	}
+
+	if ns == "" && len(identity.Namespaces) > 0 {
+		ns = identity.Namespaces[0]
+	}
+	if !identity.AllowsNamespace(ns) {
+		return Decision{Identity: identity, Namespace: ns, Status: http.StatusForbidden}
+	}
+	return Decision{Identity: identity, Namespace: ns, Status: http.StatusOK}
+}
+
+// CacheAuth creates a middleware that validates HTTP Basic or bearer token
+// authentication and requires the given role, as described for Authorize.
+// Clients locked out after repeated failures get 429 with Retry-After.
+func CacheAuth(authn *Authenticator, role Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d := authn.Authorize(c.Request, c.ClientIP(), role, c.GetString(NamespaceKey))
+		if d.Identity != nil {
+			c.Set(IdentityKey, d.Identity)
+			c.Set(UsernameKey, d.Identity.Username)
+		}
+		if d.Namespace != "" {
+			c.Set(NamespaceKey, d.Namespace)
+		}
+
+		switch d.Status {
+		case http.StatusOK:
+			c.Next()
+		case http.StatusTooManyRequests:
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(d.RetryAfter.Seconds()))))
+			c.AbortWithStatus(http.StatusTooManyRequests)
+		case http.StatusUnauthorized:
+			c.Header("WWW-Authenticate", `Basic realm="Gradle Build Cache"`)
+			if len(authn.tokens) > 0 || authn.jwt != nil {
+				c.Writer.Header().Add("WWW-Authenticate", `Bearer realm="Gradle Build Cache"`)
+			}
+			c.AbortWithStatus(http.StatusUnauthorized)
+		default:
+			c.AbortWithStatus(d.Status)
+		}
 	}
 }
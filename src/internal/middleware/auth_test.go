@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kevingruber/gradle-cache/internal/config"
+	"github.com/rs/zerolog"
+)
+
+func newTestAuthenticator(t *testing.T, cfg config.AuthConfig) *Authenticator {
+	t.Helper()
+	authn, err := NewAuthenticator(cfg, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewAuthenticator() = %v", err)
+	}
+	return authn
+}
+
+// authorize runs Authorize for a request with the given Basic credentials.
+// An empty username sends no Authorization header.
+func authorize(authn *Authenticator, username, password string, role Role, ns string) Decision {
+	req := httptest.NewRequest(http.MethodGet, "/cache/key", nil)
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	return authn.Authorize(req, "192.0.2.1", role, ns)
+}
+
+func TestAuthorizeBasic(t *testing.T) {
+	authn := newTestAuthenticator(t, config.AuthConfig{
+		Users: []config.UserAuth{
+			{Username: "reader", Password: "pa$$word", Roles: []string{config.RoleRead}},
+			{Username: "writer", Password: "secret", Roles: []string{config.RoleWrite}},
+		},
+	})
+
+	tests := []struct {
+		name               string
+		username, password string
+		role               Role
+		want               int
+	}{
+		{"reader reads", "reader", "pa$$word", RoleRead, http.StatusOK},
+		{"reader writes", "reader", "pa$$word", RoleWrite, http.StatusForbidden},
+		{"writer reads", "writer", "secret", RoleRead, http.StatusOK},
+		{"writer administers", "writer", "secret", RoleAdmin, http.StatusForbidden},
+		{"wrong password", "writer", "guess", RoleRead, http.StatusUnauthorized},
+		{"unknown user", "nobody", "secret", RoleRead, http.StatusUnauthorized},
+		{"no credentials", "", "", RoleRead, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := authorize(authn, tt.username, tt.password, tt.role, "")
+			if d.Status != tt.want {
+				t.Fatalf("Status = %d, want %d", d.Status, tt.want)
+			}
+			if tt.want != http.StatusUnauthorized && d.Identity.Username != tt.username {
+				t.Fatalf("Identity = %q, want %q", d.Identity.Username, tt.username)
+			}
+		})
+	}
+}
+
+func TestAuthorizeNamespaces(t *testing.T) {
+	authn := newTestAuthenticator(t, config.AuthConfig{
+		Users: []config.UserAuth{{
+			Username:   "exercise",
+			Password:   "secret",
+			Roles:      []string{config.RoleWrite},
+			Namespaces: []string{"exercise-1", "exercise-2"},
+		}},
+	})
+
+	if d := authorize(authn, "exercise", "secret", RoleRead, "exercise-2"); d.Status != http.StatusOK {
+		t.Fatalf("allowed namespace: Status = %d", d.Status)
+	}
+	if d := authorize(authn, "exercise", "secret", RoleRead, "other"); d.Status != http.StatusForbidden {
+		t.Fatalf("foreign namespace: Status = %d, want 403", d.Status)
+	}
+	d := authorize(authn, "exercise", "secret", RoleRead, "")
+	if d.Status != http.StatusOK || d.Namespace != "exercise-1" {
+		t.Fatalf("no namespace: Status = %d, Namespace = %q, want 200 from exercise-1", d.Status, d.Namespace)
+	}
+}
+
+func TestCacheAuthChallenges(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authn := newTestAuthenticator(t, config.AuthConfig{
+		Users: []config.UserAuth{{Username: "writer", Password: "secret", Roles: []string{config.RoleWrite}}},
+	})
+	r := gin.New()
+	r.GET("/cache/:key", CacheAuth(authn, RoleRead), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString(UsernameKey))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cache/key", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Code = %d, want 401", w.Code)
+	}
+	if got := w.Header().Get("WWW-Authenticate"); got != `Basic realm="Gradle Build Cache"` {
+		t.Fatalf("WWW-Authenticate = %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/cache/key", nil)
+	req.SetBasicAuth("writer", "secret")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "writer" {
+		t.Fatalf("Code = %d, body = %q, want 200 for writer", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"crypto/x509"
+	"net/http"
+	"path"
+
+	"github.com/kevingruber/gradle-cache/internal/config"
+)
+
+// certRule maps client certificates matching all of its patterns to an identity.
+type certRule struct {
+	commonName string
+	dnsName    string
+	uri        string
+	email      string
+	roles      []Role
+	namespaces []string
+}
+
+func newCertRule(cfg config.CertificateRule) *certRule {
+	roles := make([]Role, len(cfg.Roles))
+	for i, r := range cfg.Roles {
+		roles[i] = Role(r)
+	}
+	return &certRule{
+		commonName: cfg.CommonName,
+		dnsName:    cfg.DNSName,
+		uri:        cfg.URI,
+		email:      cfg.Email,
+		roles:      roles,
+		namespaces: cfg.Namespaces,
+	}
+}
+
+func (r *certRule) matches(cert *x509.Certificate) bool {
+	if r.commonName != "" && !globMatch(r.commonName, cert.Subject.CommonName) {
+		return false
+	}
+	if r.dnsName != "" && !anyMatch(r.dnsName, cert.DNSNames) {
+		return false
+	}
+	if r.uri != "" {
+		uris := make([]string, len(cert.URIs))
+		for i, u := range cert.URIs {
+			uris[i] = u.String()
+		}
+		if !anyMatch(r.uri, uris) {
+			return false
+		}
+	}
+	if r.email != "" && !anyMatch(r.email, cert.EmailAddresses) {
+		return false
+	}
+	return true
+}
+
+func globMatch(pattern, value string) bool {
+	ok, _ := path.Match(pattern, value)
+	return ok
+}
+
+func anyMatch(pattern string, values []string) bool {
+	for _, v := range values {
+		if globMatch(pattern, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticateCertificate returns the identity of the first rule matching
+// the verified client certificate of r, or nil.
+func (a *Authenticator) authenticateCertificate(r *http.Request) *Identity {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	for _, rule := range a.certRules {
+		if rule.matches(cert) {
+			return &Identity{
+				Username:   "cert:" + cert.Subject.CommonName,
+				Roles:      rule.roles,
+				Namespaces: rule.namespaces,
+			}
+		}
+	}
+	return nil
+}
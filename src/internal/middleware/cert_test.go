@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/kevingruber/gradle-cache/internal/config"
+)
+
+// authorizeCertificate runs Authorize for a request presenting cert as its
+// verified client certificate. A nil cert sends a request without TLS.
+func authorizeCertificate(authn *Authenticator, cert *x509.Certificate, role Role) Decision {
+	req := httptest.NewRequest(http.MethodGet, "/cache/key", nil)
+	if cert != nil {
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+	return authn.Authorize(req, "192.0.2.1", role, "")
+}
+
+func TestAuthorizeCertificate(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.com/ci/runner")
+	authn := newTestAuthenticator(t, config.AuthConfig{
+		Certificates: []config.CertificateRule{
+			{CommonName: "ci-*", DNSName: "*.ci.example.com", Roles: []string{config.RoleWrite}},
+			{URI: "spiffe://example.com/ci/*", Roles: []string{config.RoleRead}, Namespaces: []string{"ci"}},
+			{Email: "*@example.com", Roles: []string{config.RoleRead}},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		cert     *x509.Certificate
+		role     Role
+		want     int
+		wantUser string
+	}{
+		{
+			name:     "common name and DNS name",
+			cert:     &x509.Certificate{Subject: pkix.Name{CommonName: "ci-1"}, DNSNames: []string{"runner.ci.example.com"}},
+			role:     RoleWrite,
+			want:     http.StatusOK,
+			wantUser: "cert:ci-1",
+		},
+		{
+			name: "common name without DNS name",
+			cert: &x509.Certificate{Subject: pkix.Name{CommonName: "ci-1"}},
+			role: RoleRead,
+			want: http.StatusUnauthorized,
+		},
+		{
+			name:     "URI",
+			cert:     &x509.Certificate{Subject: pkix.Name{CommonName: "runner"}, URIs: []*url.URL{spiffe}},
+			role:     RoleRead,
+			want:     http.StatusOK,
+			wantUser: "cert:runner",
+		},
+		{
+			name: "URI with insufficient role",
+			cert: &x509.Certificate{Subject: pkix.Name{CommonName: "runner"}, URIs: []*url.URL{spiffe}},
+			role: RoleWrite,
+			want: http.StatusForbidden,
+		},
+		{
+			name:     "email",
+			cert:     &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}, EmailAddresses: []string{"alice@example.com"}},
+			role:     RoleRead,
+			want:     http.StatusOK,
+			wantUser: "cert:alice",
+		},
+		{
+			name: "no matching rule",
+			cert: &x509.Certificate{Subject: pkix.Name{CommonName: "mallory"}, EmailAddresses: []string{"mallory@evil.example"}},
+			role: RoleRead,
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "no certificate",
+			role: RoleRead,
+			want: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := authorizeCertificate(authn, tt.cert, tt.role)
+			if d.Status != tt.want {
+				t.Fatalf("Status = %d, want %d", d.Status, tt.want)
+			}
+			if tt.wantUser != "" && d.Identity.Username != tt.wantUser {
+				t.Fatalf("Username = %q, want %q", d.Identity.Username, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestCertificateNamespaces(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.com/ci/runner")
+	authn := newTestAuthenticator(t, config.AuthConfig{
+		Certificates: []config.CertificateRule{
+			{URI: "spiffe://example.com/ci/*", Roles: []string{config.RoleRead}, Namespaces: []string{"ci"}},
+		},
+	})
+	d := authorizeCertificate(authn, &x509.Certificate{URIs: []*url.URL{spiffe}}, RoleRead)
+	if d.Status != http.StatusOK || d.Namespace != "ci" {
+		t.Fatalf("Status = %d, Namespace = %q, want 200 in ci", d.Status, d.Namespace)
+	}
+}
+
+func TestCertificateIgnoredWithAuthorizationHeader(t *testing.T) {
+	authn := newTestAuthenticator(t, config.AuthConfig{
+		Certificates: []config.CertificateRule{{CommonName: "*", Roles: []string{config.RoleRead}}},
+	})
+	req := httptest.NewRequest(http.MethodGet, "/cache/key", nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "ci"}}}}}
+	req.SetBasicAuth("nobody", "wrong")
+	if d := authn.Authorize(req, "192.0.2.1", RoleRead, ""); d.Status != http.StatusUnauthorized {
+		t.Fatalf("Status = %d, want 401 for failed Basic auth", d.Status)
+	}
+}
@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// htpasswdDebounce collapses the burst of events editors and Kubernetes
+// secret updates produce into a single reload.
+const htpasswdDebounce = 200 * time.Millisecond
+
+// htpasswd holds the credentials of an htpasswd file and reloads them when
+// the file changes. A file that fails to parse is ignored and the previous
+// credentials stay active.
+type htpasswd struct {
+	path   string
+	logger zerolog.Logger
+
+	mu     sync.RWMutex
+	hashes map[string]string
+	sum    [sha256.Size]byte
+	// verified caches the SHA-256 of the last password that matched each
+	// user, so bcrypt runs once per user rather than on every request. It
+	// is cleared on reload.
+	verified map[string][sha256.Size]byte
+}
+
+func newHtpasswd(path string, logger zerolog.Logger) (*htpasswd, error) {
+	h := &htpasswd{path: path, logger: logger}
+	if _, err := h.reload(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch htpasswd file: %w", err)
+	}
+	// Watch the directory rather than the file, since editors and Kubernetes
+	// replace the file instead of writing to it.
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch htpasswd file: %w", err)
+	}
+	go h.watch(watcher)
+	return h, nil
+}
+
+func (h *htpasswd) watch(watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+
+	var pending <-chan time.Time
+	for {
+		select {
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			pending = time.After(htpasswdDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			h.logger.Warn().Err(err).Str("path", h.path).Msg("htpasswd watcher error")
+		case <-pending:
+			pending = nil
+			changed, err := h.reload()
+			if err != nil {
+				h.logger.Error().Err(err).Str("path", h.path).Msg("rejected htpasswd file, keeping previous credentials")
+			} else if changed {
+				h.logger.Info().Str("path", h.path).Int("users", h.len()).Msg("reloaded htpasswd file")
+			}
+		}
+	}
+}
+
+// reload re-reads the file and reports whether its content changed.
+func (h *htpasswd) reload() (bool, error) {
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read htpasswd file: %w", err)
+	}
+	sum := sha256.Sum256(data)
+
+	h.mu.RLock()
+	unchanged := h.hashes != nil && sum == h.sum
+	h.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	hashes, err := parseHtpasswd(data)
+	if err != nil {
+		return false, err
+	}
+
+	h.mu.Lock()
+	h.hashes = hashes
+	h.sum = sum
+	h.verified = make(map[string][sha256.Size]byte)
+	h.mu.Unlock()
+	return true, nil
+}
+
+func (h *htpasswd) len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.hashes)
+}
+
+// verify reports whether password matches the hash stored for username.
+func (h *htpasswd) verify(username, password string) bool {
+	digest := sha256.Sum256([]byte(password))
+	h.mu.RLock()
+	hash, ok := h.hashes[username]
+	cached, hit := h.verified[username]
+	h.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	if hit && subtle.ConstantTimeCompare(cached[:], digest[:]) == 1 {
+		return true
+	}
+
+	if !matchHtpasswdHash(hash, password) {
+		return false
+	}
+	h.mu.Lock()
+	// Skip caching if a reload replaced the hash in the meantime.
+	if h.hashes[username] == hash {
+		h.verified[username] = digest
+	}
+	h.mu.Unlock()
+	return true
+}
+
+func matchHtpasswdHash(hash, password string) bool {
+	if strings.HasPrefix(hash, "{SHA}") {
+		sum := sha1.Sum([]byte(password))
+		expected := base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(hash[len("{SHA}"):]), []byte(expected)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// parseHtpasswd parses "user:hash" lines. Only bcrypt and {SHA} hashes are
+// accepted; any other line makes the whole file invalid.
+func parseHtpasswd(data []byte) (map[string]string, error) {
+	hashes := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		username, hash, ok := strings.Cut(line, ":")
+		if !ok || username == "" || hash == "" {
+			return nil, fmt.Errorf("htpasswd line %d: expected user:hash", n)
+		}
+		if err := checkHtpasswdHash(hash); err != nil {
+			return nil, fmt.Errorf("htpasswd line %d: %w", n, err)
+		}
+		if _, dup := hashes[username]; dup {
+			return nil, fmt.Errorf("htpasswd line %d: duplicate user %q", n, username)
+		}
+		hashes[username] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse htpasswd file: %w", err)
+	}
+	return hashes, nil
+}
+
+func checkHtpasswdHash(hash string) error {
+	if strings.HasPrefix(hash, "{SHA}") {
+		if raw, err := base64.StdEncoding.DecodeString(hash[len("{SHA}"):]); err != nil || len(raw) != sha1.Size {
+			return fmt.Errorf("invalid {SHA} hash")
+		}
+		return nil
+	}
+	if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+		return fmt.Errorf("unsupported hash, only bcrypt and {SHA} are accepted")
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kevingruber/gradle-cache/internal/config"
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func bcryptLine(t *testing.T, username, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword() = %v", err)
+	}
+	return username + ":" + string(hash) + "\n"
+}
+
+func shaLine(username, password string) string {
+	sum := sha1.Sum([]byte(password))
+	return username + ":{SHA}" + base64.StdEncoding.EncodeToString(sum[:]) + "\n"
+}
+
+func writeHtpasswd(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+}
+
+func newTestHtpasswd(t *testing.T, content string) (*htpasswd, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	writeHtpasswd(t, path, content)
+	h, err := newHtpasswd(path, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("newHtpasswd() = %v", err)
+	}
+	return h, path
+}
+
+func TestHtpasswdVerify(t *testing.T) {
+	h, _ := newTestHtpasswd(t, "# users\n"+bcryptLine(t, "alice", "secret")+shaLine("bob", "hunter2"))
+
+	tests := []struct {
+		username, password string
+		want               bool
+	}{
+		{"alice", "secret", true},
+		{"alice", "secret", true}, // served from the cache
+		{"alice", "wrong", false},
+		{"bob", "hunter2", true},
+		{"bob", "secret", false},
+		{"carol", "secret", false},
+	}
+	for _, tt := range tests {
+		if got := h.verify(tt.username, tt.password); got != tt.want {
+			t.Errorf("verify(%q, %q) = %v, want %v", tt.username, tt.password, got, tt.want)
+		}
+	}
+	if _, ok := h.verified["alice"]; !ok {
+		t.Fatal("successful bcrypt verification was not cached")
+	}
+}
+
+func TestHtpasswdReload(t *testing.T) {
+	h, path := newTestHtpasswd(t, bcryptLine(t, "alice", "old"))
+	if !h.verify("alice", "old") {
+		t.Fatal("verify(alice, old) = false")
+	}
+
+	writeHtpasswd(t, path, bcryptLine(t, "alice", "new"))
+	if changed, err := h.reload(); err != nil || !changed {
+		t.Fatalf("reload() = %v, %v, want changed", changed, err)
+	}
+	if h.verify("alice", "old") {
+		t.Fatal("old password still accepted after reload")
+	}
+	if !h.verify("alice", "new") {
+		t.Fatal("new password rejected after reload")
+	}
+
+	// A broken file keeps the previous credentials.
+	writeHtpasswd(t, path, "alice:plaintext\n")
+	if _, err := h.reload(); err == nil {
+		t.Fatal("reload() accepted a plaintext password")
+	}
+	if !h.verify("alice", "new") {
+		t.Fatal("credentials lost after a rejected reload")
+	}
+}
+
+func TestHtpasswdWatch(t *testing.T) {
+	h, path := newTestHtpasswd(t, bcryptLine(t, "alice", "secret"))
+
+	// Replace the file the way editors and Kubernetes do.
+	tmp := path + ".tmp"
+	writeHtpasswd(t, tmp, bcryptLine(t, "alice", "secret")+bcryptLine(t, "bob", "secret"))
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("Rename() = %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !h.verify("bob", "secret") {
+		if time.Now().After(deadline) {
+			t.Fatal("htpasswd file was not reloaded")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestParseHtpasswd(t *testing.T) {
+	for _, bad := range []string{
+		"alice\n",
+		":hash\n",
+		"alice:plaintext\n",
+		"alice:{SHA}not-base64\n",
+		"alice:$apr1$salt$hash\n",
+		shaLine("alice", "a") + shaLine("alice", "b"),
+	} {
+		if _, err := parseHtpasswd([]byte(bad)); err == nil {
+			t.Errorf("parseHtpasswd(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestAuthorizeHtpasswd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	writeHtpasswd(t, path, bcryptLine(t, "admin", "root")+shaLine("student", "secret"))
+	authn := newTestAuthenticator(t, config.AuthConfig{
+		Users:         []config.UserAuth{{Username: "admin", Roles: []string{config.RoleAdmin}}},
+		HtpasswdFile:  path,
+		HtpasswdRoles: []string{config.RoleRead},
+	})
+
+	if d := authorize(authn, "admin", "root", RoleAdmin, ""); d.Status != http.StatusOK {
+		t.Fatalf("configured user: Status = %d, want 200", d.Status)
+	}
+	if d := authorize(authn, "student", "secret", RoleRead, ""); d.Status != http.StatusOK {
+		t.Fatalf("htpasswd user: Status = %d, want 200", d.Status)
+	}
+	if d := authorize(authn, "student", "secret", RoleWrite, ""); d.Status != http.StatusForbidden {
+		t.Fatalf("htpasswd user writing: Status = %d, want 403", d.Status)
+	}
+	if d := authorize(authn, "student", "wrong", RoleRead, ""); d.Status != http.StatusUnauthorized {
+		t.Fatalf("wrong password: Status = %d, want 401", d.Status)
+	}
+}
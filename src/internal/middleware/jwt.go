@@ -0,0 +1,402 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/kevingruber/gradle-cache/internal/config"
+	"github.com/rs/zerolog"
+)
+
+// jwksMinRefresh bounds how often an unknown key ID may trigger an early
+// JWKS reload, so garbage tokens cannot hammer the identity provider.
+const jwksMinRefresh = 30 * time.Second
+
+// jwtLeeway tolerates clock skew between the server and the identity provider.
+const jwtLeeway = 30 * time.Second
+
+// jwtValidator validates JWTs against a JWKS and maps their claims to an
+// identity.
+type jwtValidator struct {
+	cfg    config.JWTConfig
+	parser *jwt.Parser
+	keys   *jwks
+	logger zerolog.Logger
+}
+
+func newJWTValidator(cfg config.JWTConfig, logger zerolog.Logger) (*jwtValidator, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(jwtLeeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	keys := &jwks{
+		file:     cfg.JWKSFile,
+		url:      cfg.JWKSURL,
+		interval: cfg.RefreshInterval,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		logger:   logger,
+	}
+	if err := keys.refresh(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	keys.stop = cancel
+	keys.done = make(chan struct{})
+	go keys.refreshLoop(ctx)
+
+	return &jwtValidator{
+		cfg:    cfg,
+		parser: jwt.NewParser(opts...),
+		keys:   keys,
+		logger: logger,
+	}, nil
+}
+
+// looksLikeJWT reports whether a bearer value has the three-part JWS shape.
+// Opaque tokens from GenerateToken never contain dots.
+func looksLikeJWT(s string) bool {
+	return strings.Count(s, ".") == 2
+}
+
+// authenticate returns the identity for a valid token, or nil.
+func (v *jwtValidator) authenticate(raw string) *Identity {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(raw, claims, v.keyFunc); err != nil {
+		return nil
+	}
+
+	identity := &Identity{Username: claimString(claims, v.cfg.UsernameClaim)}
+	if identity.Username == "" {
+		identity.Username = claimString(claims, "sub")
+	}
+	for _, r := range claimStrings(claims, v.cfg.RoleClaim) {
+		if role := Role(r); role.level() > 0 {
+			identity.Roles = append(identity.Roles, role)
+		}
+	}
+	if len(identity.Roles) == 0 {
+		// The token is still authenticated, but every request gets 403.
+		v.logger.Warn().
+			Str("username", identity.Username).
+			Str("claim", v.cfg.RoleClaim).
+			Interface("value", claims[v.cfg.RoleClaim]).
+			Msg("JWT has no usable role claim")
+	}
+
+	if v.cfg.NamespaceClaim != "" {
+		if claim, ok := claims[v.cfg.NamespaceClaim]; ok {
+			for _, ns := range claimStrings(claims, v.cfg.NamespaceClaim) {
+				if ValidNamespace(ns) {
+					identity.Namespaces = append(identity.Namespaces, ns)
+				}
+			}
+			// A present but unusable claim must not turn into "all namespaces".
+			if len(identity.Namespaces) == 0 {
+				v.logger.Warn().
+					Str("username", identity.Username).
+					Str("claim", v.cfg.NamespaceClaim).
+					Interface("value", claim).
+					Msg("rejecting JWT with unusable namespace claim")
+				return nil
+			}
+		}
+	}
+	return identity
+}
+
+func (v *jwtValidator) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := v.keys.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// claimString returns a string claim, or "" if it is missing or not a string.
+func claimString(claims jwt.MapClaims, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+// claimStrings returns a claim that is either a string, a number or a list
+// of them. Numbers are formatted in decimal, so an identity provider that
+// emits "exercise_id": 42 matches the namespace "42".
+func claimStrings(claims jwt.MapClaims, name string) []string {
+	switch v := claims[name].(type) {
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := claimValue(item); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		if s, ok := claimValue(v); ok {
+			return []string{s}
+		}
+	}
+	return nil
+}
+
+// claimValue formats a scalar claim value as a string.
+func claimValue(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case json.Number:
+		return v.String(), true
+	}
+	return "", false
+}
+
+// jwks holds the signing keys of a JWKS document and reloads them
+// periodically. Keys are looked up by key ID.
+type jwks struct {
+	file     string
+	url      string
+	interval time.Duration
+	client   *http.Client
+	logger   zerolog.Logger
+	// stop ends the reload loop, which closes done once it has returned.
+	stop context.CancelFunc
+	done chan struct{}
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	lastRefresh time.Time
+}
+
+func (k *jwks) lookup(kid string) (crypto.PublicKey, bool) {
+	k.mu.RLock()
+	key, ok := k.find(kid)
+	k.mu.RUnlock()
+	if ok || !k.claimEarlyRefresh() {
+		return key, ok
+	}
+
+	// The identity provider may have rotated its keys since the last reload.
+	if err := k.refresh(); err != nil {
+		k.logger.Warn().Err(err).Msg("failed to reload JWKS")
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.find(kid)
+}
+
+// find must be called with k.mu held. Tokens without a key ID are accepted
+// only if the set has exactly one key.
+func (k *jwks) find(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key, true
+		}
+	}
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+// claimEarlyRefresh reports whether an out-of-schedule reload may run now
+// and, if so, records it so concurrent lookups do not reload as well.
+func (k *jwks) claimEarlyRefresh() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if time.Since(k.lastRefresh) < jwksMinRefresh {
+		return false
+	}
+	k.lastRefresh = time.Now()
+	return true
+}
+
+func (k *jwks) refreshLoop(ctx context.Context) {
+	defer close(k.done)
+	ticker := time.NewTicker(k.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.refresh(); err != nil {
+				k.logger.Warn().Err(err).Msg("failed to reload JWKS, keeping previous keys")
+			}
+		}
+	}
+}
+
+// close stops reloading the key set. The current keys stay in use.
+func (k *jwks) close() {
+	k.stop()
+	<-k.done
+}
+
+// refresh reloads the key set. On failure the previous keys are kept.
+func (k *jwks) refresh() error {
+	k.mu.Lock()
+	k.lastRefresh = time.Now()
+	k.mu.Unlock()
+
+	data, err := k.load()
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.mu.Unlock()
+	return nil
+}
+
+func (k *jwks) load() ([]byte, error) {
+	if k.file != "" {
+		data, err := os.ReadFile(k.file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+		}
+		return data, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), k.client.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS: %w", err)
+	}
+	return data, nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS extracts the RSA and EC signing keys of a JWKS document.
+// Encryption keys and unsupported key types are skipped.
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		var (
+			key crypto.PublicKey
+			err error
+		)
+		switch jwk.Kty {
+		case "RSA":
+			key, err = jwk.rsaKey()
+		case "EC":
+			key, err = jwk.ecKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS key %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS contains no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (jwk jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeBigInt(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("invalid RSA exponent")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (jwk jsonWebKey) ecKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+	}
+	x, err := decodeBigInt(jwk.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeBigInt(jwk.Y)
+	if err != nil {
+		return nil, err
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/kevingruber/gradle-cache/internal/config"
+)
+
+// testSigner is an ES256 key published under kid in a JWKS file.
+type testSigner struct {
+	kid string
+	key *ecdsa.PrivateKey
+}
+
+func newTestSigner(t *testing.T, kid string) testSigner {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() = %v", err)
+	}
+	return testSigner{kid: kid, key: key}
+}
+
+func (s testSigner) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = s.kid
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		t.Fatalf("SignedString() = %v", err)
+	}
+	return signed
+}
+
+// writeJWKS writes the public keys of signers to path.
+func writeJWKS(t *testing.T, path string, signers ...testSigner) {
+	t.Helper()
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	for _, s := range signers {
+		set.Keys = append(set.Keys, jsonWebKey{
+			Kty: "EC",
+			Kid: s.kid,
+			Use: "sig",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(s.key.X.FillBytes(make([]byte, 32))),
+			Y:   base64.RawURLEncoding.EncodeToString(s.key.Y.FillBytes(make([]byte, 32))),
+		})
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("Marshal() = %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+}
+
+func newTestJWTAuthenticator(t *testing.T, signers ...testSigner) (*Authenticator, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, signers...)
+	authn := newTestAuthenticator(t, config.AuthConfig{
+		JWT: config.JWTConfig{
+			Enabled:         true,
+			JWKSFile:        path,
+			RefreshInterval: time.Hour,
+			Issuer:          "https://idp.example.com",
+			RoleClaim:       "cache_role",
+			NamespaceClaim:  "exercise_id",
+		},
+	})
+	t.Cleanup(func() { authn.Close() })
+	return authn, path
+}
+
+func testClaims(extra jwt.MapClaims) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"iss":        "https://idp.example.com",
+		"sub":        "student",
+		"exp":        time.Now().Add(time.Hour).Unix(),
+		"cache_role": "write",
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	return claims
+}
+
+func TestAuthorizeJWT(t *testing.T) {
+	signer := newTestSigner(t, "key-1")
+	authn, _ := newTestJWTAuthenticator(t, signer)
+
+	d := authorizeBearer(authn, signer.sign(t, testClaims(nil)), RoleWrite)
+	if d.Status != http.StatusOK || d.Identity.Username != "student" {
+		t.Fatalf("valid token: Status = %d, Identity = %+v", d.Status, d.Identity)
+	}
+	if d := authorizeBearer(authn, signer.sign(t, testClaims(nil)), RoleAdmin); d.Status != http.StatusForbidden {
+		t.Fatalf("insufficient role: Status = %d, want 403", d.Status)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired", signer.sign(t, testClaims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}))},
+		{"missing expiry", signer.sign(t, testClaims(jwt.MapClaims{"exp": nil}))},
+		{"wrong issuer", signer.sign(t, testClaims(jwt.MapClaims{"iss": "https://evil.example.com"}))},
+		{"unknown kid", testSigner{kid: "key-2", key: signer.key}.sign(t, testClaims(nil))},
+		{"unknown key", newTestSigner(t, "key-1").sign(t, testClaims(nil))},
+		{"unusable namespace", signer.sign(t, testClaims(jwt.MapClaims{"exercise_id": "../etc"}))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if d := authorizeBearer(authn, tt.token, RoleRead); d.Status != http.StatusUnauthorized {
+				t.Fatalf("Status = %d, want 401", d.Status)
+			}
+		})
+	}
+}
+
+func TestAuthorizeJWTNamespaceClaim(t *testing.T) {
+	signer := newTestSigner(t, "key-1")
+	authn, _ := newTestJWTAuthenticator(t, signer)
+
+	for _, claim := range []any{42, "42", []any{42, "43"}} {
+		token := signer.sign(t, testClaims(jwt.MapClaims{"exercise_id": claim}))
+		d := authorizeBearer(authn, token, RoleRead)
+		if d.Status != http.StatusOK || d.Namespace != "42" {
+			t.Fatalf("exercise_id %v: Status = %d, Namespace = %q, want 200 in 42", claim, d.Status, d.Namespace)
+		}
+	}
+}
+
+func TestJWKSRefresh(t *testing.T) {
+	oldSigner := newTestSigner(t, "key-1")
+	authn, path := newTestJWTAuthenticator(t, oldSigner)
+
+	// The identity provider rotates to a new key.
+	newSigner := newTestSigner(t, "key-2")
+	writeJWKS(t, path, newSigner)
+	token := newSigner.sign(t, testClaims(nil))
+
+	// Unknown key IDs reload the set at most every jwksMinRefresh.
+	if d := authorizeBearer(authn, token, RoleRead); d.Status != http.StatusUnauthorized {
+		t.Fatalf("before the reload interval: Status = %d, want 401", d.Status)
+	}
+	authn.jwt.keys.mu.Lock()
+	authn.jwt.keys.lastRefresh = time.Now().Add(-jwksMinRefresh)
+	authn.jwt.keys.mu.Unlock()
+
+	if d := authorizeBearer(authn, token, RoleRead); d.Status != http.StatusOK {
+		t.Fatalf("after rotation: Status = %d, want 200", d.Status)
+	}
+	if d := authorizeBearer(authn, oldSigner.sign(t, testClaims(nil)), RoleRead); d.Status != http.StatusUnauthorized {
+		t.Fatalf("rotated out key: Status = %d, want 401", d.Status)
+	}
+}
+
+func TestJWKSClose(t *testing.T) {
+	signer := newTestSigner(t, "key-1")
+	authn, _ := newTestJWTAuthenticator(t, signer)
+
+	done := make(chan struct{})
+	go func() {
+		authn.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop the reload loop")
+	}
+
+	// The keys loaded before Close stay in use.
+	if d := authorizeBearer(authn, signer.sign(t, testClaims(nil)), RoleRead); d.Status != http.StatusOK {
+		t.Fatalf("after Close: Status = %d, want 200", d.Status)
+	}
+}
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kevingruber/gradle-cache/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	lockoutScopeIP   = "ip"
+	lockoutScopeUser = "username"
+)
+
+// lockoutKey identifies a tracked client IP, or a username used from a
+// client IP.
+type lockoutKey struct {
+	scope string
+	value string
+	ip    string
+}
+
+// lockoutKeys returns the keys a request is tracked under. Usernames are
+// tracked per client IP, so failures from one address cannot lock a user
+// out everywhere. Requests without a username are only tracked by IP.
+func lockoutKeys(ip, username string) []lockoutKey {
+	keys := []lockoutKey{{scope: lockoutScopeIP, value: ip}}
+	if username != "" {
+		keys = append(keys, lockoutKey{scope: lockoutScopeUser, value: username, ip: ip})
+	}
+	return keys
+}
+
+type lockoutEntry struct {
+	key         lockoutKey
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// lockout tracks authentication failures with exponential backoff. Beyond
+// cfg.MaxTracked entries it drops the least recently failed ones that are
+// not locked out, so flooding it with new keys cannot lift a lockout.
+type lockout struct {
+	cfg     config.LockoutConfig
+	metrics *lockoutMetrics
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[lockoutKey]*list.Element
+}
+
+func newLockout(cfg config.LockoutConfig) (*lockout, error) {
+	metrics, err := newLockoutMetrics()
+	if err != nil {
+		return nil, err
+	}
+	return &lockout{
+		cfg:     cfg,
+		metrics: metrics,
+		order:   list.New(),
+		entries: make(map[lockoutKey]*list.Element),
+	}, nil
+}
+
+// retryAfter returns how long the longest lockout among keys still lasts,
+// or zero if none of them is locked out.
+func (l *lockout) retryAfter(keys []lockoutKey) time.Duration {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var wait time.Duration
+	for _, key := range keys {
+		if elem, ok := l.entries[key]; ok {
+			if d := elem.Value.(*lockoutEntry).lockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait
+}
+
+// fail records a failed attempt for keys and locks out those that reached
+// the failure limit.
+func (l *lockout) fail(ctx context.Context, keys []lockoutKey) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.metrics.failures.Add(ctx, 1)
+	for _, key := range keys {
+		var entry *lockoutEntry
+		if elem, ok := l.entries[key]; ok {
+			entry = elem.Value.(*lockoutEntry)
+			l.order.MoveToFront(elem)
+			if now.Sub(entry.lastFailure) > l.cfg.ResetAfter {
+				entry.failures = 0
+			}
+		} else {
+			entry = &lockoutEntry{key: key}
+			l.entries[key] = l.order.PushFront(entry)
+		}
+
+		entry.failures++
+		entry.lastFailure = now
+		if entry.failures >= l.cfg.MaxFailures {
+			entry.lockedUntil = now.Add(l.delay(entry.failures))
+			l.metrics.lockouts.Add(ctx, 1, metric.WithAttributes(attribute.String("scope", key.scope)))
+		}
+	}
+	l.evict(now)
+}
+
+// evict drops the least recently failed entries beyond cfg.MaxTracked,
+// skipping those still locked out. Must be called with l.mu held.
+func (l *lockout) evict(now time.Time) {
+	for elem := l.order.Back(); elem != nil && len(l.entries) > l.cfg.MaxTracked; {
+		prev := elem.Prev()
+		if entry := elem.Value.(*lockoutEntry); !entry.lockedUntil.After(now) {
+			l.order.Remove(elem)
+			delete(l.entries, entry.key)
+		}
+		elem = prev
+	}
+}
+
+// succeed forgets the failures of keys.
+func (l *lockout) succeed(keys []lockoutKey) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := l.entries[key]; ok {
+			l.order.Remove(elem)
+			delete(l.entries, key)
+		}
+	}
+}
+
+// delay returns the lockout for the given number of failures: BaseDelay at
+// the limit, doubling for each failure beyond it, capped at MaxDelay.
+func (l *lockout) delay(failures int) time.Duration {
+	d := l.cfg.BaseDelay
+	for i := l.cfg.MaxFailures; i < failures && d < l.cfg.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, l.cfg.MaxDelay)
+}
+
+type lockoutMetrics struct {
+	failures metric.Int64Counter
+	lockouts metric.Int64Counter
+}
+
+func newLockoutMetrics() (*lockoutMetrics, error) {
+	meter := otel.Meter("gradle-cache")
+
+	failures, err := meter.Int64Counter(
+		"gradle_cache.auth_failures",
+		metric.WithDescription("Total number of failed authentication attempts"))
+	if err != nil {
+		return nil, err
+	}
+
+	lockouts, err := meter.Int64Counter(
+		"gradle_cache.auth_lockouts",
+		metric.WithDescription("Total number of times a client IP or username was locked out"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &lockoutMetrics{failures: failures, lockouts: lockouts}, nil
+}
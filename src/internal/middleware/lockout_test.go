@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kevingruber/gradle-cache/internal/config"
+)
+
+func testLockoutConfig() config.LockoutConfig {
+	return config.LockoutConfig{
+		Enabled:     true,
+		MaxFailures: 3,
+		BaseDelay:   time.Minute,
+		MaxDelay:    4 * time.Minute,
+		ResetAfter:  time.Hour,
+		MaxTracked:  100,
+	}
+}
+
+func newTestLockoutAuthenticator(t *testing.T) *Authenticator {
+	t.Helper()
+	return newTestAuthenticator(t, config.AuthConfig{
+		Users:   []config.UserAuth{{Username: "alice", Password: "secret", Roles: []string{config.RoleRead}}},
+		Lockout: testLockoutConfig(),
+	})
+}
+
+// authorizeFrom runs Authorize for Basic credentials sent from clientIP.
+func authorizeFrom(authn *Authenticator, clientIP, username, password string) Decision {
+	req := httptest.NewRequest(http.MethodGet, "/cache/key", nil)
+	req.SetBasicAuth(username, password)
+	return authn.Authorize(req, clientIP, RoleRead, "")
+}
+
+func TestLockoutAfterFailures(t *testing.T) {
+	authn := newTestLockoutAuthenticator(t)
+
+	for i := 0; i < 3; i++ {
+		if d := authorizeFrom(authn, "192.0.2.1", "alice", "guess"); d.Status != http.StatusUnauthorized {
+			t.Fatalf("failure %d: Status = %d, want 401", i+1, d.Status)
+		}
+	}
+	d := authorizeFrom(authn, "192.0.2.1", "alice", "secret")
+	if d.Status != http.StatusTooManyRequests || d.RetryAfter <= 0 || d.RetryAfter > time.Minute {
+		t.Fatalf("locked out: Status = %d, RetryAfter = %v, want 429 within a minute", d.Status, d.RetryAfter)
+	}
+
+	// The username is only locked out at the address the failures came from.
+	if d := authorizeFrom(authn, "192.0.2.2", "alice", "secret"); d.Status != http.StatusOK {
+		t.Fatalf("other address: Status = %d, want 200", d.Status)
+	}
+}
+
+func TestLockoutSuccessClearsFailures(t *testing.T) {
+	authn := newTestLockoutAuthenticator(t)
+
+	for i := 0; i < 2; i++ {
+		authorizeFrom(authn, "192.0.2.1", "alice", "guess")
+	}
+	if d := authorizeFrom(authn, "192.0.2.1", "alice", "secret"); d.Status != http.StatusOK {
+		t.Fatalf("Status = %d, want 200", d.Status)
+	}
+	for i := 0; i < 2; i++ {
+		authorizeFrom(authn, "192.0.2.1", "alice", "guess")
+	}
+	if d := authorizeFrom(authn, "192.0.2.1", "alice", "secret"); d.Status != http.StatusOK {
+		t.Fatalf("after clearing: Status = %d, want 200", d.Status)
+	}
+}
+
+func TestLockoutIgnoresMissingCredentials(t *testing.T) {
+	authn := newTestLockoutAuthenticator(t)
+
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/cache/key", nil)
+		if d := authn.Authorize(req, "192.0.2.1", RoleRead, ""); d.Status != http.StatusUnauthorized {
+			t.Fatalf("Status = %d, want 401", d.Status)
+		}
+	}
+	if d := authorizeFrom(authn, "192.0.2.1", "alice", "secret"); d.Status != http.StatusOK {
+		t.Fatalf("Status = %d, want 200", d.Status)
+	}
+}
+
+func TestLockoutDelay(t *testing.T) {
+	l, err := newLockout(testLockoutConfig())
+	if err != nil {
+		t.Fatalf("newLockout() = %v", err)
+	}
+	for failures, want := range map[int]time.Duration{
+		3: time.Minute,
+		4: 2 * time.Minute,
+		5: 4 * time.Minute,
+		9: 4 * time.Minute,
+	} {
+		if got := l.delay(failures); got != want {
+			t.Errorf("delay(%d) = %v, want %v", failures, got, want)
+		}
+	}
+}
+
+func TestLockoutKeepsLockedEntries(t *testing.T) {
+	cfg := testLockoutConfig()
+	cfg.MaxTracked = 2
+	l, err := newLockout(cfg)
+	if err != nil {
+		t.Fatalf("newLockout() = %v", err)
+	}
+	ctx := context.Background()
+
+	locked := lockoutKeys("192.0.2.1", "")
+	for i := 0; i < cfg.MaxFailures; i++ {
+		l.fail(ctx, locked)
+	}
+	// Flood the tracker with single failures from other addresses.
+	for i := 0; i < 10; i++ {
+		l.fail(ctx, lockoutKeys("198.51.100."+strconv.Itoa(i), ""))
+	}
+
+	if l.retryAfter(locked) <= 0 {
+		t.Fatal("locked out address was evicted")
+	}
+	if len(l.entries) != cfg.MaxTracked {
+		t.Fatalf("tracking %d entries, want %d", len(l.entries), cfg.MaxTracked)
+	}
+}
+
+func TestCacheAuthRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authn := newTestLockoutAuthenticator(t)
+	r := gin.New()
+	r.GET("/cache/:key", CacheAuth(authn, RoleRead), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 4; i++ {
+		w = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/cache/key", nil)
+		req.SetBasicAuth("alice", "guess")
+		r.ServeHTTP(w, req)
+	}
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Fatalf("Code = %d, Retry-After = %q, want 429 after 60s", w.Code, w.Header().Get("Retry-After"))
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -39,12 +40,17 @@ func RequestLogger(logger zerolog.Logger) gin.HandlerFunc {
 			Str("client_ip", c.ClientIP())
 
 		// Add username if authenticated
-		if username, exists := c.Get("username"); exists {
-			event.Str("user", username.(string))
+		if username := c.GetString(UsernameKey); username != "" {
+			event.Str("user", username)
+		}
+
+		// Add namespace if present
+		if ns := c.GetString(NamespaceKey); ns != "" {
+			event.Str("namespace", ns)
 		}
 
 		// Add cache key if present
-		if key := c.Param("key"); key != "" {
+		if key := CacheKey(c); key != "" {
 			event.Str("cache_key", key)
 		}
 
@@ -56,3 +62,15 @@ func RequestLogger(logger zerolog.Logger) gin.HandlerFunc {
 		event.Msg("request")
 	}
 }
+
+// CacheKey returns the cache key, hash or WebDAV path a request refers to,
+// if any.
+func CacheKey(c *gin.Context) string {
+	if key := c.Param("key"); key != "" {
+		return key
+	}
+	if hash := c.Param("hash"); hash != "" {
+		return hash
+	}
+	return strings.TrimPrefix(c.Param("path"), "/")
+}
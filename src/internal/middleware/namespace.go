@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+// NamespaceKey is the context key holding the namespace of a request.
+const NamespaceKey = "namespace"
+
+var namespacePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidNamespace reports whether ns is a well-formed namespace name.
+func ValidNamespace(ns string) bool {
+	return namespacePattern.MatchString(ns)
+}
+
+// Namespace creates a middleware that takes the namespace from the
+// :namespace route parameter and stores it in the request context.
+func Namespace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ns := c.Param("namespace")
+		if !ValidNamespace(ns) {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		c.Set(NamespaceKey, ns)
+		c.Next()
+	}
+}
+
+// QueryNamespace creates a middleware that takes the namespace from the
+// first of the given query parameters that is set, for clients that select
+// a namespace by query rather than by path. Requests without any of them
+// use the default namespace.
+func QueryNamespace(params ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, param := range params {
+			ns := c.Query(param)
+			if ns == "" {
+				continue
+			}
+			if !ValidNamespace(ns) {
+				c.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
+			c.Set(NamespaceKey, ns)
+			break
+		}
+		c.Next()
+	}
+}
+
+// IgnoredQueryNamespace creates a middleware for the routes of
+// QueryNamespace while namespaces are disabled. It logs once when a client
+// selects a namespace with one of the given query parameters, since the
+// parameter is ignored and the client shares the default namespace.
+func IgnoredQueryNamespace(logger zerolog.Logger, params ...string) gin.HandlerFunc {
+	var once sync.Once
+	return func(c *gin.Context) {
+		for _, param := range params {
+			if c.Query(param) == "" {
+				continue
+			}
+			once.Do(func() {
+				logger.Warn().
+					Str("param", param).
+					Msg("namespaces are disabled, ignoring the namespace query parameter")
+			})
+			break
+		}
+		c.Next()
+	}
+}
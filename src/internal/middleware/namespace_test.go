@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+func TestValidNamespace(t *testing.T) {
+	for ns, want := range map[string]bool{
+		"exercise-42":            true,
+		"team_a.b":               true,
+		"":                       false,
+		"_blobs":                 false,
+		"-leading":               false,
+		"has/slash":              false,
+		"has:colon":              false,
+		string(make([]byte, 65)): false,
+	} {
+		if got := ValidNamespace(ns); got != want {
+			t.Errorf("ValidNamespace(%q) = %v, want %v", ns, got, want)
+		}
+	}
+}
+
+// namespaceOf runs middleware for path and returns the response status and
+// the namespace it stored.
+func namespaceOf(t *testing.T, route, path string, middleware gin.HandlerFunc) (int, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	var ns string
+	r.GET(route, middleware, func(c *gin.Context) {
+		ns = c.GetString(NamespaceKey)
+		c.Status(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w.Code, ns
+}
+
+func TestNamespaceMiddleware(t *testing.T) {
+	if code, ns := namespaceOf(t, "/ns/:namespace/cache/:key", "/ns/exercise-1/cache/abc", Namespace()); code != http.StatusOK || ns != "exercise-1" {
+		t.Fatalf("got %d, %q", code, ns)
+	}
+	if code, _ := namespaceOf(t, "/ns/:namespace/cache/:key", "/ns/_blobs/cache/abc", Namespace()); code != http.StatusBadRequest {
+		t.Fatalf("invalid namespace got %d, want 400", code)
+	}
+}
+
+func TestQueryNamespace(t *testing.T) {
+	mw := QueryNamespace("slug", "teamId")
+	if code, ns := namespaceOf(t, "/a", "/a?teamId=team&slug=slug", mw); code != http.StatusOK || ns != "slug" {
+		t.Fatalf("got %d, %q; want the first parameter", code, ns)
+	}
+	if code, ns := namespaceOf(t, "/a", "/a", mw); code != http.StatusOK || ns != "" {
+		t.Fatalf("got %d, %q; want the default namespace", code, ns)
+	}
+	if code, _ := namespaceOf(t, "/a", "/a?teamId=../x", mw); code != http.StatusBadRequest {
+		t.Fatalf("invalid namespace got %d, want 400", code)
+	}
+}
+
+func TestIgnoredQueryNamespace(t *testing.T) {
+	var logs bytes.Buffer
+	mw := IgnoredQueryNamespace(zerolog.New(&logs), "slug", "teamId")
+
+	if code, ns := namespaceOf(t, "/a", "/a", mw); code != http.StatusOK || ns != "" || logs.Len() != 0 {
+		t.Fatalf("got %d, %q, logged %q; want the default namespace without a warning", code, ns, logs.String())
+	}
+	for _, path := range []string{"/a?teamId=team", "/a?slug=other"} {
+		if code, ns := namespaceOf(t, "/a", path, mw); code != http.StatusOK || ns != "" {
+			t.Fatalf("%s: got %d, %q; want the default namespace", path, code, ns)
+		}
+	}
+	if n := strings.Count(logs.String(), "ignoring the namespace query parameter"); n != 1 {
+		t.Fatalf("logged the warning %d times, want once", n)
+	}
+}
@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"container/list"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kevingruber/gradle-cache/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/time/rate"
+)
+
+const (
+	rateScopeUser      = "user"
+	rateScopeNamespace = "namespace"
+
+	rateReasonRequests = "requests"
+	rateReasonUploads  = "uploads"
+)
+
+// RateLimiter enforces per-user and per-namespace request rates and bounds
+// on concurrent uploads.
+type RateLimiter struct {
+	users      *limiterSet
+	namespaces *limiterSet
+	rejections metric.Int64Counter
+}
+
+// NewRateLimiter creates a rate limiter for the limits in cfg.
+func NewRateLimiter(cfg config.RateLimitConfig) (*RateLimiter, error) {
+	rejections, err := otel.Meter("gradle-cache").Int64Counter(
+		"gradle_cache.rate_limited",
+		metric.WithDescription("Total number of requests rejected by rate or upload limits"))
+	if err != nil {
+		return nil, err
+	}
+	return &RateLimiter{
+		users:      newLimiterSet(rateScopeUser, cfg.PerUser, cfg.MaxTracked),
+		namespaces: newLimiterSet(rateScopeNamespace, cfg.PerNamespace, cfg.MaxTracked),
+		rejections: rejections,
+	}, nil
+}
+
+// Middleware creates a Gin middleware that applies the limits. It must run
+// after CacheAuth so the user and namespace are known; without auth, clients
+// are limited by IP.
+func (l *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.GetString(UsernameKey)
+		if user == "" {
+			user = "ip:" + c.ClientIP()
+		}
+		// Requests outside any namespace get a bucket of their own per user
+		// rather than one shared by every client. Namespace names cannot
+		// contain ":", so these keys never collide with a namespace.
+		ns := c.GetString(NamespaceKey)
+		if ns == "" {
+			ns = "user:" + user
+		}
+		sets := []*limiterSet{l.users, l.namespaces}
+		entries := []*limiterEntry{l.users.get(user), l.namespaces.get(ns)}
+
+		// Take a token from every bucket, or from none of them.
+		now := time.Now()
+		reservations := make([]*rate.Reservation, 0, len(entries))
+		for i, entry := range entries {
+			if entry.bucket == nil {
+				continue
+			}
+			r := entry.bucket.ReserveN(now, 1)
+			if delay := r.DelayFrom(now); delay > 0 {
+				r.CancelAt(now)
+				for _, prev := range reservations {
+					prev.CancelAt(now)
+				}
+				l.reject(c, sets[i].scope, rateReasonRequests, delay)
+				return
+			}
+			reservations = append(reservations, r)
+		}
+
+		if c.Request.Method == http.MethodPut {
+			for i, entry := range entries {
+				if !sets[i].acquireUpload(entry) {
+					for j := range i {
+						sets[j].releaseUpload(entries[j])
+					}
+					l.reject(c, sets[i].scope, rateReasonUploads, time.Second)
+					return
+				}
+			}
+			defer func() {
+				for i, entry := range entries {
+					sets[i].releaseUpload(entry)
+				}
+			}()
+		}
+
+		c.Next()
+	}
+}
+
+func (l *RateLimiter) reject(c *gin.Context, scope, reason string, retryAfter time.Duration) {
+	l.rejections.Add(c.Request.Context(), 1, metric.WithAttributes(
+		attribute.String("scope", scope),
+		attribute.String("reason", reason)))
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	c.AbortWithStatus(http.StatusTooManyRequests)
+}
+
+type limiterEntry struct {
+	key     string
+	bucket  *rate.Limiter
+	uploads int
+}
+
+// limiterSet holds the limiters of one scope, keyed by user or namespace.
+// Beyond maxTracked keys it forgets the least recently used ones. Entries
+// with uploads in flight are kept, since a fresh entry for the same key would
+// let further uploads past the limit.
+type limiterSet struct {
+	scope      string
+	limit      config.RateLimit
+	maxTracked int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func newLimiterSet(scope string, limit config.RateLimit, maxTracked int) *limiterSet {
+	return &limiterSet{
+		scope:      scope,
+		limit:      limit,
+		maxTracked: maxTracked,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+func (s *limiterSet) get(key string) *limiterEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		s.order.MoveToFront(elem)
+		return elem.Value.(*limiterEntry)
+	}
+
+	entry := &limiterEntry{key: key}
+	if s.limit.RequestsPerSecond > 0 {
+		entry.bucket = rate.NewLimiter(rate.Limit(s.limit.RequestsPerSecond), s.limit.Burst)
+	}
+	s.entries[key] = s.order.PushFront(entry)
+	for elem := s.order.Back(); elem != nil && len(s.entries) > s.maxTracked; {
+		prev := elem.Prev()
+		if oldest := elem.Value.(*limiterEntry); oldest.uploads == 0 && oldest != entry {
+			s.order.Remove(elem)
+			delete(s.entries, oldest.key)
+		}
+		elem = prev
+	}
+	return entry
+}
+
+func (s *limiterSet) acquireUpload(entry *limiterEntry) bool {
+	if s.limit.MaxUploads <= 0 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry.uploads >= s.limit.MaxUploads {
+		return false
+	}
+	entry.uploads++
+	return true
+}
+
+func (s *limiterSet) releaseUpload(entry *limiterEntry) {
+	if s.limit.MaxUploads <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.uploads--
+}
@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kevingruber/gradle-cache/internal/config"
+)
+
+// newTestRateLimitRouter serves /cache/:key behind the rate limiter. The
+// X-User and X-Namespace headers stand in for what CacheAuth would set. If
+// started is not nil, PUT handlers signal on it and block until release is
+// closed.
+func newTestRateLimitRouter(t *testing.T, cfg config.RateLimitConfig, started, release chan struct{}) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	limiter, err := NewRateLimiter(cfg)
+	if err != nil {
+		t.Fatalf("NewRateLimiter() = %v", err)
+	}
+	r := gin.New()
+	identify := func(c *gin.Context) {
+		if user := c.GetHeader("X-User"); user != "" {
+			c.Set(UsernameKey, user)
+		}
+		if ns := c.GetHeader("X-Namespace"); ns != "" {
+			c.Set(NamespaceKey, ns)
+		}
+	}
+	r.GET("/cache/:key", identify, limiter.Middleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.PUT("/cache/:key", identify, limiter.Middleware(), func(c *gin.Context) {
+		if started != nil {
+			started <- struct{}{}
+			<-release
+		}
+		c.Status(http.StatusCreated)
+	})
+	return r
+}
+
+func rateLimited(r *gin.Engine, method, user, ns string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/cache/key", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+	if ns != "" {
+		req.Header.Set("X-Namespace", ns)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitPerUser(t *testing.T) {
+	r := newTestRateLimitRouter(t, config.RateLimitConfig{
+		PerUser:    config.RateLimit{RequestsPerSecond: 0.1, Burst: 2},
+		MaxTracked: 100,
+	}, nil, nil)
+
+	for i := 0; i < 2; i++ {
+		if w := rateLimited(r, http.MethodGet, "alice", ""); w.Code != http.StatusOK {
+			t.Fatalf("request %d: Code = %d, want 200", i+1, w.Code)
+		}
+	}
+	w := rateLimited(r, http.MethodGet, "alice", "")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("Code = %d, Retry-After = %q, want 429 with Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := rateLimited(r, http.MethodGet, "bob", ""); w.Code != http.StatusOK {
+		t.Fatalf("other user: Code = %d, want 200", w.Code)
+	}
+	// Without auth, clients are limited by IP.
+	for i := 0; i < 2; i++ {
+		rateLimited(r, http.MethodGet, "", "")
+	}
+	if w := rateLimited(r, http.MethodGet, "", ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("anonymous: Code = %d, want 429", w.Code)
+	}
+}
+
+func TestRateLimitPerNamespace(t *testing.T) {
+	r := newTestRateLimitRouter(t, config.RateLimitConfig{
+		PerNamespace: config.RateLimit{RequestsPerSecond: 0.1, Burst: 1},
+		MaxTracked:   100,
+	}, nil, nil)
+
+	if w := rateLimited(r, http.MethodGet, "alice", "team"); w.Code != http.StatusOK {
+		t.Fatalf("Code = %d, want 200", w.Code)
+	}
+	if w := rateLimited(r, http.MethodGet, "bob", "team"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("shared namespace: Code = %d, want 429", w.Code)
+	}
+
+	// Requests without a namespace do not share a bucket across users.
+	if w := rateLimited(r, http.MethodGet, "alice", ""); w.Code != http.StatusOK {
+		t.Fatalf("alice without namespace: Code = %d, want 200", w.Code)
+	}
+	if w := rateLimited(r, http.MethodGet, "bob", ""); w.Code != http.StatusOK {
+		t.Fatalf("bob without namespace: Code = %d, want 200", w.Code)
+	}
+	if w := rateLimited(r, http.MethodGet, "bob", ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("bob again: Code = %d, want 429", w.Code)
+	}
+}
+
+func TestRateLimitUploads(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	r := newTestRateLimitRouter(t, config.RateLimitConfig{
+		PerUser:    config.RateLimit{MaxUploads: 1},
+		MaxTracked: 100,
+	}, started, release)
+
+	done := make(chan int)
+	go func() { done <- rateLimited(r, http.MethodPut, "alice", "").Code }()
+	<-started
+
+	if w := rateLimited(r, http.MethodPut, "alice", ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second upload: Code = %d, want 429", w.Code)
+	}
+	if w := rateLimited(r, http.MethodGet, "alice", ""); w.Code != http.StatusOK {
+		t.Fatalf("GET during upload: Code = %d, want 200", w.Code)
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusCreated {
+		t.Fatalf("first upload: Code = %d, want 201", code)
+	}
+	go func() { done <- rateLimited(r, http.MethodPut, "alice", "").Code }()
+	<-started
+	if code := <-done; code != http.StatusCreated {
+		t.Fatalf("upload after release: Code = %d, want 201", code)
+	}
+}
+
+func TestLimiterSetKeepsUploadsInFlight(t *testing.T) {
+	s := newLimiterSet(rateScopeUser, config.RateLimit{MaxUploads: 1}, 1)
+
+	busy := s.get("alice")
+	if !s.acquireUpload(busy) {
+		t.Fatal("acquireUpload() = false")
+	}
+	s.get("bob")
+	s.get("carol")
+
+	if got := s.get("alice"); got != busy {
+		t.Fatal("entry with an upload in flight was evicted")
+	}
+	if s.acquireUpload(s.get("alice")) {
+		t.Fatal("second upload admitted past the limit")
+	}
+
+	s.releaseUpload(busy)
+	s.get("bob")
+	if len(s.entries) != 1 {
+		t.Fatalf("tracking %d entries after release, want 1", len(s.entries))
+	}
+}
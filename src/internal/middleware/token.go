@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// tokenPrefix marks tokens issued by GenerateToken so they are easy to spot
+// in logs and secret scanners.
+const tokenPrefix = "gct_"
+
+// tokenHashScheme is the only supported hash format: "sha256:<salt>:<digest>",
+// with hex encoded salt and digest. Tokens are long random strings, so a
+// salted SHA-256 is sufficient and keeps verification cheap.
+const tokenHashScheme = "sha256"
+
+type token struct {
+	salt      []byte
+	digest    []byte
+	expiresAt time.Time
+	identity  *Identity
+}
+
+func (t *token) matches(presented string) bool {
+	return subtle.ConstantTimeCompare(hashToken(t.salt, presented), t.digest) == 1
+}
+
+func (t *token) expired(now time.Time) bool {
+	return !t.expiresAt.IsZero() && now.After(t.expiresAt)
+}
+
+func hashToken(salt []byte, presented string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(presented))
+	return h.Sum(nil)
+}
+
+// parseTokenHash splits a stored token hash into salt and digest.
+func parseTokenHash(s string) (salt, digest []byte, err error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 || parts[0] != tokenHashScheme {
+		return nil, nil, fmt.Errorf("unsupported token hash format")
+	}
+	if salt, err = hex.DecodeString(parts[1]); err != nil || len(salt) == 0 {
+		return nil, nil, fmt.Errorf("invalid token hash salt")
+	}
+	if digest, err = hex.DecodeString(parts[2]); err != nil || len(digest) != sha256.Size {
+		return nil, nil, fmt.Errorf("invalid token hash digest")
+	}
+	return salt, digest, nil
+}
+
+// GenerateToken returns a new random bearer token and its salted hash for
+// use in the auth.tokens configuration.
+func GenerateToken() (plain, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	plain = tokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	hash = fmt.Sprintf("%s:%s:%s", tokenHashScheme,
+		hex.EncodeToString(salt), hex.EncodeToString(hashToken(salt, plain)))
+	return plain, hash, nil
+}
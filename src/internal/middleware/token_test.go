@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kevingruber/gradle-cache/internal/config"
+)
+
+// authorizeBearer runs Authorize for a request with the given bearer token.
+func authorizeBearer(authn *Authenticator, presented string, role Role) Decision {
+	req := httptest.NewRequest(http.MethodGet, "/cache/key", nil)
+	req.Header.Set("Authorization", "Bearer "+presented)
+	return authn.Authorize(req, "192.0.2.1", role, "")
+}
+
+func TestAuthorizeToken(t *testing.T) {
+	plain, hash, err := GenerateToken()
+	if err != nil {
+		t.Fatalf("GenerateToken() = %v", err)
+	}
+	if !strings.HasPrefix(plain, tokenPrefix) {
+		t.Fatalf("token %q lacks prefix %q", plain, tokenPrefix)
+	}
+	expiredPlain, expiredHash, err := GenerateToken()
+	if err != nil {
+		t.Fatalf("GenerateToken() = %v", err)
+	}
+
+	authn := newTestAuthenticator(t, config.AuthConfig{
+		Tokens: []config.TokenAuth{
+			{Name: "ci", Hash: hash, Role: config.RoleWrite},
+			{
+				Name:      "old",
+				Hash:      expiredHash,
+				Role:      config.RoleWrite,
+				ExpiresAt: time.Now().Add(-time.Hour).Format(time.RFC3339),
+			},
+		},
+	})
+
+	d := authorizeBearer(authn, plain, RoleWrite)
+	if d.Status != http.StatusOK || d.Identity.Username != "token:ci" {
+		t.Fatalf("valid token: Status = %d, Identity = %+v", d.Status, d.Identity)
+	}
+	if d := authorizeBearer(authn, plain, RoleAdmin); d.Status != http.StatusForbidden {
+		t.Fatalf("insufficient role: Status = %d, want 403", d.Status)
+	}
+	if d := authorizeBearer(authn, plain+"x", RoleRead); d.Status != http.StatusUnauthorized {
+		t.Fatalf("wrong token: Status = %d, want 401", d.Status)
+	}
+	if d := authorizeBearer(authn, expiredPlain, RoleRead); d.Status != http.StatusUnauthorized {
+		t.Fatalf("expired token: Status = %d, want 401", d.Status)
+	}
+}
+
+func TestParseTokenHash(t *testing.T) {
+	_, hash, err := GenerateToken()
+	if err != nil {
+		t.Fatalf("GenerateToken() = %v", err)
+	}
+	if _, _, err := parseTokenHash(hash); err != nil {
+		t.Fatalf("parseTokenHash(%q) = %v", hash, err)
+	}
+
+	for _, bad := range []string{
+		"",
+		"md5:00:00",
+		"sha256:zz:" + strings.Repeat("00", 32),
+		"sha256::" + strings.Repeat("00", 32),
+		"sha256:00:0000",
+	} {
+		if _, _, err := parseTokenHash(bad); err == nil {
+			t.Errorf("parseTokenHash(%q) succeeded", bad)
+		}
+	}
+}
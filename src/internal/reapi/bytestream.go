@@ -0,0 +1,254 @@
+package reapi
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/kevingruber/gradle-cache/internal/handler"
+	"github.com/kevingruber/gradle-cache/internal/middleware"
+	"github.com/kevingruber/gradle-cache/internal/reapi/bspb"
+	"github.com/kevingruber/gradle-cache/internal/reapi/repb"
+	"github.com/kevingruber/gradle-cache/internal/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// readChunkSize is the size of the data messages ByteStream Read sends.
+const readChunkSize = 64 * 1024
+
+// blobResource is a parsed ByteStream resource name.
+type blobResource struct {
+	instance string
+	digest   *repb.Digest
+}
+
+// parseResource parses "[{instance}/]blobs/{hash}/{size}" for reads and
+// "[{instance}/]uploads/{uuid}/blobs/{hash}/{size}[/{metadata}]" for writes.
+// Compressed blobs are not supported.
+func parseResource(name string, upload bool) (blobResource, error) {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		if part == "compressed-blobs" {
+			return blobResource{}, status.Error(codes.InvalidArgument, "compressed blobs are not supported")
+		}
+		if upload && part != "uploads" || !upload && part != "blobs" {
+			continue
+		}
+		rest := parts[i+1:]
+		if upload {
+			if len(rest) < 4 || rest[1] != "blobs" {
+				break
+			}
+			rest = rest[2:]
+		} else if len(rest) != 2 {
+			break
+		}
+		size, err := strconv.ParseInt(rest[1], 10, 64)
+		if err != nil {
+			break
+		}
+		r := blobResource{
+			instance: strings.Join(parts[:i], "/"),
+			digest:   &repb.Digest{Hash: rest[0], SizeBytes: size},
+		}
+		if err := checkDigest(r.digest); err != nil {
+			return blobResource{}, err
+		}
+		return r, nil
+	}
+	return blobResource{}, status.Errorf(codes.InvalidArgument, "invalid resource name %q", name)
+}
+
+// Read streams a blob in chunks of readChunkSize.
+func (s *Server) Read(req *bspb.ReadRequest, stream grpc.ServerStreamingServer[bspb.ReadResponse]) error {
+	ctx := stream.Context()
+	r, err := parseResource(req.GetResourceName(), false)
+	if err != nil {
+		return err
+	}
+	offset, limit := req.GetReadOffset(), req.GetReadLimit()
+	if offset < 0 || offset > r.digest.GetSizeBytes() {
+		return status.Errorf(codes.OutOfRange, "read offset %d is outside of the blob", offset)
+	}
+	if limit < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid read limit %d", limit)
+	}
+	store, err := s.authorize(ctx, middleware.RoleRead, r.instance)
+	if err != nil {
+		return err
+	}
+	key := handler.BazelKey(handler.BazelCAS, r.digest.GetHash())
+	getCallInfo(ctx).key = key
+	if r.digest.GetHash() == emptyHash {
+		return nil
+	}
+
+	reader, size, err := store.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			s.cacheMetrics.CacheMisses.Add(ctx, 1)
+			return status.Errorf(codes.NotFound, "%s not found", key)
+		}
+		return status.Errorf(codes.Internal, "failed to read %s: %v", key, err)
+	}
+	defer reader.Close()
+	s.cacheMetrics.CacheHits.Add(ctx, 1)
+
+	if offset > size {
+		return status.Errorf(codes.OutOfRange, "read offset %d is outside of the blob", offset)
+	}
+	if _, err := io.CopyN(io.Discard, reader, offset); err != nil {
+		return status.Errorf(codes.Internal, "failed to read %s: %v", key, err)
+	}
+	remaining := size - offset
+	if limit > 0 && limit < remaining {
+		remaining = limit
+	}
+	getCallInfo(ctx).size = remaining
+
+	buf := make([]byte, min(remaining, readChunkSize))
+	for remaining > 0 {
+		n, err := io.ReadFull(reader, buf[:min(remaining, int64(len(buf)))])
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to read %s: %v", key, err)
+		}
+		if err := stream.Send(&bspb.ReadResponse{Data: buf[:n]}); err != nil {
+			return err
+		}
+		remaining -= int64(n)
+	}
+	return nil
+}
+
+// Write stores the blob uploaded by a ByteStream Write. The data is streamed
+// into storage as it arrives and verified against the digest in the resource
+// name. A blob that is already stored completes the write early.
+func (s *Server) Write(stream grpc.ClientStreamingServer[bspb.WriteRequest, bspb.WriteResponse]) error {
+	ctx := stream.Context()
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	r, err := parseResource(req.GetResourceName(), true)
+	if err != nil {
+		return err
+	}
+	store, err := s.authorize(ctx, middleware.RoleWrite, r.instance)
+	if err != nil {
+		return err
+	}
+	size := r.digest.GetSizeBytes()
+	key := handler.BazelKey(handler.BazelCAS, r.digest.GetHash())
+	call := getCallInfo(ctx)
+	call.key = key
+	call.size = size
+	if size > s.cfg.MaxEntrySize {
+		return status.Errorf(codes.InvalidArgument, "entry of %d bytes exceeds the maximum of %d", size, s.cfg.MaxEntrySize)
+	}
+
+	if r.digest.GetHash() == emptyHash {
+		return stream.SendAndClose(&bspb.WriteResponse{CommittedSize: 0})
+	}
+	exists, err := store.Exists(ctx, key)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to check %s: %v", key, err)
+	}
+	if exists {
+		return stream.SendAndClose(&bspb.WriteResponse{CommittedSize: size})
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		want, _ := hex.DecodeString(r.digest.GetHash())
+		err := s.write(ctx, store, key, handler.NewDigestVerifier(pr, want, size), size)
+		// Unblock the receiving loop if storage stopped reading early.
+		pr.CloseWithError(io.ErrClosedPipe)
+		done <- err
+	}()
+
+	if err := s.receive(stream, req, pw, size); err != nil {
+		pw.CloseWithError(err)
+		cancel()
+		putErr := <-done
+		if errors.Is(err, io.ErrClosedPipe) {
+			// Storage rejected the upload before it was complete.
+			if putErr != nil {
+				return putErr
+			}
+			return status.Error(codes.Internal, "failed to store upload")
+		}
+		return err
+	}
+	pw.Close()
+	if err := <-done; err != nil {
+		return err
+	}
+	return stream.SendAndClose(&bspb.WriteResponse{CommittedSize: size})
+}
+
+// receive copies the data of the write requests, starting with req, to w
+// until the request that finishes the write.
+func (s *Server) receive(stream grpc.ClientStreamingServer[bspb.WriteRequest, bspb.WriteResponse], req *bspb.WriteRequest, w io.Writer, size int64) error {
+	var committed int64
+	for {
+		data := req.GetData()
+		if req.GetWriteOffset() != committed {
+			return status.Errorf(codes.InvalidArgument, "write offset %d does not match the committed size %d", req.GetWriteOffset(), committed)
+		}
+		if committed+int64(len(data)) > size {
+			return status.Errorf(codes.InvalidArgument, "upload is larger than %d bytes", size)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		committed += int64(len(data))
+		if req.GetFinishWrite() {
+			break
+		}
+		var err error
+		if req, err = stream.Recv(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return status.Error(codes.InvalidArgument, "upload ended without finishing the write")
+			}
+			return err
+		}
+	}
+	if committed != size {
+		return status.Errorf(codes.InvalidArgument, "upload has %d of %d bytes", committed, size)
+	}
+	return nil
+}
+
+// QueryWriteStatus reports whether a blob has been uploaded. Interrupted
+// uploads are not kept, so they cannot be resumed and are reported as not
+// found.
+func (s *Server) QueryWriteStatus(ctx context.Context, req *bspb.QueryWriteStatusRequest) (*bspb.QueryWriteStatusResponse, error) {
+	r, err := parseResource(req.GetResourceName(), true)
+	if err != nil {
+		return nil, err
+	}
+	store, err := s.authorize(ctx, middleware.RoleRead, r.instance)
+	if err != nil {
+		return nil, err
+	}
+	key := handler.BazelKey(handler.BazelCAS, r.digest.GetHash())
+	getCallInfo(ctx).key = key
+	if r.digest.GetHash() != emptyHash {
+		exists, err := store.Exists(ctx, key)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to check %s: %v", key, err)
+		}
+		if !exists {
+			return nil, status.Errorf(codes.NotFound, "%s not found", key)
+		}
+	}
+	return &bspb.QueryWriteStatusResponse{CommittedSize: r.digest.GetSizeBytes(), Complete: true}, nil
+}
@@ -0,0 +1,128 @@
+package reapi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/kevingruber/gradle-cache/internal/handler"
+	"github.com/kevingruber/gradle-cache/internal/reapi/bspb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseResource(t *testing.T) {
+	hash := digestOf([]byte("blob")).GetHash()
+
+	tests := []struct {
+		name     string
+		upload   bool
+		instance string
+		wantErr  bool
+	}{
+		{"blobs/" + hash + "/4", false, "", false},
+		{"ns/blobs/" + hash + "/4", false, "ns", false},
+		{"uploads/uuid/blobs/" + hash + "/4", true, "", false},
+		{"ns/uploads/uuid/blobs/" + hash + "/4/metadata", true, "ns", false},
+		{"blobs/" + hash + "/4", true, "", true},
+		{"blobs/" + hash, false, "", true},
+		{"blobs/" + hash + "/size", false, "", true},
+		{"blobs/abc/4", false, "", true},
+		{"compressed-blobs/zstd/" + hash + "/4", false, "", true},
+	}
+	for _, tt := range tests {
+		r, err := parseResource(tt.name, tt.upload)
+		if tt.wantErr {
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("parseResource(%q) = %v, want InvalidArgument", tt.name, err)
+			}
+			continue
+		}
+		if err != nil || r.instance != tt.instance || r.digest.GetHash() != hash || r.digest.GetSizeBytes() != 4 {
+			t.Errorf("parseResource(%q) = %+v, %v", tt.name, r, err)
+		}
+	}
+}
+
+func TestByteStreamRoundTrip(t *testing.T) {
+	conn, store := newTestServer(t)
+	bs := bspb.NewByteStreamClient(conn)
+	data := bytes.Repeat([]byte("0123456789"), readChunkSize/5)
+	d := digestOf(data)
+
+	write, err := bs.Write(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resource := "uploads/uuid/blobs/" + digestString(d)
+	half := len(data) / 2
+	write.Send(&bspb.WriteRequest{ResourceName: resource, Data: data[:half]})
+	write.Send(&bspb.WriteRequest{WriteOffset: int64(half), Data: data[half:], FinishWrite: true})
+	resp, err := write.CloseAndRecv()
+	if err != nil || resp.GetCommittedSize() != d.GetSizeBytes() {
+		t.Fatalf("Write = %v, %v", resp, err)
+	}
+	if ok, _ := store.Exists(t.Context(), handler.BazelKey(handler.BazelCAS, d.GetHash())); !ok {
+		t.Fatal("blob not stored under its HTTP key")
+	}
+
+	got := readAll(t, bs, &bspb.ReadRequest{ResourceName: "blobs/" + digestString(d)})
+	if !bytes.Equal(got, data) {
+		t.Fatalf("Read returned %d bytes, want %d", len(got), len(data))
+	}
+	got = readAll(t, bs, &bspb.ReadRequest{ResourceName: "blobs/" + digestString(d), ReadOffset: 10, ReadLimit: 5})
+	if string(got) != "01234" {
+		t.Fatalf("Read with offset and limit = %q", got)
+	}
+
+	qs, err := bs.QueryWriteStatus(t.Context(), &bspb.QueryWriteStatusRequest{ResourceName: resource})
+	if err != nil || !qs.GetComplete() || qs.GetCommittedSize() != d.GetSizeBytes() {
+		t.Fatalf("QueryWriteStatus = %v, %v", qs, err)
+	}
+}
+
+func TestByteStreamRejectsMismatchingUpload(t *testing.T) {
+	conn, store := newTestServer(t)
+	bs := bspb.NewByteStreamClient(conn)
+	d := digestOf([]byte("expected"))
+
+	write, err := bs.Write(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	write.Send(&bspb.WriteRequest{ResourceName: "uploads/uuid/blobs/" + digestString(d), Data: []byte("mismatch"), FinishWrite: true})
+	if _, err := write.CloseAndRecv(); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Write = %v, want InvalidArgument", err)
+	}
+	if ok, _ := store.Exists(t.Context(), handler.BazelKey(handler.BazelCAS, d.GetHash())); ok {
+		t.Fatal("mismatching upload was stored")
+	}
+
+	read, err := bs.Read(t.Context(), &bspb.ReadRequest{ResourceName: "blobs/" + digestString(d)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := read.Recv(); status.Code(err) != codes.NotFound {
+		t.Fatalf("Read of missing blob = %v, want NotFound", err)
+	}
+}
+
+func readAll(t *testing.T, bs bspb.ByteStreamClient, req *bspb.ReadRequest) []byte {
+	t.Helper()
+	stream, err := bs.Read(t.Context(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data []byte
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return data
+		}
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		data = append(data, resp.GetData()...)
+	}
+}
@@ -0,0 +1,352 @@
+// Package reapi serves the cache parts of Bazel's Remote Execution API over
+// gRPC: the Capabilities, ActionCache, ContentAddressableStorage and
+// ByteStream services. Entries are shared with the Bazel HTTP endpoints.
+package reapi
+
+// The generated messages and services are a subset of the upstream
+// definitions. GOOGLEAPIS is a checkout of github.com/googleapis/googleapis,
+// which provides google/rpc/status.proto.
+//go:generate protoc -I . -I ${GOOGLEAPIS} --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative repb/semver.proto repb/remote_execution.proto bspb/bytestream.proto
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/kevingruber/gradle-cache/internal/audit"
+	"github.com/kevingruber/gradle-cache/internal/handler"
+	"github.com/kevingruber/gradle-cache/internal/middleware"
+	"github.com/kevingruber/gradle-cache/internal/reapi/bspb"
+	"github.com/kevingruber/gradle-cache/internal/reapi/repb"
+	"github.com/kevingruber/gradle-cache/internal/storage"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements the REAPI cache services on top of a storage.
+type Server struct {
+	repb.UnimplementedCapabilitiesServer
+	repb.UnimplementedActionCacheServer
+	repb.UnimplementedContentAddressableStorageServer
+	bspb.UnimplementedByteStreamServer
+
+	grpc *grpc.Server
+	cfg  Config
+
+	storage storage.Storage
+	// authn is nil if authentication is disabled.
+	authn *middleware.Authenticator
+	// audit is nil if auditing is disabled.
+	audit        *audit.Logger
+	metrics      *middleware.Metrics
+	cacheMetrics *handler.Metrics
+	logger       zerolog.Logger
+}
+
+// Config holds the settings of a Server.
+type Config struct {
+	// MaxEntrySize is the largest blob or action result accepted.
+	MaxEntrySize int64
+	// MaxBatchSize is the largest total size of a batch request, and
+	// bounds the size of gRPC messages.
+	MaxBatchSize int64
+	// Namespaces allows instance names, which select the namespace.
+	Namespaces         bool
+	FallbackNamespaces []string
+	// TLS enables TLS, including client certificate verification.
+	TLS *tls.Config
+}
+
+// NewServer creates a REAPI server. authn, auditLogger and metrics may be
+// nil to disable authentication, auditing and request metrics.
+func NewServer(store storage.Storage, cfg Config, authn *middleware.Authenticator, auditLogger *audit.Logger, metrics *middleware.Metrics, logger zerolog.Logger) (*Server, error) {
+	cacheMetrics, err := handler.NewMetrics()
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		cfg:          cfg,
+		storage:      store,
+		authn:        authn,
+		audit:        auditLogger,
+		metrics:      metrics,
+		cacheMetrics: cacheMetrics,
+		logger:       logger,
+	}
+
+	// Leave room for the message framing around the batched blobs.
+	maxMessageSize := int(cfg.MaxBatchSize) + 1<<20
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(maxMessageSize),
+		grpc.MaxSendMsgSize(maxMessageSize),
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	}
+	if cfg.TLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(cfg.TLS)))
+	}
+	s.grpc = grpc.NewServer(opts...)
+	repb.RegisterCapabilitiesServer(s.grpc, s)
+	repb.RegisterActionCacheServer(s.grpc, s)
+	repb.RegisterContentAddressableStorageServer(s.grpc, s)
+	bspb.RegisterByteStreamServer(s.grpc, s)
+	return s, nil
+}
+
+// Serve accepts connections on lis until Stop is called.
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// Stop stops the server, waiting for running calls until ctx is done.
+func (s *Server) Stop(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.grpc.Stop()
+	}
+}
+
+// callInfo is attached to the context of every call by the interceptors and
+// filled in as the call is authorized.
+type callInfo struct {
+	identity  *middleware.Identity
+	namespace string
+	key       string
+	size      int64
+}
+
+type callInfoKey struct{}
+
+func getCallInfo(ctx context.Context) *callInfo {
+	if info, ok := ctx.Value(callInfoKey{}).(*callInfo); ok {
+		return info
+	}
+	return &callInfo{}
+}
+
+// writeMethods are audited.
+var writeMethods = map[string]string{
+	"/build.bazel.remote.execution.v2.ActionCache/UpdateActionResult":             "reapi.ac.update",
+	"/build.bazel.remote.execution.v2.ContentAddressableStorage/BatchUpdateBlobs": "reapi.cas.batch_update",
+	"/google.bytestream.ByteStream/Write":                                         "reapi.cas.write",
+}
+
+func (s *Server) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, h grpc.UnaryHandler) (any, error) {
+	ctx, call := s.startCall(ctx)
+	start := time.Now()
+	resp, err := h(ctx, req)
+	s.finishCall(ctx, call, info.FullMethod, start, err)
+	return resp, err
+}
+
+func (s *Server) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+	ctx, call := s.startCall(ss.Context())
+	start := time.Now()
+	err := h(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	s.finishCall(ctx, call, info.FullMethod, start, err)
+	return err
+}
+
+func (s *Server) startCall(ctx context.Context) (context.Context, *callInfo) {
+	call := &callInfo{size: -1}
+	return context.WithValue(ctx, callInfoKey{}, call), call
+}
+
+// finishCall records metrics, the request log and the audit log for a call.
+func (s *Server) finishCall(ctx context.Context, call *callInfo, method string, start time.Time, err error) {
+	code := status.Code(err)
+	duration := time.Since(start)
+
+	if s.metrics != nil {
+		s.metrics.RequestsTotal.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("method", method),
+			attribute.String("status", code.String())))
+		s.metrics.RequestDuration.Record(ctx, duration.Seconds(), metric.WithAttributes(
+			attribute.String("method", method)))
+	}
+
+	var event *zerolog.Event
+	switch httpStatus(code) {
+	case http.StatusOK:
+		event = s.logger.Info()
+	case http.StatusInternalServerError:
+		event = s.logger.Error()
+	default:
+		event = s.logger.Warn()
+	}
+	event = event.
+		Str("method", method).
+		Str("status", code.String()).
+		Float64("latency", float64(duration.Nanoseconds())/1e6).
+		Str("client_ip", clientIP(ctx))
+	if call.identity != nil {
+		event.Str("user", call.identity.Username)
+	}
+	if call.namespace != "" {
+		event.Str("namespace", call.namespace)
+	}
+	if err != nil && code != codes.NotFound {
+		event.Str("error", status.Convert(err).Message())
+	}
+	event.Msg("grpc request")
+
+	action, audited := writeMethods[method]
+	if s.audit == nil || !audited {
+		return
+	}
+	r := audit.Record{
+		Action:    action,
+		Method:    "GRPC",
+		Path:      method,
+		ClientIP:  clientIP(ctx),
+		Status:    httpStatus(code),
+		Identity:  call.identity,
+		Namespace: call.namespace,
+		Key:       call.key,
+		Size:      call.size,
+	}
+	if err != nil {
+		r.Error = status.Convert(err).Message()
+	}
+	s.audit.Record(r)
+}
+
+// httpStatus maps a gRPC code to the HTTP status logged for it.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Canceled:
+		return 499
+	}
+	return http.StatusInternalServerError
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// authorize checks the credentials of the call for role and returns the
+// storage of the namespace selected by instance.
+func (s *Server) authorize(ctx context.Context, role middleware.Role, instance string) (storage.Storage, error) {
+	call := getCallInfo(ctx)
+	if instance != "" {
+		if !s.cfg.Namespaces {
+			return nil, status.Error(codes.InvalidArgument, "instance names require namespaces to be enabled")
+		}
+		if !middleware.ValidNamespace(instance) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid instance name %q", instance)
+		}
+	}
+
+	ns := instance
+	if s.authn != nil {
+		d := s.authn.Authorize(authRequest(ctx), clientIP(ctx), role, instance)
+		call.identity = d.Identity
+		call.namespace = d.Namespace
+		switch d.Status {
+		case http.StatusOK:
+		case http.StatusUnauthorized:
+			return nil, status.Error(codes.Unauthenticated, "authentication required")
+		case http.StatusTooManyRequests:
+			return nil, status.Errorf(codes.ResourceExhausted, "too many failed authentication attempts, retry in %s", d.RetryAfter.Round(time.Second))
+		default:
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		}
+		ns = d.Namespace
+	}
+	call.namespace = ns
+
+	store, err := handler.ScopedStorage(s.storage, ns, s.cfg.FallbackNamespaces)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return store, nil
+}
+
+// authRequest builds the HTTP request the authenticator inspects from the
+// metadata and TLS state of a call.
+func authRequest(ctx context.Context) *http.Request {
+	r := (&http.Request{Header: make(http.Header)}).WithContext(ctx)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, v := range md.Get("authorization") {
+			r.Header.Add("Authorization", v)
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			r.TLS = &info.State
+		}
+	}
+	return r
+}
+
+// checkDigestFunction rejects digest functions other than SHA-256.
+func checkDigestFunction(fn repb.DigestFunction_Value) error {
+	if fn != repb.DigestFunction_UNKNOWN && fn != repb.DigestFunction_SHA256 {
+		return status.Error(codes.InvalidArgument, "only SHA-256 digests are supported")
+	}
+	return nil
+}
+
+func checkDigest(d *repb.Digest) error {
+	if !handler.ValidBazelHash(d.GetHash()) {
+		return status.Errorf(codes.InvalidArgument, "invalid digest hash %q", d.GetHash())
+	}
+	if d.GetSizeBytes() < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid digest size %d", d.GetSizeBytes())
+	}
+	return nil
+}
+
+// digestString formats d as hash/size, as in resource names.
+func digestString(d *repb.Digest) string {
+	return fmt.Sprintf("%s/%d", d.GetHash(), d.GetSizeBytes())
+}
+
+// emptyHash is the SHA-256 of no data. REAPI servers must treat the empty
+// blob as always present.
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
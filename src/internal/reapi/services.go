@@ -0,0 +1,270 @@
+package reapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/kevingruber/gradle-cache/internal/handler"
+	"github.com/kevingruber/gradle-cache/internal/middleware"
+	"github.com/kevingruber/gradle-cache/internal/reapi/repb"
+	"github.com/kevingruber/gradle-cache/internal/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// findMissingConcurrency bounds the existence checks FindMissingBlobs runs
+// in parallel.
+const findMissingConcurrency = 16
+
+// GetCapabilities advertises a cache-only server. GetTree and the
+// Execution service are not implemented and are answered with
+// Unimplemented.
+func (s *Server) GetCapabilities(ctx context.Context, req *repb.GetCapabilitiesRequest) (*repb.ServerCapabilities, error) {
+	if _, err := s.authorize(ctx, middleware.RoleRead, req.GetInstanceName()); err != nil {
+		return nil, err
+	}
+	return &repb.ServerCapabilities{
+		CacheCapabilities: &repb.CacheCapabilities{
+			DigestFunctions: []repb.DigestFunction_Value{repb.DigestFunction_SHA256},
+			ActionCacheUpdateCapabilities: &repb.ActionCacheUpdateCapabilities{
+				UpdateEnabled: true,
+			},
+			MaxBatchTotalSizeBytes:      s.cfg.MaxBatchSize,
+			SymlinkAbsolutePathStrategy: repb.SymlinkAbsolutePathStrategy_DISALLOWED,
+		},
+		LowApiVersion:  &repb.SemVer{Major: 2},
+		HighApiVersion: &repb.SemVer{Major: 2, Minor: 3},
+	}, nil
+}
+
+// GetActionResult returns a stored action result. Entries that are not
+// ActionResult messages, which the HTTP endpoints accept, are reported as
+// missing.
+func (s *Server) GetActionResult(ctx context.Context, req *repb.GetActionResultRequest) (*repb.ActionResult, error) {
+	if err := checkDigestFunction(req.GetDigestFunction()); err != nil {
+		return nil, err
+	}
+	if err := checkDigest(req.GetActionDigest()); err != nil {
+		return nil, err
+	}
+	store, err := s.authorize(ctx, middleware.RoleRead, req.GetInstanceName())
+	if err != nil {
+		return nil, err
+	}
+	key := handler.BazelKey(handler.BazelAC, req.GetActionDigest().GetHash())
+	getCallInfo(ctx).key = key
+
+	data, err := s.read(ctx, store, key)
+	if err != nil {
+		return nil, err
+	}
+	result := &repb.ActionResult{}
+	if err := proto.Unmarshal(data, result); err != nil {
+		return nil, status.Errorf(codes.NotFound, "%s is not an action result", key)
+	}
+	return result, nil
+}
+
+// UpdateActionResult stores an action result. Fields outside of the
+// generated subset are stored as they were sent.
+func (s *Server) UpdateActionResult(ctx context.Context, req *repb.UpdateActionResultRequest) (*repb.ActionResult, error) {
+	if err := checkDigestFunction(req.GetDigestFunction()); err != nil {
+		return nil, err
+	}
+	if err := checkDigest(req.GetActionDigest()); err != nil {
+		return nil, err
+	}
+	store, err := s.authorize(ctx, middleware.RoleWrite, req.GetInstanceName())
+	if err != nil {
+		return nil, err
+	}
+	result := req.GetActionResult()
+	if result == nil {
+		result = &repb.ActionResult{}
+	}
+	data, err := proto.Marshal(result)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid action result: %v", err)
+	}
+	key := handler.BazelKey(handler.BazelAC, req.GetActionDigest().GetHash())
+	call := getCallInfo(ctx)
+	call.key = key
+	call.size = int64(len(data))
+
+	if err := s.write(ctx, store, key, bytes.NewReader(data), int64(len(data))); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindMissingBlobs reports which of the requested blobs are not stored.
+func (s *Server) FindMissingBlobs(ctx context.Context, req *repb.FindMissingBlobsRequest) (*repb.FindMissingBlobsResponse, error) {
+	if err := checkDigestFunction(req.GetDigestFunction()); err != nil {
+		return nil, err
+	}
+	digests := req.GetBlobDigests()
+	for _, d := range digests {
+		if err := checkDigest(d); err != nil {
+			return nil, err
+		}
+	}
+	store, err := s.authorize(ctx, middleware.RoleRead, req.GetInstanceName())
+	if err != nil {
+		return nil, err
+	}
+
+	missing := make([]bool, len(digests))
+	errs := make([]error, len(digests))
+	sem := make(chan struct{}, findMissingConcurrency)
+	var wg sync.WaitGroup
+	for i, d := range digests {
+		if d.GetHash() == emptyHash {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			exists, err := store.Exists(ctx, handler.BazelKey(handler.BazelCAS, d.GetHash()))
+			missing[i], errs[i] = !exists, err
+		}()
+	}
+	wg.Wait()
+
+	resp := &repb.FindMissingBlobsResponse{}
+	for i, d := range digests {
+		if errs[i] != nil {
+			return nil, status.Errorf(codes.Internal, "failed to check blob %s: %v", digestString(d), errs[i])
+		}
+		if missing[i] {
+			resp.MissingBlobDigests = append(resp.MissingBlobDigests, d)
+		}
+	}
+	return resp, nil
+}
+
+// BatchUpdateBlobs stores the blobs of a batch, reporting the result of
+// each upload separately.
+func (s *Server) BatchUpdateBlobs(ctx context.Context, req *repb.BatchUpdateBlobsRequest) (*repb.BatchUpdateBlobsResponse, error) {
+	if err := checkDigestFunction(req.GetDigestFunction()); err != nil {
+		return nil, err
+	}
+	store, err := s.authorize(ctx, middleware.RoleWrite, req.GetInstanceName())
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	resp := &repb.BatchUpdateBlobsResponse{Responses: make([]*repb.BatchUpdateBlobsResponse_Response, len(req.GetRequests()))}
+	for i, r := range req.GetRequests() {
+		total += int64(len(r.GetData()))
+		resp.Responses[i] = &repb.BatchUpdateBlobsResponse_Response{
+			Digest: r.GetDigest(),
+			Status: status.Convert(s.updateBlob(ctx, store, r)).Proto(),
+		}
+	}
+	getCallInfo(ctx).size = total
+	return resp, nil
+}
+
+func (s *Server) updateBlob(ctx context.Context, store storage.Storage, r *repb.BatchUpdateBlobsRequest_Request) error {
+	d := r.GetDigest()
+	if err := checkDigest(d); err != nil {
+		return err
+	}
+	if r.GetCompressor() != repb.Compressor_IDENTITY {
+		return status.Error(codes.InvalidArgument, "compressed blobs are not supported")
+	}
+	data := r.GetData()
+	if int64(len(data)) != d.GetSizeBytes() {
+		return status.Errorf(codes.InvalidArgument, "blob %s has %d bytes", digestString(d), len(data))
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != d.GetHash() {
+		return status.Errorf(codes.InvalidArgument, "blob %s does not match its digest", digestString(d))
+	}
+	return s.write(ctx, store, handler.BazelKey(handler.BazelCAS, d.GetHash()), bytes.NewReader(data), int64(len(data)))
+}
+
+// BatchReadBlobs returns the blobs of a batch, reporting the result of each
+// read separately.
+func (s *Server) BatchReadBlobs(ctx context.Context, req *repb.BatchReadBlobsRequest) (*repb.BatchReadBlobsResponse, error) {
+	if err := checkDigestFunction(req.GetDigestFunction()); err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, d := range req.GetDigests() {
+		if err := checkDigest(d); err != nil {
+			return nil, err
+		}
+		total += d.GetSizeBytes()
+	}
+	if total > s.cfg.MaxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "batch of %d bytes exceeds the maximum of %d", total, s.cfg.MaxBatchSize)
+	}
+	store, err := s.authorize(ctx, middleware.RoleRead, req.GetInstanceName())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &repb.BatchReadBlobsResponse{Responses: make([]*repb.BatchReadBlobsResponse_Response, len(req.GetDigests()))}
+	for i, d := range req.GetDigests() {
+		var data []byte
+		var err error
+		if d.GetHash() != emptyHash {
+			data, err = s.read(ctx, store, handler.BazelKey(handler.BazelCAS, d.GetHash()))
+		}
+		resp.Responses[i] = &repb.BatchReadBlobsResponse_Response{
+			Digest: d,
+			Data:   data,
+			Status: status.Convert(err).Proto(),
+		}
+	}
+	return resp, nil
+}
+
+// read returns a whole entry, counting the lookup as a cache hit or miss.
+func (s *Server) read(ctx context.Context, store storage.Storage, key string) ([]byte, error) {
+	reader, size, err := store.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		s.cacheMetrics.CacheMisses.Add(ctx, 1)
+		return nil, status.Errorf(codes.NotFound, "%s not found", key)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to read %s: %v", key, err)
+	}
+	defer reader.Close()
+	s.cacheMetrics.CacheHits.Add(ctx, 1)
+
+	data, err := io.ReadAll(io.LimitReader(reader, size))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to read %s: %v", key, err)
+	}
+	return data, nil
+}
+
+// write stores an entry, mapping storage errors to gRPC codes.
+func (s *Server) write(ctx context.Context, store storage.Storage, key string, reader io.Reader, size int64) error {
+	if size > s.cfg.MaxEntrySize {
+		return status.Errorf(codes.InvalidArgument, "entry of %d bytes exceeds the maximum of %d", size, s.cfg.MaxEntrySize)
+	}
+	err := store.Put(ctx, key, reader, size)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, handler.ErrDigestMismatch):
+		return status.Errorf(codes.InvalidArgument, "upload does not match its digest")
+	case errors.Is(err, storage.ErrQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, "namespace quota exceeded")
+	case status.Code(err) != codes.Unknown:
+		return err
+	}
+	return status.Error(codes.Internal, fmt.Sprintf("failed to store %s: %v", key, err))
+}
@@ -0,0 +1,259 @@
+package reapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"testing"
+
+	"github.com/kevingruber/gradle-cache/internal/handler"
+	"github.com/kevingruber/gradle-cache/internal/reapi/repb"
+	"github.com/kevingruber/gradle-cache/internal/storage"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+const testMaxBatchSize = 1024
+
+// newTestServer serves a REAPI server over an in-memory connection and
+// returns a client connection to it and the storage it serves.
+func newTestServer(t *testing.T) (*grpc.ClientConn, storage.Storage) {
+	t.Helper()
+	store, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(store, Config{MaxEntrySize: 1 << 20, MaxBatchSize: testMaxBatchSize}, nil, nil, nil, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	go s.Serve(lis)
+	t.Cleanup(func() { s.Stop(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, store
+}
+
+func digestOf(data []byte) *repb.Digest {
+	sum := sha256.Sum256(data)
+	return &repb.Digest{Hash: hex.EncodeToString(sum[:]), SizeBytes: int64(len(data))}
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestWireFormat checks the generated messages against encodings written
+// out by hand from the upstream field numbers.
+func TestWireFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     proto.Message
+		encoded string
+	}{
+		{
+			"FindMissingBlobsRequest",
+			&repb.FindMissingBlobsRequest{
+				BlobDigests:    []*repb.Digest{{Hash: "ab", SizeBytes: 3}},
+				DigestFunction: repb.DigestFunction_SHA256,
+			},
+			"1206" + "0a026162" + "1003" + "1801",
+		},
+		{
+			"ServerCapabilities",
+			&repb.ServerCapabilities{
+				CacheCapabilities: &repb.CacheCapabilities{
+					DigestFunctions:               []repb.DigestFunction_Value{repb.DigestFunction_SHA256},
+					ActionCacheUpdateCapabilities: &repb.ActionCacheUpdateCapabilities{UpdateEnabled: true},
+					MaxBatchTotalSizeBytes:        1024,
+					SymlinkAbsolutePathStrategy:   repb.SymlinkAbsolutePathStrategy_DISALLOWED,
+				},
+				LowApiVersion:  &repb.SemVer{Major: 2},
+				HighApiVersion: &repb.SemVer{Major: 2, Minor: 3},
+			},
+			"0a0c" + "0a0101" + "12020801" + "208008" + "2801" + "22020802" + "2a0408021003",
+		},
+		{
+			"BatchUpdateBlobsRequest",
+			&repb.BatchUpdateBlobsRequest{
+				InstanceName: "ns",
+				Requests: []*repb.BatchUpdateBlobsRequest_Request{
+					{Digest: &repb.Digest{Hash: "ab", SizeBytes: 1}, Data: []byte("x")},
+				},
+			},
+			"0a026e73" + "120b" + "0a06" + "0a026162" + "1001" + "120178",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := mustDecodeHex(t, tt.encoded)
+			got, err := proto.Marshal(tt.msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("encoded as %x, want %x", got, want)
+			}
+			decoded := tt.msg.ProtoReflect().New().Interface()
+			if err := proto.Unmarshal(want, decoded); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !proto.Equal(decoded, tt.msg) {
+				t.Fatalf("decoded as %v, want %v", decoded, tt.msg)
+			}
+		})
+	}
+}
+
+func TestGetCapabilities(t *testing.T) {
+	conn, _ := newTestServer(t)
+
+	caps, err := repb.NewCapabilitiesClient(conn).GetCapabilities(t.Context(), &repb.GetCapabilitiesRequest{})
+	if err != nil {
+		t.Fatalf("GetCapabilities: %v", err)
+	}
+	cache := caps.GetCacheCapabilities()
+	if len(cache.GetDigestFunctions()) != 1 || cache.GetDigestFunctions()[0] != repb.DigestFunction_SHA256 {
+		t.Fatalf("digest functions = %v, want SHA256", cache.GetDigestFunctions())
+	}
+	if !cache.GetActionCacheUpdateCapabilities().GetUpdateEnabled() || cache.GetMaxBatchTotalSizeBytes() != testMaxBatchSize {
+		t.Fatalf("cache capabilities = %v", cache)
+	}
+}
+
+func TestActionCache(t *testing.T) {
+	conn, store := newTestServer(t)
+	ac := repb.NewActionCacheClient(conn)
+	action := digestOf([]byte("action"))
+
+	_, err := ac.GetActionResult(t.Context(), &repb.GetActionResultRequest{ActionDigest: action})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("GetActionResult before update = %v, want NotFound", err)
+	}
+
+	// exit_code 1, followed by an execution_metadata field (9) that is not
+	// part of the generated subset.
+	encoded := mustDecodeHex(t, "2001"+"4a03"+"0a0178")
+	result := &repb.ActionResult{}
+	if err := proto.Unmarshal(encoded, result); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ac.UpdateActionResult(t.Context(), &repb.UpdateActionResultRequest{ActionDigest: action, ActionResult: result}); err != nil {
+		t.Fatalf("UpdateActionResult: %v", err)
+	}
+
+	got, err := ac.GetActionResult(t.Context(), &repb.GetActionResultRequest{ActionDigest: action})
+	if err != nil {
+		t.Fatalf("GetActionResult: %v", err)
+	}
+	if b, _ := proto.Marshal(got); !bytes.Equal(b, encoded) {
+		t.Fatalf("GetActionResult = %x, want %x", b, encoded)
+	}
+
+	// The entry is shared with the HTTP action cache.
+	r, _, err := store.Get(t.Context(), handler.BazelKey(handler.BazelAC, action.GetHash()))
+	if err != nil {
+		t.Fatalf("entry not stored under its HTTP key: %v", err)
+	}
+	defer r.Close()
+	var stored bytes.Buffer
+	stored.ReadFrom(r)
+	if !bytes.Equal(stored.Bytes(), encoded) {
+		t.Fatalf("stored %x, want %x", stored.Bytes(), encoded)
+	}
+}
+
+func TestContentAddressableStorage(t *testing.T) {
+	conn, _ := newTestServer(t)
+	cas := repb.NewContentAddressableStorageClient(conn)
+	blob := []byte("blob")
+	d := digestOf(blob)
+	empty := digestOf(nil)
+
+	missing, err := cas.FindMissingBlobs(t.Context(), &repb.FindMissingBlobsRequest{BlobDigests: []*repb.Digest{d, empty}})
+	if err != nil {
+		t.Fatalf("FindMissingBlobs: %v", err)
+	}
+	if len(missing.GetMissingBlobDigests()) != 1 || !proto.Equal(missing.GetMissingBlobDigests()[0], d) {
+		t.Fatalf("missing = %v, want only %v", missing.GetMissingBlobDigests(), d)
+	}
+
+	wrong := &repb.Digest{Hash: d.GetHash(), SizeBytes: 5}
+	updated, err := cas.BatchUpdateBlobs(t.Context(), &repb.BatchUpdateBlobsRequest{Requests: []*repb.BatchUpdateBlobsRequest_Request{
+		{Digest: d, Data: blob},
+		{Digest: wrong, Data: []byte("other")},
+	}})
+	if err != nil {
+		t.Fatalf("BatchUpdateBlobs: %v", err)
+	}
+	if codes.Code(updated.GetResponses()[0].GetStatus().GetCode()) != codes.OK {
+		t.Fatalf("update of valid blob = %v", updated.GetResponses()[0].GetStatus())
+	}
+	if codes.Code(updated.GetResponses()[1].GetStatus().GetCode()) != codes.InvalidArgument {
+		t.Fatalf("update of mismatching blob = %v, want InvalidArgument", updated.GetResponses()[1].GetStatus())
+	}
+
+	read, err := cas.BatchReadBlobs(t.Context(), &repb.BatchReadBlobsRequest{Digests: []*repb.Digest{d, empty, digestOf([]byte("missing"))}})
+	if err != nil {
+		t.Fatalf("BatchReadBlobs: %v", err)
+	}
+	responses := read.GetResponses()
+	if !bytes.Equal(responses[0].GetData(), blob) || codes.Code(responses[0].GetStatus().GetCode()) != codes.OK {
+		t.Fatalf("read of stored blob = %q, %v", responses[0].GetData(), responses[0].GetStatus())
+	}
+	if len(responses[1].GetData()) != 0 || codes.Code(responses[1].GetStatus().GetCode()) != codes.OK {
+		t.Fatalf("read of empty blob = %q, %v", responses[1].GetData(), responses[1].GetStatus())
+	}
+	if codes.Code(responses[2].GetStatus().GetCode()) != codes.NotFound {
+		t.Fatalf("read of missing blob = %v, want NotFound", responses[2].GetStatus())
+	}
+
+	_, err = cas.BatchReadBlobs(t.Context(), &repb.BatchReadBlobsRequest{Digests: []*repb.Digest{{Hash: d.GetHash(), SizeBytes: testMaxBatchSize + 1}}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("oversized batch = %v, want InvalidArgument", err)
+	}
+}
+
+func TestRejectsInvalidRequests(t *testing.T) {
+	conn, _ := newTestServer(t)
+	cas := repb.NewContentAddressableStorageClient(conn)
+	d := digestOf([]byte("blob"))
+
+	tests := []struct {
+		name string
+		req  *repb.FindMissingBlobsRequest
+	}{
+		{"digest function", &repb.FindMissingBlobsRequest{BlobDigests: []*repb.Digest{d}, DigestFunction: repb.DigestFunction_SHA1}},
+		{"hash", &repb.FindMissingBlobsRequest{BlobDigests: []*repb.Digest{{Hash: "abc", SizeBytes: 1}}}},
+		{"size", &repb.FindMissingBlobsRequest{BlobDigests: []*repb.Digest{{Hash: d.GetHash(), SizeBytes: -1}}}},
+		{"instance without namespaces", &repb.FindMissingBlobsRequest{InstanceName: "ns", BlobDigests: []*repb.Digest{d}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := cas.FindMissingBlobs(t.Context(), tt.req); status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("FindMissingBlobs = %v, want InvalidArgument", err)
+			}
+		})
+	}
+}
@@ -2,14 +2,20 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kevingruber/gradle-cache/internal/audit"
 	"github.com/kevingruber/gradle-cache/internal/config"
 	"github.com/kevingruber/gradle-cache/internal/handler"
 	"github.com/kevingruber/gradle-cache/internal/middleware"
+	"github.com/kevingruber/gradle-cache/internal/reapi"
 	"github.com/kevingruber/gradle-cache/internal/storage"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
@@ -22,6 +28,11 @@ type Server struct {
 	storage storage.Storage
 	logger  zerolog.Logger
 	metrics *middleware.Metrics
+	authn   *middleware.Authenticator
+	limiter *middleware.RateLimiter
+	audit   *audit.Logger
+	// reapi is nil unless the Bazel gRPC cache is enabled.
+	reapi *reapi.Server
 }
 
 // New creates a new server instance.
@@ -40,6 +51,40 @@ func New(cfg *config.Config, store storage.Storage, logger zerolog.Logger) *Serv
 		logger:  logger,
 	}
 
+	// Without trusted proxies, X-Forwarded-For is ignored and the client IP
+	// is the peer address.
+	proxies := cfg.Server.TrustedProxies
+	if len(proxies) == 0 {
+		proxies = nil
+	}
+	if err := s.router.SetTrustedProxies(proxies); err != nil {
+		logger.Fatal().Err(err).Msg("Invalid trusted proxies")
+	}
+
+	if cfg.Auth.Enabled {
+		authn, err := middleware.NewAuthenticator(cfg.Auth, logger)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to initialize authentication")
+		}
+		s.authn = authn
+	}
+
+	if cfg.Audit.Enabled {
+		auditLogger, err := audit.New(cfg.Audit)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to initialize audit log")
+		}
+		s.audit = auditLogger
+	}
+
+	if cfg.RateLimit.Enabled {
+		limiter, err := middleware.NewRateLimiter(cfg.RateLimit)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to initialize rate limiting")
+		}
+		s.limiter = limiter
+	}
+
 	// Initialize metrics if enabled
 	if cfg.Metrics.Enabled {
 		metrics, err := middleware.NewMetrics()
@@ -50,9 +95,41 @@ func New(cfg *config.Config, store storage.Storage, logger zerolog.Logger) *Serv
 	}
 
 	s.setupRoutes()
+
+	if cfg.Protocols.Bazel.GRPC.Enabled {
+		reapiServer, err := s.newREAPIServer()
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to initialize Bazel gRPC cache")
+		}
+		s.reapi = reapiServer
+	}
 	return s
 }
 
+// newREAPIServer creates the Bazel gRPC cache, sharing the storage, auth,
+// audit log and metrics of the HTTP server.
+func (s *Server) newREAPIServer() (*reapi.Server, error) {
+	cfg := reapi.Config{
+		MaxEntrySize:       s.cfg.MaxEntrySizeBytes(),
+		MaxBatchSize:       s.cfg.Protocols.Bazel.GRPC.MaxBatchSizeBytes(),
+		Namespaces:         s.cfg.Namespaces.Enabled,
+		FallbackNamespaces: s.cfg.Namespaces.Fallback,
+	}
+	if s.cfg.Server.TLS.Enabled {
+		tlsConfig, err := newTLSConfig(s.cfg.Server.TLS)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := tls.LoadX509KeyPair(s.cfg.Server.TLS.CertFile, s.cfg.Server.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load server certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		cfg.TLS = tlsConfig
+	}
+	return reapi.NewServer(s.storage, cfg, s.authn, s.audit, s.metrics, s.logger)
+}
+
 // setupRoutes configures all HTTP routes.
 func (s *Server) setupRoutes() {
 	// Recovery middleware
@@ -78,7 +155,10 @@ func (s *Server) setupRoutes() {
 	// Cache endpoints
 	cacheHandler, err := handler.NewCacheHandler(
 		s.storage,
-		s.cfg.MaxEntrySizeBytes(),
+		handler.CacheHandlerConfig{
+			MaxEntrySize:       s.cfg.MaxEntrySizeBytes(),
+			FallbackNamespaces: s.cfg.Namespaces.Fallback,
+		},
 		s.logger,
 	)
 
@@ -88,19 +168,134 @@ func (s *Server) setupRoutes() {
 
 	// Create cache group with optional auth
 	cacheGroup := s.router.Group("/cache")
+	s.registerCacheRoutes(cacheGroup, cacheHandler)
+
+	bazelHandler := handler.NewBazelHandler(cacheHandler)
+	if s.cfg.Protocols.Bazel.Enabled {
+		s.registerBazelRoutes(&s.router.RouterGroup, bazelHandler)
+	}
+	nxHandler := handler.NewNxHandler(cacheHandler)
+	if s.cfg.Protocols.Nx.Enabled {
+		s.registerNxRoutes(s.router.Group("/v1/cache"), nxHandler)
+	}
+	webDAVHandler := handler.NewWebDAVHandler(cacheHandler)
+	if s.cfg.Protocols.WebDAV.Enabled {
+		s.registerWebDAVRoutes(s.router.Group("/dav"), webDAVHandler)
+	}
+	if s.cfg.Protocols.Turbo.Enabled {
+		// Turborepo selects the namespace by team rather than by path.
+		turboGroup := s.router.Group("/v8/artifacts")
+		if s.cfg.Namespaces.Enabled {
+			turboGroup.Use(middleware.QueryNamespace("slug", "teamId"))
+		} else {
+			turboGroup.Use(middleware.IgnoredQueryNamespace(s.logger, "slug", "teamId"))
+		}
+		s.registerTurboRoutes(turboGroup, handler.NewTurboHandler(cacheHandler))
+	}
+
+	// Namespaced cache groups isolate entries per exercise
+	if s.cfg.Namespaces.Enabled {
+		if _, ok := s.storage.(storage.NamespacedStorage); !ok {
+			s.logger.Fatal().Msg("Storage backend does not support namespaces")
+		}
+		for _, ns := range s.cfg.Namespaces.Fallback {
+			if !middleware.ValidNamespace(ns) {
+				s.logger.Fatal().Str("namespace", ns).Msg("Invalid fallback namespace")
+			}
+		}
+		nsGroup := s.router.Group("/ns/:namespace", middleware.Namespace())
+		s.registerCacheRoutes(nsGroup.Group("/cache"), cacheHandler)
+		if s.cfg.Protocols.Bazel.Enabled {
+			s.registerBazelRoutes(nsGroup, bazelHandler)
+		}
+		if s.cfg.Protocols.Nx.Enabled {
+			s.registerNxRoutes(nsGroup.Group("/v1/cache"), nxHandler)
+		}
+		if s.cfg.Protocols.WebDAV.Enabled {
+			s.registerWebDAVRoutes(nsGroup.Group("/dav"), webDAVHandler)
+		}
+	}
+
+	// Admin endpoints
+	capacity, _ := s.storage.(*storage.CapacityManager)
+	adminHandler := handler.NewAdminHandler(capacity)
+	adminGroup := s.router.Group("/admin", s.audited("admin"), s.cacheAuth(middleware.RoleAdmin))
+	adminGroup.GET("/quotas", adminHandler.Quotas)
+}
+
+// registerCacheRoutes adds the Gradle cache endpoints to a route group.
+func (s *Server) registerCacheRoutes(group *gin.RouterGroup, cacheHandler *handler.CacheHandler) {
+	group.GET("/:key", s.cacheAuth(middleware.RoleRead), s.rateLimit(), cacheHandler.Get)
+	group.HEAD("/:key", s.cacheAuth(middleware.RoleRead), s.rateLimit(), cacheHandler.Head)
+	group.PUT("/:key", s.audited("cache.put"), s.cacheAuth(middleware.RoleWrite), s.rateLimit(), cacheHandler.Put)
+}
+
+// registerBazelRoutes adds the Bazel HTTP remote cache endpoints to a route
+// group, so that Bazel's --remote_cache can point at the group's prefix.
+func (s *Server) registerBazelRoutes(group *gin.RouterGroup, bazelHandler *handler.BazelHandler) {
+	for _, store := range []string{handler.BazelAC, handler.BazelCAS} {
+		path := "/" + store + "/:hash"
+		group.GET(path, s.cacheAuth(middleware.RoleRead), s.rateLimit(), bazelHandler.Get(store))
+		group.HEAD(path, s.cacheAuth(middleware.RoleRead), s.rateLimit(), bazelHandler.Head(store))
+		group.PUT(path, s.audited("bazel."+store+".put"), s.cacheAuth(middleware.RoleWrite), s.rateLimit(), bazelHandler.Put(store))
+	}
+}
+
+// registerTurboRoutes adds the Turborepo remote cache endpoints to a route
+// group, so that Turborepo's --api can point at the server root.
+func (s *Server) registerTurboRoutes(group *gin.RouterGroup, turboHandler *handler.TurboHandler) {
+	group.GET("/status", s.cacheAuth(middleware.RoleRead), s.rateLimit(), turboHandler.Status)
+	group.POST("/events", s.cacheAuth(middleware.RoleRead), s.rateLimit(), turboHandler.Events)
+	group.GET("/:hash", s.cacheAuth(middleware.RoleRead), s.rateLimit(), turboHandler.Get)
+	group.HEAD("/:hash", s.cacheAuth(middleware.RoleRead), s.rateLimit(), turboHandler.Head)
+	group.PUT("/:hash", s.audited("turbo.put"), s.cacheAuth(middleware.RoleWrite), s.rateLimit(), turboHandler.Put)
+}
 
-	cacheGroup.GET("/:key", s.cacheAuth(false), cacheHandler.Get)
-	cacheGroup.HEAD("/:key", s.cacheAuth(false), cacheHandler.Head)
-	cacheGroup.PUT("/:key", s.cacheAuth(true), cacheHandler.Put)
+// registerNxRoutes adds the Nx self-hosted remote cache endpoints to a route
+// group. Nx appends /v1/cache to its server URL, so the URL may include a
+// namespace prefix.
+func (s *Server) registerNxRoutes(group *gin.RouterGroup, nxHandler *handler.NxHandler) {
+	group.GET("/:hash", s.cacheAuth(middleware.RoleRead), s.rateLimit(), nxHandler.Get)
+	group.PUT("/:hash", s.audited("nx.put"), s.cacheAuth(middleware.RoleWrite), s.rateLimit(), nxHandler.Put)
 }
 
-func (s *Server) cacheAuth(requireWriter bool) gin.HandlerFunc {
+// registerWebDAVRoutes adds the WebDAV endpoints to a route group, so that
+// sccache and ccache can use the group's prefix as their WebDAV endpoint.
+func (s *Server) registerWebDAVRoutes(group *gin.RouterGroup, webDAVHandler *handler.WebDAVHandler) {
+	group.OPTIONS("/*path", webDAVHandler.Options)
+	group.GET("/*path", s.cacheAuth(middleware.RoleRead), s.rateLimit(), webDAVHandler.Get)
+	group.HEAD("/*path", s.cacheAuth(middleware.RoleRead), s.rateLimit(), webDAVHandler.Head)
+	group.Handle(handler.MethodPropfind, "/*path", s.cacheAuth(middleware.RoleRead), s.rateLimit(), webDAVHandler.Propfind)
+	group.PUT("/*path", s.audited("webdav.put"), s.cacheAuth(middleware.RoleWrite), s.rateLimit(), webDAVHandler.Put)
+	group.Handle(handler.MethodMkcol, "/*path", s.audited("webdav.mkcol"), s.cacheAuth(middleware.RoleWrite), s.rateLimit(), webDAVHandler.Mkcol)
+}
+
+// audited records requests to the audit log as action, if auditing is enabled.
+func (s *Server) audited(action string) gin.HandlerFunc {
+	if s.audit == nil {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	return s.audit.Middleware(action)
+}
+
+func (s *Server) rateLimit() gin.HandlerFunc {
+	if s.limiter == nil {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	return s.limiter.Middleware()
+}
+
+func (s *Server) cacheAuth(role middleware.Role) gin.HandlerFunc {
 	if !s.cfg.Auth.Enabled {
 		return func(c *gin.Context) {
 			c.Next()
 		}
 	}
-	return middleware.CacheAuth(s.cfg.Auth, requireWriter)
+	return middleware.CacheAuth(s.authn, role)
 }
 
 // handlePing is a simple health check endpoint.
@@ -128,6 +323,15 @@ func (s *Server) handleHealth(c *gin.Context) {
 	})
 }
 
+// Close stops the background work of the server's components. Call it
+// after Run has returned.
+func (s *Server) Close() error {
+	if s.authn != nil {
+		return s.authn.Close()
+	}
+	return nil
+}
+
 // Run starts the HTTP server.
 func (s *Server) Run(ctx context.Context) error {
 	addr := fmt.Sprintf(":%d", s.cfg.Server.Port)
@@ -139,8 +343,16 @@ func (s *Server) Run(ctx context.Context) error {
 		WriteTimeout: s.cfg.Server.WriteTimeout,
 	}
 
+	if s.cfg.Server.TLS.Enabled {
+		tlsConfig, err := newTLSConfig(s.cfg.Server.TLS)
+		if err != nil {
+			return err
+		}
+		srv.TLSConfig = tlsConfig
+	}
+
 	// Channel to capture server errors
-	errCh := make(chan error, 1)
+	errCh := make(chan error, 2)
 
 	go func() {
 		if s.cfg.Server.TLS.Enabled {
@@ -162,6 +374,23 @@ func (s *Server) Run(ctx context.Context) error {
 		}
 	}()
 
+	if s.reapi != nil {
+		grpcAddr := fmt.Sprintf(":%d", s.cfg.Protocols.Bazel.GRPC.Port)
+		lis, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen for gRPC: %w", err)
+		}
+		go func() {
+			s.logger.Info().
+				Str("addr", grpcAddr).
+				Bool("tls", s.cfg.Server.TLS.Enabled).
+				Msg("starting Bazel gRPC cache")
+			if err := s.reapi.Serve(lis); err != nil {
+				errCh <- err
+			}
+		}()
+	}
+
 	// Wait for context cancellation or server error
 	select {
 	case <-ctx.Done():
@@ -170,6 +399,9 @@ func (s *Server) Run(ctx context.Context) error {
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
+		if s.reapi != nil {
+			s.reapi.Stop(shutdownCtx)
+		}
 		if err := srv.Shutdown(shutdownCtx); err != nil {
 			return fmt.Errorf("server shutdown failed %w", err)
 
@@ -184,3 +416,29 @@ func (s *Server) Run(ctx context.Context) error {
 func (s *Server) Router() *gin.Engine {
 	return s.router
 }
+
+// newTLSConfig builds the server TLS settings, including client certificate
+// verification. The server certificate itself is loaded by ListenAndServeTLS.
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if cfg.ClientAuth == config.ClientAuthNone {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("client CA file contains no certificates")
+	}
+	tlsConfig.ClientCAs = pool
+
+	if cfg.ClientAuth == config.ClientAuthRequired {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	} else {
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,713 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// CapacityManager bounds the total size of the cache independently of the
+// backend. It tracks the size and last access of every entry it sees and
+// deletes the least recently used entries once MaxBytes is exceeded. It also
+// enforces per-namespace quotas, rejecting uploads with ErrQuotaExceeded or
+// evicting the namespace's oldest entries to make room.
+//
+// Tracking state lives in memory. With a Scanner, the manager lists the
+// backend on startup and periodically after that, adopting entries written
+// before a restart or by other replicas and forgetting entries removed
+// behind its back. Adopted entries count with the size of their content and
+// as least recently used until they are next read. Reading the size through
+// the wrapped storage does not refresh their lifetime.
+type CapacityManager struct {
+	backend   Storage
+	namespace string
+	tracker   *capacityTracker
+	// scanner lists the backend in the background. Nil without a Scanner.
+	scanner *backgroundLoop
+}
+
+type CapacityConfig struct {
+	// MaxBytes is the total entry size above which entries are evicted.
+	// Zero leaves the total unbounded.
+	MaxBytes int64
+	// DefaultQuota applies to every named namespace without an override.
+	DefaultQuota Quota
+	// Quotas overrides the default quota for individual namespaces.
+	Quotas map[string]Quota
+	// TTL lets the manager forget entries the backend has expired. Zero
+	// keeps tracking entries until they are evicted or deleted.
+	TTL time.Duration
+	// Scanner lists the entries of the backend. Nil only counts entries
+	// the manager sees being written or read.
+	Scanner Scanner
+	// ScanInterval is how often the backend is listed again after the
+	// initial scan.
+	ScanInterval time.Duration
+}
+
+// Quota limits the entries stored in a single namespace. Zero values mean
+// unlimited.
+type Quota struct {
+	MaxBytes   int64
+	MaxEntries int
+	// EvictOldest makes room for an upload by evicting the namespace's least
+	// recently used entries instead of rejecting it.
+	EvictOldest bool
+}
+
+func (q Quota) limited() bool {
+	return q.MaxBytes > 0 || q.MaxEntries > 0
+}
+
+// NamespaceUsage reports the tracked usage and quota of a namespace.
+type NamespaceUsage struct {
+	Namespace  string `json:"namespace"`
+	Bytes      int64  `json:"used_bytes"`
+	Entries    int    `json:"used_entries"`
+	MaxBytes   int64  `json:"max_bytes,omitempty"`
+	MaxEntries int    `json:"max_entries,omitempty"`
+}
+
+func NewCapacityManager(backend Storage, cfg CapacityConfig, logger zerolog.Logger) (*CapacityManager, error) {
+	if cfg.MaxBytes < 0 {
+		return nil, fmt.Errorf("capacity manager size limit must not be negative")
+	}
+	tracker := &capacityTracker{
+		cfg:        cfg,
+		logger:     logger,
+		order:      list.New(),
+		items:      make(map[entryID]*list.Element),
+		namespaces: make(map[string]*namespaceUsage),
+	}
+	if err := tracker.registerMetrics(); err != nil {
+		return nil, err
+	}
+	manager := &CapacityManager{backend: backend, tracker: tracker}
+	if cfg.Scanner != nil {
+		if cfg.ScanInterval <= 0 {
+			return nil, fmt.Errorf("capacity manager scan interval must be positive")
+		}
+		manager.scanner = startLoop(func(ctx context.Context) {
+			manager.scanLoop(ctx, cfg.ScanInterval)
+		})
+	}
+	return manager, nil
+}
+
+func (s *CapacityManager) id(key string) entryID {
+	return entryID{namespace: s.namespace, key: key}
+}
+
+func (s *CapacityManager) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	reader, size, err := s.backend.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			s.tracker.forget(s.id(key))
+		}
+		return nil, 0, err
+	}
+	s.tracker.access(s.id(key), size, s.backend)
+	return reader, size, nil
+}
+
+func (s *CapacityManager) Put(ctx context.Context, key string, reader io.Reader, size int64) error {
+	if size < 0 && s.tracker.quota(s.namespace).limited() {
+		// Quotas are checked before the upload starts, which needs its size.
+		entry, err := spool(reader, size)
+		if err != nil {
+			return err
+		}
+		defer entry.Close()
+		if reader, err = entry.Reader(); err != nil {
+			return err
+		}
+		size = entry.size
+	}
+
+	id := s.id(key)
+	victims, err := s.tracker.reserve(ctx, id, size)
+	if err != nil {
+		return err
+	}
+
+	counter := &countingReader{r: reader}
+	err = s.backend.Put(ctx, key, counter, size)
+	s.tracker.release(id, size)
+	if err != nil {
+		// The room made for the upload is not needed after all.
+		s.tracker.restore(victims)
+		return err
+	}
+	s.tracker.evict(ctx, victims, "quota")
+	s.tracker.store(ctx, id, counter.n, s.backend)
+	return nil
+}
+
+func (s *CapacityManager) Exists(ctx context.Context, key string) (bool, error) {
+	exists, err := s.backend.Exists(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		s.tracker.access(s.id(key), -1, s.backend)
+	} else {
+		s.tracker.forget(s.id(key))
+	}
+	return exists, nil
+}
+
+func (s *CapacityManager) Stat(ctx context.Context, key string) (EntryInfo, error) {
+	info, err := Stat(ctx, s.backend, key)
+	if errors.Is(err, ErrNotFound) {
+		s.tracker.forget(s.id(key))
+	} else if err == nil {
+		s.tracker.access(s.id(key), -1, s.backend)
+	}
+	return info, err
+}
+
+func (s *CapacityManager) Delete(ctx context.Context, key string) error {
+	s.tracker.forget(s.id(key))
+	return s.backend.Delete(ctx, key)
+}
+
+func (s *CapacityManager) Ping(ctx context.Context) error {
+	return s.backend.Ping(ctx)
+}
+
+// Close stops listing the backend in the background. Entries are still
+// tracked as they are written and read.
+func (s *CapacityManager) Close() error {
+	s.scanner.stop()
+	return nil
+}
+
+// scanLoop scans the backend now and then every interval until ctx is
+// cancelled.
+func (s *CapacityManager) scanLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.scan(ctx); err != nil && ctx.Err() == nil {
+			s.tracker.logger.Error().Err(err).Msg("failed to scan storage backend")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan reconciles the tracked entries with the backend. Entries the backend
+// holds are adopted if unknown, and tracked entries it no longer holds are
+// forgotten unless they were accessed while the scan ran. The cache is
+// brought back within its size limit afterwards.
+func (s *CapacityManager) scan(ctx context.Context) error {
+	t := s.tracker
+	start := time.Now()
+	t.mu.Lock()
+	t.generation++
+	generation := t.generation
+	t.mu.Unlock()
+
+	adopted := 0
+	err := t.cfg.Scanner.Scan(ctx, func(found ScannedEntry) error {
+		id := entryID{namespace: found.Namespace, key: found.Key}
+		if t.markSeen(id, generation) {
+			return nil
+		}
+		store := withNamespace(s.backend, found.Namespace)
+		size, ok := s.contentSize(ctx, store, found)
+		if !ok {
+			return nil
+		}
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if elem, ok := t.items[id]; ok {
+			// Written or read while its size was resolved.
+			elem.Value.(*trackedEntry).seen = generation
+			return nil
+		}
+		t.insertBack(&trackedEntry{
+			id:         id,
+			size:       size,
+			lastAccess: start,
+			seen:       generation,
+			store:      store,
+		})
+		adopted++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	forgotten := 0
+	for elem := t.order.Back(); elem != nil; {
+		prev := elem.Prev()
+		if entry := elem.Value.(*trackedEntry); entry.seen != generation && entry.lastAccess.Before(start) {
+			t.remove(elem)
+			forgotten++
+		}
+		elem = prev
+	}
+	t.lastScan = time.Now()
+	victims := t.collectVictims()
+	t.mu.Unlock()
+
+	t.logger.Info().
+		Int("adopted", adopted).
+		Int("forgotten", forgotten).
+		Dur("duration", time.Since(start)).
+		Msg("scanned storage backend")
+	t.evict(ctx, victims, "capacity")
+	return nil
+}
+
+// contentSize resolves the size of a scanned entry through the wrapped
+// storage, since the backend only knows the stored size. That differs from
+// the content size under compression and encryption, and under deduplication
+// the backend holds only a small reference. Entries that are gone by now are
+// reported as not found.
+func (s *CapacityManager) contentSize(ctx context.Context, store Storage, found ScannedEntry) (int64, bool) {
+	info, err := Stat(withoutRefresh(ctx), store, found.Key)
+	if errors.Is(err, ErrNotFound) {
+		return 0, false
+	}
+	if err != nil || info.Size < 0 {
+		s.tracker.logger.Warn().Err(err).
+			Str("namespace", found.Namespace).
+			Str("key", found.Key).
+			Msg("failed to resolve the size of a scanned entry, counting its stored size")
+		return found.Size, true
+	}
+	return info.Size, true
+}
+
+// Usage returns the tracked usage of every namespace with entries or a quota.
+func (s *CapacityManager) Usage() []NamespaceUsage {
+	return s.tracker.usage()
+}
+
+// LastScan returns when the tracked usage was last reconciled with the
+// backend, or the zero time if it never was.
+func (s *CapacityManager) LastScan() time.Time {
+	s.tracker.mu.Lock()
+	defer s.tracker.mu.Unlock()
+	return s.tracker.lastScan
+}
+
+func (s *CapacityManager) WithNamespace(namespace string) Storage {
+	return &CapacityManager{
+		backend:   withNamespace(s.backend, namespace),
+		namespace: namespace,
+		tracker:   s.tracker,
+		scanner:   s.scanner,
+	}
+}
+
+type entryID struct {
+	namespace string
+	key       string
+}
+
+type trackedEntry struct {
+	id         entryID
+	size       int64
+	lastAccess time.Time
+	// seen is the generation of the last scan that found the entry.
+	seen uint64
+	// store is the namespaced backend the entry lives in, used to evict it.
+	store Storage
+}
+
+// namespaceUsage holds the tracked totals of one namespace. Pending counts
+// uploads that passed the quota check but have not completed yet.
+type namespaceUsage struct {
+	bytes          int64
+	entries        int
+	pendingBytes   int64
+	pendingEntries int
+}
+
+// capacityTracker is the LRU bookkeeping shared by all namespaces.
+type capacityTracker struct {
+	mu         sync.Mutex
+	cfg        CapacityConfig
+	logger     zerolog.Logger
+	bytes      int64
+	order      *list.List
+	items      map[entryID]*list.Element
+	namespaces map[string]*namespaceUsage
+	generation uint64
+	lastScan   time.Time
+
+	evictions       metric.Int64Counter
+	evictedBytes    metric.Int64Counter
+	quotaRejections metric.Int64Counter
+}
+
+func (t *capacityTracker) quota(namespace string) Quota {
+	if namespace == "" {
+		return Quota{}
+	}
+	if q, ok := t.cfg.Quotas[namespace]; ok {
+		return q
+	}
+	return t.cfg.DefaultQuota
+}
+
+func (t *capacityTracker) namespaceUsage(namespace string) *namespaceUsage {
+	u, ok := t.namespaces[namespace]
+	if !ok {
+		u = &namespaceUsage{}
+		t.namespaces[namespace] = u
+	}
+	return u
+}
+
+// reserve checks an upload against the quota of its namespace and reserves
+// room for it. Under an evicting quota it returns the entries that must be
+// evicted first; otherwise it fails with ErrQuotaExceeded.
+func (t *capacityTracker) reserve(ctx context.Context, id entryID, size int64) ([]*trackedEntry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	q := t.quota(id.namespace)
+	if !q.limited() {
+		return nil, nil
+	}
+	u := t.namespaceUsage(id.namespace)
+
+	// An upload replacing an entry frees the old entry's space.
+	var replaced *trackedEntry
+	if elem, ok := t.items[id]; ok {
+		replaced = elem.Value.(*trackedEntry)
+	}
+	fits := func() bool {
+		bytes, entries := u.bytes+u.pendingBytes+max(size, 0), u.entries+u.pendingEntries+1
+		if replaced != nil {
+			bytes -= replaced.size
+			entries--
+		}
+		return (q.MaxBytes <= 0 || bytes <= q.MaxBytes) &&
+			(q.MaxEntries <= 0 || entries <= q.MaxEntries)
+	}
+
+	var victims []*trackedEntry
+	if !fits() && q.EvictOldest {
+		for elem := t.order.Back(); elem != nil && !fits(); {
+			prev := elem.Prev()
+			entry := elem.Value.(*trackedEntry)
+			if entry.id.namespace == id.namespace && entry != replaced {
+				victims = append(victims, t.remove(elem))
+			}
+			elem = prev
+		}
+	}
+	if !fits() {
+		// Put the entries back; evicting them would not make room anyway.
+		for _, entry := range victims {
+			t.insertBack(entry)
+		}
+		t.quotaRejections.Add(ctx, 1, metric.WithAttributes(attribute.String("namespace", id.namespace)))
+		return nil, ErrQuotaExceeded
+	}
+
+	u.pendingBytes += max(size, 0)
+	u.pendingEntries++
+	return victims, nil
+}
+
+// restore tracks victims of a reservation again after the upload they made
+// room for failed, unless they were written again in the meantime.
+func (t *capacityTracker) restore(victims []*trackedEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, entry := range victims {
+		if _, ok := t.items[entry.id]; !ok {
+			t.insertBack(entry)
+		}
+	}
+}
+
+// release drops the reservation made for an upload.
+func (t *capacityTracker) release(id entryID, size int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.quota(id.namespace).limited() {
+		return
+	}
+	u := t.namespaceUsage(id.namespace)
+	u.pendingBytes -= max(size, 0)
+	u.pendingEntries--
+}
+
+func (t *capacityTracker) usage() []NamespaceUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage := make([]NamespaceUsage, 0, len(t.namespaces))
+	for ns, u := range t.namespaces {
+		q := t.quota(ns)
+		usage = append(usage, NamespaceUsage{
+			Namespace:  ns,
+			Bytes:      u.bytes,
+			Entries:    u.entries,
+			MaxBytes:   q.MaxBytes,
+			MaxEntries: q.MaxEntries,
+		})
+	}
+	for ns, q := range t.cfg.Quotas {
+		if _, ok := t.namespaces[ns]; !ok {
+			usage = append(usage, NamespaceUsage{
+				Namespace:  ns,
+				MaxBytes:   q.MaxBytes,
+				MaxEntries: q.MaxEntries,
+			})
+		}
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Namespace < usage[j].Namespace
+	})
+	return usage
+}
+
+// access records a hit. Entries unknown to the tracker are adopted when their
+// size is known, which is the case for Get but not for Exists. A known size
+// also replaces the stored size an entry was adopted with by a scan.
+func (t *capacityTracker) access(id entryID, size int64, store Storage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if elem, ok := t.items[id]; ok {
+		entry := elem.Value.(*trackedEntry)
+		if size >= 0 && size != entry.size {
+			t.account(entry, -1)
+			entry.size = size
+			t.account(entry, 1)
+		}
+		entry.lastAccess = time.Now()
+		t.order.MoveToFront(elem)
+		return
+	}
+	if size >= 0 {
+		t.insert(&trackedEntry{id: id, size: size, lastAccess: time.Now(), store: store})
+	}
+}
+
+// store records a completed write and evicts entries if the cache is now
+// over capacity.
+func (t *capacityTracker) store(ctx context.Context, id entryID, size int64, store Storage) {
+	t.mu.Lock()
+	if elem, ok := t.items[id]; ok {
+		t.remove(elem)
+	}
+	t.insert(&trackedEntry{id: id, size: size, lastAccess: time.Now(), store: store})
+	victims := t.collectVictims()
+	t.mu.Unlock()
+
+	t.evict(ctx, victims, "capacity")
+}
+
+// markSeen records that a scan found a tracked entry, and reports whether the
+// entry is tracked.
+func (t *capacityTracker) markSeen(id entryID, generation uint64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	elem, ok := t.items[id]
+	if ok {
+		elem.Value.(*trackedEntry).seen = generation
+	}
+	return ok
+}
+
+func (t *capacityTracker) forget(id entryID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if elem, ok := t.items[id]; ok {
+		t.remove(elem)
+	}
+}
+
+func (t *capacityTracker) insert(entry *trackedEntry) {
+	t.items[entry.id] = t.order.PushFront(entry)
+	t.account(entry, 1)
+}
+
+// insertBack restores an entry at the least recently used end.
+func (t *capacityTracker) insertBack(entry *trackedEntry) {
+	t.items[entry.id] = t.order.PushBack(entry)
+	t.account(entry, 1)
+}
+
+func (t *capacityTracker) remove(elem *list.Element) *trackedEntry {
+	entry := t.order.Remove(elem).(*trackedEntry)
+	delete(t.items, entry.id)
+	t.account(entry, -1)
+	return entry
+}
+
+func (t *capacityTracker) account(entry *trackedEntry, sign int) {
+	u := t.namespaceUsage(entry.id.namespace)
+	t.bytes += int64(sign) * entry.size
+	u.bytes += int64(sign) * entry.size
+	u.entries += sign
+	if u.entries == 0 && u.pendingEntries == 0 && !t.quota(entry.id.namespace).limited() {
+		delete(t.namespaces, entry.id.namespace)
+	}
+}
+
+// collectVictims untracks least recently used entries until the total fits.
+// Entries older than the TTL have already expired in the backend and are
+// dropped without being reported as evictions. Must be called with mu held.
+func (t *capacityTracker) collectVictims() []*trackedEntry {
+	var victims []*trackedEntry
+	for t.cfg.MaxBytes > 0 && t.bytes > t.cfg.MaxBytes && t.order.Len() > 1 {
+		entry := t.remove(t.order.Back())
+		if t.cfg.TTL > 0 && time.Since(entry.lastAccess) > t.cfg.TTL {
+			continue
+		}
+		victims = append(victims, entry)
+	}
+	return victims
+}
+
+// evict deletes victims from their backends. It runs without holding the
+// lock so slow deletes do not block other requests.
+func (t *capacityTracker) evict(ctx context.Context, victims []*trackedEntry, reason string) {
+	for _, entry := range victims {
+		attrs := metric.WithAttributes(
+			attribute.String("namespace", entry.id.namespace),
+			attribute.String("reason", reason))
+		if err := entry.store.Delete(context.WithoutCancel(ctx), entry.id.key); err != nil {
+			t.logger.Error().Err(err).
+				Str("namespace", entry.id.namespace).
+				Str("key", entry.id.key).
+				Msg("failed to evict cache entry")
+			continue
+		}
+		t.evictions.Add(ctx, 1, attrs)
+		t.evictedBytes.Add(ctx, entry.size, attrs)
+		t.logger.Info().
+			Str("namespace", entry.id.namespace).
+			Str("key", entry.id.key).
+			Int64("size", entry.size).
+			Time("last_access", entry.lastAccess).
+			Str("reason", reason).
+			Msg("evicted cache entry")
+	}
+}
+
+func (t *capacityTracker) registerMetrics() error {
+	meter := otel.Meter("gradle-cache")
+
+	var err error
+	t.evictions, err = meter.Int64Counter(
+		"gradle_cache.evictions",
+		metric.WithDescription("Total number of cache entries evicted by the capacity manager"))
+	if err != nil {
+		return err
+	}
+
+	t.evictedBytes, err = meter.Int64Counter(
+		"gradle_cache.evicted_bytes",
+		metric.WithDescription("Total size of cache entries evicted by the capacity manager"),
+		metric.WithUnit("By"))
+	if err != nil {
+		return err
+	}
+
+	t.quotaRejections, err = meter.Int64Counter(
+		"gradle_cache.quota_rejections",
+		metric.WithDescription("Total number of uploads rejected because a namespace quota was exceeded"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.Int64ObservableGauge(
+		"gradle_cache.namespace_used_bytes",
+		metric.WithDescription("Total size of cache entries tracked per namespace"),
+		metric.WithUnit("By"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			for _, u := range t.usage() {
+				if u.Namespace != "" {
+					o.Observe(u.Bytes, metric.WithAttributes(attribute.String("namespace", u.Namespace)))
+				}
+			}
+			return nil
+		}))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.Int64ObservableGauge(
+		"gradle_cache.namespace_used_entries",
+		metric.WithDescription("Number of cache entries tracked per namespace"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			for _, u := range t.usage() {
+				if u.Namespace != "" {
+					o.Observe(int64(u.Entries), metric.WithAttributes(attribute.String("namespace", u.Namespace)))
+				}
+			}
+			return nil
+		}))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.Int64ObservableGauge(
+		"gradle_cache.stored_bytes",
+		metric.WithDescription("Total size of cache entries tracked by the capacity manager"),
+		metric.WithUnit("By"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			o.Observe(t.bytes)
+			return nil
+		}))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.Int64ObservableGauge(
+		"gradle_cache.stored_entries",
+		metric.WithDescription("Number of cache entries tracked by the capacity manager"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			o.Observe(int64(t.order.Len()))
+			return nil
+		}))
+	return err
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func newTestCapacity(t *testing.T, backend Storage, cfg CapacityConfig) *CapacityManager {
+	t.Helper()
+	m, err := NewCapacityManager(backend, cfg, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func exists(t *testing.T, s Storage, key string) bool {
+	t.Helper()
+	ok, err := s.Exists(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Exists(%q): %v", key, err)
+	}
+	return ok
+}
+
+// failingStorage fails every Put after reading the body.
+type failingStorage struct {
+	Storage
+}
+
+func (s failingStorage) Put(ctx context.Context, key string, reader io.Reader, size int64) error {
+	io.Copy(io.Discard, reader)
+	return errors.New("backend unavailable")
+}
+
+func (s failingStorage) WithNamespace(namespace string) Storage {
+	return failingStorage{withNamespace(s.Storage, namespace)}
+}
+
+func TestCapacityEvictsLeastRecentlyUsed(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{})
+	m := newTestCapacity(t, backend, CapacityConfig{MaxBytes: 10})
+
+	put(t, m, "a", []byte("aaaa"))
+	put(t, m, "b", []byte("bbbb"))
+	get(t, m, "a")
+	put(t, m, "c", []byte("cccc"))
+
+	if !exists(t, backend, "a") || !exists(t, backend, "c") {
+		t.Fatal("recently used entries were evicted")
+	}
+	if exists(t, backend, "b") {
+		t.Fatal("least recently used entry was not evicted")
+	}
+}
+
+func TestCapacityQuotaRejects(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{})
+	m := newTestCapacity(t, backend, CapacityConfig{DefaultQuota: Quota{MaxEntries: 1}})
+	ns := m.WithNamespace("team")
+
+	put(t, ns, "a", []byte("a"))
+	err := ns.Put(context.Background(), "b", strings.NewReader("b"), 1)
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Put over quota = %v, want ErrQuotaExceeded", err)
+	}
+	// Replacing an entry does not need extra room.
+	put(t, ns, "a", []byte("new"))
+	// The default namespace has no quota.
+	put(t, m, "a", []byte("a"))
+	put(t, m, "b", []byte("b"))
+}
+
+func TestCapacityQuotaEvictsOldest(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{})
+	m := newTestCapacity(t, backend, CapacityConfig{DefaultQuota: Quota{MaxBytes: 8, EvictOldest: true}})
+	ns := m.WithNamespace("team")
+
+	put(t, ns, "a", []byte("aaaa"))
+	put(t, ns, "b", []byte("bbbb"))
+	put(t, ns, "c", []byte("cccc"))
+
+	raw := backend.WithNamespace("team")
+	if exists(t, raw, "a") || !exists(t, raw, "b") || !exists(t, raw, "c") {
+		t.Fatal("quota did not evict exactly the oldest entry")
+	}
+	usage := m.Usage()
+	if len(usage) != 1 || usage[0].Bytes != 8 || usage[0].Entries != 2 {
+		t.Fatalf("usage = %+v", usage)
+	}
+}
+
+func TestCapacityKeepsVictimsOfFailedUploads(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{})
+	m := newTestCapacity(t, backend, CapacityConfig{DefaultQuota: Quota{MaxEntries: 1, EvictOldest: true}})
+	put(t, m.WithNamespace("team"), "a", []byte("a"))
+
+	failing := &CapacityManager{backend: failingStorage{backend}, tracker: m.tracker}
+	if err := failing.WithNamespace("team").Put(context.Background(), "b", strings.NewReader("b"), 1); err == nil {
+		t.Fatal("Put to failing backend succeeded")
+	}
+	if !exists(t, backend.WithNamespace("team"), "a") {
+		t.Fatal("entry was evicted for an upload that failed")
+	}
+	if usage := m.Usage(); usage[0].Entries != 1 {
+		t.Fatalf("usage after failed upload = %+v", usage)
+	}
+}
+
+func TestCapacityQuotaWithUnknownSize(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{})
+	m := newTestCapacity(t, backend, CapacityConfig{DefaultQuota: Quota{MaxBytes: 4}})
+
+	err := m.WithNamespace("team").Put(context.Background(), "a", strings.NewReader("too large"), -1)
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("streamed Put over quota = %v, want ErrQuotaExceeded", err)
+	}
+}
+
+func TestCapacityScanReconciles(t *testing.T) {
+	backend, _ := newTestRedis(t, 1024, ExpiryPolicy{})
+	m := newTestCapacity(t, backend, CapacityConfig{MaxBytes: 12})
+	m.tracker.cfg.Scanner = backend
+	ctx := context.Background()
+
+	// Entries written by another replica or before a restart.
+	put(t, backend, "old", []byte("1234"))
+	put(t, backend.WithNamespace("team"), "key", []byte("1234"))
+	if err := m.scan(ctx); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if usage := m.Usage(); len(usage) != 2 {
+		t.Fatalf("usage after scan = %+v, want both namespaces", usage)
+	}
+
+	// Adopted entries are the first to go once the cache is full.
+	put(t, m, "new", []byte("12345678"))
+	if exists(t, backend, "old") && exists(t, backend.WithNamespace("team"), "key") {
+		t.Fatal("no adopted entry was evicted")
+	}
+	if !exists(t, backend, "new") {
+		t.Fatal("new entry was evicted")
+	}
+
+	// Entries deleted behind the manager's back are forgotten.
+	if err := backend.Delete(ctx, "new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.scan(ctx); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if _, ok := m.tracker.items[entryID{key: "new"}]; ok {
+		t.Fatal("deleted entry is still tracked")
+	}
+	if m.tracker.bytes != 4 {
+		t.Fatalf("tracked %d bytes after scan, want 4", m.tracker.bytes)
+	}
+}
+
+// countingScanner counts the scans of the wrapped scanner.
+type countingScanner struct {
+	Scanner
+	scans atomic.Int64
+}
+
+func (s *countingScanner) Scan(ctx context.Context, fn func(ScannedEntry) error) error {
+	s.scans.Add(1)
+	return s.Scanner.Scan(ctx, fn)
+}
+
+func TestCapacityCloseStopsScans(t *testing.T) {
+	backend, _ := newTestRedis(t, 1024, ExpiryPolicy{})
+	scanner := &countingScanner{Scanner: backend}
+	m := newTestCapacity(t, backend, CapacityConfig{Scanner: scanner, ScanInterval: time.Millisecond})
+
+	for scanner.scans.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := m.WithNamespace("team").(*CapacityManager).Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	scans := scanner.scans.Load()
+	time.Sleep(20 * time.Millisecond)
+	if got := scanner.scans.Load(); got != scans {
+		t.Fatalf("scanned %d more times after Close", got-scans)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+}
+
+func TestCapacityScanCountsContentSize(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{TTL: time.Hour, Sliding: true})
+	store := newTestChecksum(t, newTestDedup(t, newTestCompression(t, backend, CompressionZstd)), true)
+	m := newTestCapacity(t, store, CapacityConfig{})
+	m.tracker.cfg.Scanner = backend
+	data := bytes.Repeat([]byte("class file contents "), 1000)
+
+	// Written by another replica, so the backend holds only a reference.
+	put(t, store.WithNamespace("team"), "key", data)
+	old := time.Now().Add(-30 * time.Minute)
+	path := backend.WithNamespace("team").(*FilesystemStorage).path("key")
+	os.Chtimes(path, old, old)
+
+	if err := m.scan(context.Background()); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if usage := m.Usage(); len(usage) != 1 || usage[0].Bytes != int64(len(data)) {
+		t.Fatalf("usage after scan = %+v, want %d bytes in team", usage, len(data))
+	}
+	if info, err := os.Stat(path); err != nil || !info.ModTime().Equal(old) {
+		t.Fatal("scan refreshed the entry")
+	}
+}
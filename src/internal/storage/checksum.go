@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ErrChecksumMismatch is returned while reading an entry whose content does
+// not match the checksum stored with it.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// checksumMagic starts every entry written by ChecksumStorage. It is
+// followed by the SHA-256 of the entry data and then the data itself.
+const checksumMagic = "\x00gradle-cache:sha256:v1\x00"
+
+const checksumHeaderSize = len(checksumMagic) + sha256.Size
+
+// checksumBufferLimit is the largest entry that is verified completely
+// before Get returns. Larger entries are verified while they are streamed.
+const checksumBufferLimit = 1 << 20
+
+// Digester is implemented by entry readers that know the SHA-256 of the
+// content they return.
+type Digester interface {
+	Digest() []byte
+}
+
+// ChecksumStorage stores a SHA-256 with every entry and verifies it on Get.
+// Entries up to checksumBufferLimit are verified before they are returned,
+// so corrupted ones are deleted and reported as ErrNotFound. Larger entries
+// are verified while streaming; a mismatch deletes the entry and fails the
+// final Read with ErrChecksumMismatch.
+//
+// With verification disabled, entries are stored without a checksum and
+// checksums of existing entries are stripped but not checked. Entries whose
+// data happens to start with checksumMagic are still stored with one, so
+// they cannot be mistaken for a checksum on read. Entries written without
+// a checksum are returned unverified.
+type ChecksumStorage struct {
+	backend  Storage
+	verify   bool
+	failures metric.Int64Counter
+}
+
+type ChecksumConfig struct {
+	// Verify stores a checksum with every new entry and checks it on Get.
+	Verify bool
+}
+
+func NewChecksumStorage(backend Storage, cfg ChecksumConfig) (*ChecksumStorage, error) {
+	failures, err := otel.Meter("gradle-cache").Int64Counter(
+		"gradle_cache.checksum_failures",
+		metric.WithDescription("Total number of entries deleted because their checksum did not match"))
+	if err != nil {
+		return nil, err
+	}
+	return &ChecksumStorage{backend: backend, verify: cfg.Verify, failures: failures}, nil
+}
+
+// readChecksumHeader reads the checksum in front of an entry. It returns
+// the digest of an entry stored with a checksum, and otherwise nil and the
+// bytes it consumed.
+func readChecksumHeader(reader io.Reader) (digest, consumed []byte, err error) {
+	header := make([]byte, checksumHeaderSize)
+	n, err := io.ReadFull(reader, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("failed to read entry header: %w", err)
+	}
+	if n < checksumHeaderSize || !bytes.HasPrefix(header, []byte(checksumMagic)) {
+		return nil, header[:n], nil
+	}
+	return header[len(checksumMagic):], nil, nil
+}
+
+func (s *ChecksumStorage) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	reader, size, err := s.backend.Get(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	digest, consumed, err := readChecksumHeader(reader)
+	if err != nil {
+		reader.Close()
+		return nil, 0, err
+	}
+	if digest == nil {
+		return &multiReadCloser{
+			Reader: io.MultiReader(bytes.NewReader(consumed), reader),
+			Closer: reader,
+		}, size, nil
+	}
+
+	size -= int64(checksumHeaderSize)
+	if !s.verify {
+		return &digestReadCloser{ReadCloser: reader, digest: digest}, size, nil
+	}
+
+	if size <= checksumBufferLimit {
+		defer reader.Close()
+		data, err := io.ReadAll(io.LimitReader(reader, size+1))
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read entry: %w", err)
+		}
+		sum := sha256.Sum256(data)
+		if int64(len(data)) != size || !bytes.Equal(sum[:], digest) {
+			s.corrupted(ctx, key)
+			return nil, 0, ErrNotFound
+		}
+		return &digestReadCloser{ReadCloser: io.NopCloser(bytes.NewReader(data)), digest: digest}, size, nil
+	}
+
+	return &verifyingReader{
+		ReadCloser: reader,
+		hash:       sha256.New(),
+		digest:     digest,
+		remaining:  size,
+		onMismatch: func() { s.corrupted(ctx, key) },
+	}, size, nil
+}
+
+// corrupted deletes an entry that failed verification.
+func (s *ChecksumStorage) corrupted(ctx context.Context, key string) {
+	s.failures.Add(ctx, 1)
+	// The entry is unusable either way; a failed delete leaves it to be
+	// caught again on the next read.
+	_ = s.backend.Delete(context.WithoutCancel(ctx), key)
+}
+
+// Stat returns the stored checksum of an entry without reading its data.
+func (s *ChecksumStorage) Stat(ctx context.Context, key string) (EntryInfo, error) {
+	info, header, err := statHeader(ctx, s.backend, key, checksumHeaderSize)
+	if err != nil {
+		return EntryInfo{}, err
+	}
+	digest, _, err := readChecksumHeader(bytes.NewReader(header))
+	if err != nil {
+		return EntryInfo{}, err
+	}
+	if digest == nil {
+		// Stored without a header, so the backend describes the content.
+		return info, nil
+	}
+	if info.Size >= 0 {
+		info.Size -= int64(checksumHeaderSize)
+	}
+	info.Digest = digest
+	return info, nil
+}
+
+// Put spools the entry to compute its checksum, since the checksum is
+// stored in front of the data. With verification disabled, entries are
+// streamed to the backend unless they start with checksumMagic.
+func (s *ChecksumStorage) Put(ctx context.Context, key string, reader io.Reader, size int64) error {
+	if !s.verify {
+		prefix := make([]byte, len(checksumMagic))
+		n, err := io.ReadFull(reader, prefix)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read entry: %w", err)
+		}
+		reader = io.MultiReader(bytes.NewReader(prefix[:n]), reader)
+		if !bytes.Equal(prefix[:n], []byte(checksumMagic)) {
+			return s.backend.Put(ctx, key, reader, size)
+		}
+	}
+
+	entry, err := spool(reader, size)
+	if err != nil {
+		return err
+	}
+	defer entry.Close()
+
+	data, err := entry.Reader()
+	if err != nil {
+		return err
+	}
+	header := append([]byte(checksumMagic), entry.digest...)
+	return s.backend.Put(ctx, key, io.MultiReader(bytes.NewReader(header), data), int64(len(header))+entry.size)
+}
+
+func (s *ChecksumStorage) Exists(ctx context.Context, key string) (bool, error) {
+	return s.backend.Exists(ctx, key)
+}
+
+func (s *ChecksumStorage) Delete(ctx context.Context, key string) error {
+	return s.backend.Delete(ctx, key)
+}
+
+func (s *ChecksumStorage) Ping(ctx context.Context) error {
+	return s.backend.Ping(ctx)
+}
+
+func (s *ChecksumStorage) WithNamespace(namespace string) Storage {
+	return &ChecksumStorage{
+		backend:  withNamespace(s.backend, namespace),
+		verify:   s.verify,
+		failures: s.failures,
+	}
+}
+
+// verifyingReader hashes an entry while it is read. The read that completes
+// the entry is verified before its bytes are returned, so a consumer never
+// receives the whole of a corrupted entry.
+type verifyingReader struct {
+	io.ReadCloser
+	hash       hash.Hash
+	digest     []byte
+	remaining  int64
+	onMismatch func()
+	failed     bool
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	if r.failed {
+		return 0, ErrChecksumMismatch
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	r.remaining -= int64(n)
+	switch {
+	case r.remaining < 0, r.remaining == 0 && !bytes.Equal(r.hash.Sum(nil), r.digest):
+		return 0, r.fail()
+	case r.remaining > 0 && errors.Is(err, io.EOF):
+		return n, r.fail()
+	}
+	return n, err
+}
+
+func (r *verifyingReader) fail() error {
+	r.failed = true
+	r.onMismatch()
+	return ErrChecksumMismatch
+}
+
+func (r *verifyingReader) Digest() []byte {
+	return r.digest
+}
+
+type digestReadCloser struct {
+	io.ReadCloser
+	digest []byte
+}
+
+func (r *digestReadCloser) Digest() []byte {
+	return r.digest
+}
+
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func newTestChecksum(t *testing.T, backend Storage, verify bool) *ChecksumStorage {
+	t.Helper()
+	s, err := NewChecksumStorage(backend, ChecksumConfig{Verify: verify})
+	if err != nil {
+		t.Fatalf("NewChecksumStorage: %v", err)
+	}
+	return s
+}
+
+// corrupt flips the last byte of the entry stored in backend under key.
+func corrupt(t *testing.T, backend Storage, key string) {
+	t.Helper()
+	raw, err := get(t, backend, key)
+	if err != nil {
+		t.Fatalf("Get(%q) from backend: %v", key, err)
+	}
+	raw[len(raw)-1] ^= 0xff
+	put(t, backend, key, raw)
+}
+
+func TestChecksumRoundTrip(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{})
+	s := newTestChecksum(t, backend, true)
+	ctx := context.Background()
+
+	data := []byte("checksummed entry")
+	sum := sha256.Sum256(data)
+	put(t, s, "key", data)
+
+	r, size, err := s.Get(ctx, "key")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, _ := io.ReadAll(r)
+	r.Close()
+	if !bytes.Equal(got, data) || size != int64(len(data)) {
+		t.Fatalf("Get = %q (size %d), want %q", got, size, data)
+	}
+	if d, ok := r.(Digester); !ok || !bytes.Equal(d.Digest(), sum[:]) {
+		t.Fatal("Get did not report the stored digest")
+	}
+
+	info, err := Stat(ctx, s, "key")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Size != int64(len(data)) || !bytes.Equal(info.Digest, sum[:]) {
+		t.Fatalf("Stat = %+v, want size %d and the stored digest", info, len(data))
+	}
+	if _, err := Stat(ctx, s, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Stat of missing key = %v, want ErrNotFound", err)
+	}
+
+	// Entries stored before checksums were enabled are served unverified.
+	put(t, backend, "legacy", []byte("plain"))
+	if got, err := get(t, s, "legacy"); err != nil || string(got) != "plain" {
+		t.Fatalf("Get(legacy) = %q, %v", got, err)
+	}
+}
+
+func TestChecksumDeletesCorruptedEntry(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{})
+	s := newTestChecksum(t, backend, true)
+
+	put(t, s, "key", []byte("soon to be corrupted"))
+	corrupt(t, backend, "key")
+
+	if _, err := get(t, s, "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of corrupted entry = %v, want ErrNotFound", err)
+	}
+	if exists(t, backend, "key") {
+		t.Fatal("corrupted entry was not deleted")
+	}
+}
+
+func TestChecksumFailsCorruptedStream(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{})
+	s := newTestChecksum(t, backend, true)
+
+	put(t, s, "key", bytes.Repeat([]byte("x"), checksumBufferLimit+1))
+	corrupt(t, backend, "key")
+
+	r, _, err := s.Get(context.Background(), "key")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer r.Close()
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("reading corrupted stream = %v, want ErrChecksumMismatch", err)
+	}
+	if exists(t, backend, "key") {
+		t.Fatal("corrupted entry was not deleted")
+	}
+}
+
+func TestChecksumDisabled(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{})
+	verified := newTestChecksum(t, backend, true)
+	s := newTestChecksum(t, backend, false)
+
+	// New entries are stored as they are.
+	put(t, s, "plain", []byte("plain entry"))
+	if raw, _ := get(t, backend, "plain"); string(raw) != "plain entry" {
+		t.Fatalf("backend holds %q, want the entry unframed", raw)
+	}
+
+	// Checksums written while verification was on are stripped, not checked.
+	put(t, verified, "framed", []byte("framed entry"))
+	corrupt(t, backend, "framed")
+	want := []byte("framed entry")
+	want[len(want)-1] ^= 0xff
+	if got, err := get(t, s, "framed"); err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("Get(framed) = %q, %v, want the unverified entry %q", got, err, want)
+	}
+
+	// Data that looks like a checksum is framed so it reads back intact.
+	tricky := append([]byte(checksumMagic), bytes.Repeat([]byte{1}, sha256.Size+4)...)
+	put(t, s, "tricky", tricky)
+	if got, err := get(t, s, "tricky"); err != nil || !bytes.Equal(got, tricky) {
+		t.Fatalf("Get(tricky) = %q, %v, want %q", got, err, tricky)
+	}
+	if raw, _ := get(t, backend, "tricky"); len(raw) != checksumHeaderSize+len(tricky) {
+		t.Fatalf("backend holds %d bytes, want the entry framed", len(raw))
+	}
+
+	// Short entries and entries of unknown size are stored as well.
+	put(t, s, "short", []byte("x"))
+	if got, err := get(t, s, "short"); err != nil || string(got) != "x" {
+		t.Fatalf("Get(short) = %q, %v", got, err)
+	}
+	if err := s.Put(context.Background(), "unknown", bytes.NewReader([]byte("unsized")), -1); err != nil {
+		t.Fatalf("Put of unknown size: %v", err)
+	}
+	if got, err := get(t, s, "unknown"); err != nil || string(got) != "unsized" {
+		t.Fatalf("Get(unknown) = %q, %v", got, err)
+	}
+}
+
+func TestStatWithoutStater(t *testing.T) {
+	// Embedding only the Storage interface hides the backend's Stat.
+	s := struct{ Storage }{newTestFilesystem(t, ExpiryPolicy{})}
+	put(t, s, "key", []byte("content"))
+
+	info, err := Stat(context.Background(), s, "key")
+	if err != nil || info.Size != 7 || info.Digest != nil {
+		t.Fatalf("Stat = %+v, %v, want size 7 without digest", info, err)
+	}
+	if _, err := Stat(context.Background(), s, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Stat of missing key = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStatThroughWrappers(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{})
+	encrypted := newTestEncryption(t, backend, "k1", testEncryptionKey("k1", 1))
+	s := newTestChecksum(t, newTestDedup(t, newTestCompression(t, encrypted, CompressionZstd)), true)
+	data := bytes.Repeat([]byte("class file contents "), 1000)
+	put(t, s, "key", data)
+
+	info, err := Stat(context.Background(), s, "key")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	if info.Size != int64(len(data)) || !bytes.Equal(info.Digest, sum[:]) {
+		t.Fatalf("Stat = size %d, digest %x, want size %d, digest %x", info.Size, info.Digest, len(data), sum)
+	}
+	if time.Since(info.ModTime) > time.Minute {
+		t.Fatalf("ModTime = %v, want the time of the Put", info.ModTime)
+	}
+
+	// Entries stored below a wrapper, without its header, are described by
+	// the layer beneath.
+	put(t, encrypted, "legacy", []byte("plain"))
+	if info, err := Stat(context.Background(), s, "legacy"); err != nil || info.Size != 5 || info.ModTime.IsZero() {
+		t.Fatalf("Stat(legacy) = %+v, %v, want size 5 with a ModTime", info, err)
+	}
+
+	if _, err := Stat(context.Background(), s, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Stat of missing key = %v, want ErrNotFound", err)
+	}
+	put(t, backend, "unencrypted", []byte("not encrypted"))
+	if _, err := Stat(context.Background(), encrypted, "unencrypted"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Stat of unencrypted entry = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,467 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Compression algorithms supported by CompressionStorage.
+const (
+	CompressionZstd = "zstd"
+	CompressionGzip = "gzip"
+)
+
+// Compression levels supported by CompressionStorage.
+const (
+	CompressionFastest = "fastest"
+	CompressionDefault = "default"
+	CompressionBetter  = "better"
+	CompressionBest    = "best"
+)
+
+// compressionMagic starts every entry written by CompressionStorage. It is
+// followed by one byte naming the algorithm, or compressionIDStored for
+// entries kept as they are, and the uncompressed size as a big-endian
+// uint64.
+const compressionMagic = "\x00gradle-cache:z:v1\x00"
+
+const compressionHeaderSize = len(compressionMagic) + 1 + 8
+
+const (
+	compressionIDStored byte = 0
+	compressionIDGzip   byte = 1
+	compressionIDZstd   byte = 2
+)
+
+const (
+	// compressionMinSize is the smallest entry worth compressing.
+	compressionMinSize = 512
+	// compressionSampleSize is how much of an entry is compressed up front
+	// to decide whether compressing the rest pays off.
+	compressionSampleSize = 64 << 10
+	// compressionMaxRatio is the largest compressed to original size ratio
+	// of the sample for which an entry is still compressed.
+	compressionMaxRatio = 0.9
+)
+
+// CompressionStorage compresses entries on Put and decompresses them on Get.
+// Whether an entry is compressed is decided from its first
+// compressionSampleSize bytes; entries that are small or do not compress
+// well are stored as they are, behind a header that says so. Every new entry
+// gets a header, so data that happens to start with compressionMagic is
+// never mistaken for one. Entries stored without the header, written before
+// compression was enabled, are returned unchanged, and entries compressed
+// with any supported algorithm can be read regardless of the configured one.
+type CompressionStorage struct {
+	backend Storage
+	codec   *codec
+	metrics *compressionMetrics
+}
+
+type CompressionConfig struct {
+	Algorithm string
+	Level     string
+}
+
+func NewCompressionStorage(backend Storage, cfg CompressionConfig) (*CompressionStorage, error) {
+	c, err := newCodec(cfg.Algorithm, cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := newCompressionMetrics()
+	if err != nil {
+		return nil, err
+	}
+	return &CompressionStorage{backend: backend, codec: c, metrics: metrics}, nil
+}
+
+func (s *CompressionStorage) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	reader, size, err := s.backend.Get(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	header := make([]byte, compressionHeaderSize)
+	n, err := io.ReadFull(reader, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		reader.Close()
+		return nil, 0, fmt.Errorf("failed to read entry header: %w", err)
+	}
+	if n < compressionHeaderSize || !bytes.HasPrefix(header, []byte(compressionMagic)) {
+		// Stored uncompressed.
+		return &multiReadCloser{
+			Reader: io.MultiReader(bytes.NewReader(header[:n]), reader),
+			Closer: reader,
+		}, size, nil
+	}
+
+	id := header[len(compressionMagic)]
+	size = int64(binary.BigEndian.Uint64(header[len(compressionMagic)+1:]))
+	if id == compressionIDStored {
+		return reader, size, nil
+	}
+	source := &timedReader{Reader: reader}
+	decompressed, err := s.codec.decompress(id, source)
+	if err != nil {
+		reader.Close()
+		return nil, 0, err
+	}
+	return &decompressingReader{
+		ReadCloser: decompressed,
+		source:     source,
+		closer:     reader,
+		remaining:  size,
+		record: func(d time.Duration) {
+			s.metrics.duration.Record(ctx, d.Seconds(), metric.WithAttributes(
+				attribute.String("operation", "decompress"),
+				attribute.String("algorithm", compressionName(id))))
+		},
+	}, size, nil
+}
+
+// Put compresses the entry into a spool first, since the compressed size
+// has to be known before it is written to the backend.
+func (s *CompressionStorage) Put(ctx context.Context, key string, reader io.Reader, size int64) error {
+	if size < 0 {
+		// The header records the original size, so entries of unknown
+		// length are spooled to learn it.
+		entry, err := spool(reader, size)
+		if err != nil {
+			return err
+		}
+		defer entry.Close()
+		if reader, err = entry.Reader(); err != nil {
+			return err
+		}
+		size = entry.size
+	}
+	if size < compressionMinSize {
+		s.metrics.skipped.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "small")))
+		return s.putStored(ctx, key, reader, size)
+	}
+
+	sample := make([]byte, min(size, compressionSampleSize))
+	n, err := io.ReadFull(reader, sample)
+	if err != nil {
+		return fmt.Errorf("failed to read entry: %w", err)
+	}
+	sample = sample[:n]
+	rest := io.MultiReader(bytes.NewReader(sample), reader)
+
+	var estimate countingWriter
+	if err := s.codec.compress(&estimate, bytes.NewReader(sample)); err != nil {
+		return err
+	}
+	if float64(estimate) > float64(len(sample))*compressionMaxRatio {
+		s.metrics.skipped.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "incompressible")))
+		return s.putStored(ctx, key, rest, size)
+	}
+
+	compressed := &spooledEntry{}
+	defer compressed.Close()
+	header := compressionHeader(s.codec.id, size)
+
+	start := time.Now()
+	if err := s.codec.compress(compressed, rest); err != nil {
+		return err
+	}
+	s.metrics.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+		attribute.String("operation", "compress"),
+		attribute.String("algorithm", s.codec.name)))
+
+	data, err := compressed.Reader()
+	if err != nil {
+		return err
+	}
+	if err := s.backend.Put(ctx, key, io.MultiReader(bytes.NewReader(header), data), int64(len(header))+compressed.size); err != nil {
+		return err
+	}
+	s.metrics.inputBytes.Add(ctx, size)
+	s.metrics.outputBytes.Add(ctx, compressed.size)
+	return nil
+}
+
+// putStored writes an entry uncompressed behind a header.
+func (s *CompressionStorage) putStored(ctx context.Context, key string, reader io.Reader, size int64) error {
+	header := compressionHeader(compressionIDStored, size)
+	return s.backend.Put(ctx, key, io.MultiReader(bytes.NewReader(header), reader), int64(len(header))+size)
+}
+
+func compressionHeader(id byte, size int64) []byte {
+	header := make([]byte, compressionHeaderSize)
+	copy(header, compressionMagic)
+	header[len(compressionMagic)] = id
+	binary.BigEndian.PutUint64(header[len(compressionMagic)+1:], uint64(size))
+	return header
+}
+
+func (s *CompressionStorage) Exists(ctx context.Context, key string) (bool, error) {
+	return s.backend.Exists(ctx, key)
+}
+
+// Stat reads the uncompressed size from the entry header.
+func (s *CompressionStorage) Stat(ctx context.Context, key string) (EntryInfo, error) {
+	info, header, err := statHeader(ctx, s.backend, key, compressionHeaderSize)
+	if err != nil {
+		return EntryInfo{}, err
+	}
+	if len(header) < compressionHeaderSize || !bytes.HasPrefix(header, []byte(compressionMagic)) {
+		// Stored uncompressed.
+		return info, nil
+	}
+	size := int64(binary.BigEndian.Uint64(header[len(compressionMagic)+1:]))
+	return EntryInfo{Size: size, ModTime: info.ModTime}, nil
+}
+
+func (s *CompressionStorage) Delete(ctx context.Context, key string) error {
+	return s.backend.Delete(ctx, key)
+}
+
+func (s *CompressionStorage) Ping(ctx context.Context) error {
+	return s.backend.Ping(ctx)
+}
+
+func (s *CompressionStorage) WithNamespace(namespace string) Storage {
+	return &CompressionStorage{
+		backend: withNamespace(s.backend, namespace),
+		codec:   s.codec,
+		metrics: s.metrics,
+	}
+}
+
+// codec compresses with the configured algorithm and decompresses any
+// supported one. Encoders and decoders are pooled, since zstd ones are
+// expensive to create.
+type codec struct {
+	id   byte
+	name string
+
+	zstdEncoders sync.Pool
+	zstdDecoders sync.Pool
+	gzipEncoders sync.Pool
+}
+
+func newCodec(algorithm, level string) (*codec, error) {
+	c := &codec{name: algorithm}
+	switch algorithm {
+	case CompressionZstd:
+		c.id = compressionIDZstd
+	case CompressionGzip:
+		c.id = compressionIDGzip
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm %q", algorithm)
+	}
+
+	var zstdLevel zstd.EncoderLevel
+	var gzipLevel int
+	switch level {
+	case CompressionFastest:
+		zstdLevel, gzipLevel = zstd.SpeedFastest, gzip.BestSpeed
+	case CompressionDefault, "":
+		zstdLevel, gzipLevel = zstd.SpeedDefault, gzip.DefaultCompression
+	case CompressionBetter:
+		zstdLevel, gzipLevel = zstd.SpeedBetterCompression, 7
+	case CompressionBest:
+		zstdLevel, gzipLevel = zstd.SpeedBestCompression, gzip.BestCompression
+	default:
+		return nil, fmt.Errorf("unsupported compression level %q", level)
+	}
+
+	c.zstdEncoders.New = func() any {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstdLevel), zstd.WithEncoderConcurrency(1))
+		return enc
+	}
+	c.zstdDecoders.New = func() any {
+		dec, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		return dec
+	}
+	c.gzipEncoders.New = func() any {
+		enc, _ := gzip.NewWriterLevel(nil, gzipLevel)
+		return enc
+	}
+	return c, nil
+}
+
+func (c *codec) compress(dst io.Writer, src io.Reader) error {
+	var enc io.WriteCloser
+	switch c.id {
+	case compressionIDZstd:
+		z := c.zstdEncoders.Get().(*zstd.Encoder)
+		defer c.zstdEncoders.Put(z)
+		z.Reset(dst)
+		enc = z
+	default:
+		g := c.gzipEncoders.Get().(*gzip.Writer)
+		defer c.gzipEncoders.Put(g)
+		g.Reset(dst)
+		enc = g
+	}
+	if _, err := io.Copy(enc, src); err != nil {
+		enc.Close()
+		return fmt.Errorf("failed to compress entry: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to compress entry: %w", err)
+	}
+	return nil
+}
+
+func (c *codec) decompress(id byte, src io.Reader) (io.ReadCloser, error) {
+	switch id {
+	case compressionIDZstd:
+		dec := c.zstdDecoders.Get().(*zstd.Decoder)
+		if err := dec.Reset(src); err != nil {
+			c.zstdDecoders.Put(dec)
+			return nil, fmt.Errorf("failed to decompress entry: %w", err)
+		}
+		return &pooledDecoder{Reader: dec, release: func() {
+			// Drop the reference to src before the decoder is reused.
+			dec.Reset(nil)
+			c.zstdDecoders.Put(dec)
+		}}, nil
+	case compressionIDGzip:
+		dec, err := gzip.NewReader(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress entry: %w", err)
+		}
+		return dec, nil
+	}
+	return nil, fmt.Errorf("unknown compression algorithm %d", id)
+}
+
+func compressionName(id byte) string {
+	switch id {
+	case compressionIDZstd:
+		return CompressionZstd
+	case compressionIDGzip:
+		return CompressionGzip
+	case compressionIDStored:
+		return "none"
+	}
+	return "unknown"
+}
+
+type pooledDecoder struct {
+	io.Reader
+	release func()
+}
+
+func (d *pooledDecoder) Close() error {
+	d.release()
+	return nil
+}
+
+// decompressingReader returns a decompressed entry and fails if it does not
+// have the size recorded in its header. On Close it records the time spent
+// decompressing, excluding the time spent reading from the backend.
+type decompressingReader struct {
+	io.ReadCloser
+	source    *timedReader
+	closer    io.Closer
+	remaining int64
+	elapsed   time.Duration
+	record    func(time.Duration)
+}
+
+func (r *decompressingReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := r.ReadCloser.Read(p)
+	r.elapsed += time.Since(start)
+	r.remaining -= int64(n)
+	switch {
+	case r.remaining < 0:
+		return n, fmt.Errorf("decompressed entry is larger than recorded")
+	case errors.Is(err, io.EOF) && r.remaining > 0:
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *decompressingReader) Close() error {
+	r.ReadCloser.Close()
+	if r.elapsed > 0 {
+		r.record(r.elapsed - r.source.elapsed)
+	}
+	return r.closer.Close()
+}
+
+// timedReader measures the time spent in Read.
+type timedReader struct {
+	io.Reader
+	elapsed time.Duration
+}
+
+func (r *timedReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := r.Reader.Read(p)
+	r.elapsed += time.Since(start)
+	return n, err
+}
+
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
+
+type compressionMetrics struct {
+	inputBytes  metric.Int64Counter
+	outputBytes metric.Int64Counter
+	skipped     metric.Int64Counter
+	duration    metric.Float64Histogram
+}
+
+func newCompressionMetrics() (*compressionMetrics, error) {
+	meter := otel.Meter("gradle-cache")
+
+	inputBytes, err := meter.Int64Counter(
+		"gradle_cache.compression_input_bytes",
+		metric.WithDescription("Total uncompressed bytes of entries stored compressed"),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+
+	outputBytes, err := meter.Int64Counter(
+		"gradle_cache.compression_output_bytes",
+		metric.WithDescription("Total compressed bytes of entries stored compressed"),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+
+	skipped, err := meter.Int64Counter(
+		"gradle_cache.compression_skipped",
+		metric.WithDescription("Total number of entries stored uncompressed"))
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := meter.Float64Histogram(
+		"gradle_cache.compression_duration",
+		metric.WithDescription("Time spent compressing or decompressing an entry"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &compressionMetrics{
+		inputBytes:  inputBytes,
+		outputBytes: outputBytes,
+		skipped:     skipped,
+		duration:    duration,
+	}, nil
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"testing"
+)
+
+func newTestCompression(t *testing.T, backend Storage, algorithm string) *CompressionStorage {
+	t.Helper()
+	s, err := NewCompressionStorage(backend, CompressionConfig{Algorithm: algorithm})
+	if err != nil {
+		t.Fatalf("NewCompressionStorage: %v", err)
+	}
+	return s
+}
+
+// storedCodec returns the algorithm ID in the header of the entry stored in
+// backend under key.
+func storedCodec(t *testing.T, backend Storage, key string) (byte, []byte) {
+	t.Helper()
+	raw, err := get(t, backend, key)
+	if err != nil {
+		t.Fatalf("Get(%q) from backend: %v", key, err)
+	}
+	if len(raw) < compressionHeaderSize || !bytes.HasPrefix(raw, []byte(compressionMagic)) {
+		t.Fatalf("entry %q was stored without a compression header", key)
+	}
+	return raw[len(compressionMagic)], raw
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	random := make([]byte, 128<<10)
+	rand.Read(random)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		wantID byte
+	}{
+		{"compressible", bytes.Repeat([]byte("class file contents "), 10000), compressionIDZstd},
+		{"small", []byte("tiny"), compressionIDStored},
+		{"empty", []byte{}, compressionIDStored},
+		{"incompressible", random, compressionIDStored},
+		{"looks compressed", append([]byte(compressionMagic), 2, 0, 0, 0, 0, 0, 0, 0, 1), compressionIDStored},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestFilesystem(t, ExpiryPolicy{})
+			s := newTestCompression(t, backend, CompressionZstd)
+
+			put(t, s, "key", tt.data)
+			id, raw := storedCodec(t, backend, "key")
+			if id != tt.wantID {
+				t.Fatalf("stored with algorithm %d, want %d", id, tt.wantID)
+			}
+			if id != compressionIDStored && len(raw) >= len(tt.data) {
+				t.Fatalf("compressed entry takes %d bytes for %d", len(raw), len(tt.data))
+			}
+			got, err := get(t, s, "key")
+			if err != nil || !bytes.Equal(got, tt.data) {
+				t.Fatalf("Get = %d bytes, %v, want the original %d bytes", len(got), err, len(tt.data))
+			}
+		})
+	}
+}
+
+func TestCompressionUnknownSize(t *testing.T) {
+	s := newTestCompression(t, newTestFilesystem(t, ExpiryPolicy{}), CompressionGzip)
+	data := bytes.Repeat([]byte("streamed "), 1000)
+
+	if err := s.Put(context.Background(), "key", bytes.NewReader(data), -1); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got, err := get(t, s, "key"); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Get = %d bytes, %v, want %d", len(got), err, len(data))
+	}
+}
+
+func TestCompressionReadsOtherEntries(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{})
+	data := bytes.Repeat([]byte("shared output "), 1000)
+
+	// Entries compressed with another algorithm stay readable.
+	put(t, newTestCompression(t, backend, CompressionGzip), "gzip", data)
+	s := newTestCompression(t, backend, CompressionZstd)
+	if got, err := get(t, s, "gzip"); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Get(gzip) = %d bytes, %v, want %d", len(got), err, len(data))
+	}
+
+	// Entries written before compression was enabled are returned as is.
+	put(t, backend, "legacy", []byte("plain"))
+	if got, err := get(t, s, "legacy"); err != nil || string(got) != "plain" {
+		t.Fatalf("Get(legacy) = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,603 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// dedupRefMagic starts the value stored under a key by DedupStorage. It is
+// followed by the SHA-256 of the content the key refers to.
+const dedupRefMagic = "\x00gradle-cache:ref:v1\x00"
+
+// dedupBlobNamespace holds the blobs and their reference counts. Namespace
+// names from requests must start with a letter or digit, so it cannot
+// collide with them.
+const dedupBlobNamespace = "_blobs"
+
+// dedupLockStripes is the number of locks reference count updates are
+// spread over by digest, and reference updates by key.
+const dedupLockStripes = 64
+
+// dedupGCPendingKey holds the blobs awaiting collection in the blob
+// namespace, so that a restart within the GC delay does not leak them.
+const dedupGCPendingKey = "gc-pending"
+
+// DedupStorage stores every distinct content once. A key holds a small
+// reference to a blob named after the SHA-256 of its content, and each blob
+// counts the keys referring to it. When the count drops to zero, the blob is
+// deleted after DedupConfig.GCDelay unless it was referenced again.
+//
+// Reference counts are only kept consistent within one server process.
+// Entries that expire in the backend without going through Delete do not
+// decrement the count; their blobs expire by the backend's TTL instead.
+// Blobs awaiting collection are persisted, and collected after a restart.
+type DedupStorage struct {
+	refs      Storage
+	namespace string
+	shared    *dedupShared
+}
+
+type DedupConfig struct {
+	// GCDelay is how long an unreferenced blob is kept before it is deleted,
+	// so that reads in progress can finish.
+	GCDelay time.Duration
+	// Expiry is the backend's expiry policy. Under a sliding policy,
+	// reference counts are refreshed along with their blobs.
+	Expiry ExpiryPolicy
+}
+
+// dedupShared is the state shared by all namespaces. A key's lock is always
+// taken before any digest lock.
+type dedupShared struct {
+	blobs    Storage
+	expiry   ExpiryPolicy
+	locks    [dedupLockStripes]sync.Mutex
+	keyLocks [dedupLockStripes]sync.Mutex
+	gc       *dedupGC
+	metrics  *dedupMetrics
+}
+
+func NewDedupStorage(backend Storage, cfg DedupConfig, logger zerolog.Logger) (*DedupStorage, error) {
+	namespaced, ok := backend.(NamespacedStorage)
+	if !ok {
+		return nil, fmt.Errorf("deduplication requires a storage backend with namespace support")
+	}
+	metrics, err := newDedupMetrics()
+	if err != nil {
+		return nil, err
+	}
+	shared := &dedupShared{
+		blobs:   namespaced.WithNamespace(dedupBlobNamespace),
+		expiry:  cfg.Expiry,
+		metrics: metrics,
+	}
+	shared.gc = &dedupGC{
+		shared:  shared,
+		delay:   cfg.GCDelay,
+		pending: make(map[string]time.Time),
+		logger:  logger,
+	}
+	if err := shared.gc.load(context.Background()); err != nil {
+		// Blobs left over from a previous process then expire by the
+		// backend's TTL instead.
+		logger.Warn().Err(err).Msg("failed to load pending blob collections")
+	}
+	shared.gc.loop = startLoop(shared.gc.run)
+
+	return &DedupStorage{refs: backend, shared: shared}, nil
+}
+
+func blobKey(digest []byte) string {
+	return "sha256-" + hex.EncodeToString(digest)
+}
+
+func refCountKey(digest []byte) string {
+	return blobKey(digest) + ".refs"
+}
+
+func (sh *dedupShared) lock(digest []byte) *sync.Mutex {
+	return &sh.locks[digest[0]%dedupLockStripes]
+}
+
+// keyLock serializes reference updates of a key, so that concurrent writes
+// and deletes of it cannot lose or double-release a reference.
+func (s *DedupStorage) keyLock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(s.namespace))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return &s.shared.keyLocks[h.Sum32()%dedupLockStripes]
+}
+
+// readRef returns the digest a key refers to. If the key holds an entry
+// written without deduplication, that entry is returned as legacy instead.
+func (s *DedupStorage) readRef(ctx context.Context, key string) (digest []byte, legacy io.ReadCloser, size int64, err error) {
+	reader, size, err := s.refs.Get(ctx, key)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	refSize := int64(len(dedupRefMagic) + sha256.Size)
+	if size != refSize {
+		return nil, reader, size, nil
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, refSize))
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to read reference: %w", err)
+	}
+	if !bytes.HasPrefix(data, []byte(dedupRefMagic)) {
+		return nil, io.NopCloser(bytes.NewReader(data)), size, nil
+	}
+	return data[len(dedupRefMagic):], nil, 0, nil
+}
+
+func (s *DedupStorage) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	digest, legacy, size, err := s.readRef(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if legacy != nil {
+		return legacy, size, nil
+	}
+
+	reader, size, err := s.shared.blobs.Get(ctx, blobKey(digest))
+	if errors.Is(err, ErrNotFound) {
+		// The blob expired before the reference did.
+		_ = s.refs.Delete(ctx, key)
+		return nil, 0, ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	s.shared.touchRefCount(ctx, digest)
+	if _, ok := reader.(Digester); ok {
+		return reader, size, nil
+	}
+	return &digestReadCloser{ReadCloser: reader, digest: digest}, size, nil
+}
+
+func (s *DedupStorage) Put(ctx context.Context, key string, reader io.Reader, size int64) error {
+	entry, err := spool(reader, size)
+	if err != nil {
+		return err
+	}
+	defer entry.Close()
+	s.shared.metrics.logicalBytes.Add(ctx, entry.size)
+
+	mu := s.keyLock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	old, legacy, _, err := s.readRef(ctx, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if legacy != nil {
+		legacy.Close()
+	}
+	if bytes.Equal(old, entry.digest) {
+		// The key already refers to this content; only make sure the blob
+		// has not expired in the meantime.
+		return s.shared.acquire(ctx, entry, false)
+	}
+
+	if err := s.shared.acquire(ctx, entry, true); err != nil {
+		return err
+	}
+	ref := append([]byte(dedupRefMagic), entry.digest...)
+	if err := s.refs.Put(ctx, key, bytes.NewReader(ref), int64(len(ref))); err != nil {
+		s.shared.release(ctx, entry.digest)
+		return err
+	}
+	if old != nil {
+		s.shared.release(ctx, old)
+	}
+	return nil
+}
+
+// Exists checks the blob as well as the reference, since the blob can expire
+// first.
+func (s *DedupStorage) Exists(ctx context.Context, key string) (bool, error) {
+	digest, legacy, _, err := s.readRef(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if legacy != nil {
+		legacy.Close()
+		return true, nil
+	}
+
+	exists, err := s.shared.blobs.Exists(ctx, blobKey(digest))
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		// The blob expired before the reference did.
+		_ = s.refs.Delete(ctx, key)
+		return false, nil
+	}
+	s.shared.touchRefCount(ctx, digest)
+	return true, nil
+}
+
+// Stat describes the blob a key refers to, and takes ModTime from the
+// reference, which is rewritten on every Put of the key.
+func (s *DedupStorage) Stat(ctx context.Context, key string) (EntryInfo, error) {
+	ref, err := Stat(ctx, s.refs, key)
+	if err != nil {
+		return EntryInfo{}, err
+	}
+	digest, legacy, _, err := s.readRef(ctx, key)
+	if err != nil {
+		return EntryInfo{}, err
+	}
+	if legacy != nil {
+		legacy.Close()
+		return ref, nil
+	}
+
+	blob, err := Stat(ctx, s.shared.blobs, blobKey(digest))
+	if errors.Is(err, ErrNotFound) {
+		_ = s.refs.Delete(ctx, key)
+		return EntryInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return EntryInfo{}, err
+	}
+	s.shared.touchRefCount(ctx, digest)
+	return EntryInfo{Size: blob.Size, Digest: digest, ModTime: ref.ModTime}, nil
+}
+
+func (s *DedupStorage) Delete(ctx context.Context, key string) error {
+	mu := s.keyLock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	digest, legacy, _, err := s.readRef(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if legacy != nil {
+		legacy.Close()
+	}
+	if err := s.refs.Delete(ctx, key); err != nil {
+		return err
+	}
+	if digest != nil {
+		s.shared.release(ctx, digest)
+	}
+	return nil
+}
+
+func (s *DedupStorage) Ping(ctx context.Context) error {
+	return s.refs.Ping(ctx)
+}
+
+func (s *DedupStorage) WithNamespace(namespace string) Storage {
+	return &DedupStorage{
+		refs:      withNamespace(s.refs, namespace),
+		namespace: namespace,
+		shared:    s.shared,
+	}
+}
+
+// Close stops collecting unreferenced blobs in the background. Pending
+// collections stay saved, so the next process picks them up.
+func (s *DedupStorage) Close() error {
+	s.shared.gc.loop.stop()
+	return nil
+}
+
+// acquire makes sure the blob of entry exists and, if addRef is set, adds a
+// reference to it.
+func (sh *dedupShared) acquire(ctx context.Context, entry *spooledEntry, addRef bool) error {
+	mu := sh.lock(entry.digest)
+	mu.Lock()
+	defer mu.Unlock()
+
+	count, err := sh.refCount(ctx, entry.digest)
+	if err != nil {
+		return err
+	}
+	exists, err := sh.blobs.Exists(ctx, blobKey(entry.digest))
+	if err != nil {
+		return err
+	}
+	if exists {
+		sh.metrics.reused.Add(ctx, 1)
+		if !addRef {
+			return nil
+		}
+		return sh.setRefCount(ctx, entry.digest, count+1)
+	}
+
+	data, err := entry.Reader()
+	if err != nil {
+		return err
+	}
+	if err := sh.blobs.Put(ctx, blobKey(entry.digest), data, entry.size); err != nil {
+		return err
+	}
+	sh.metrics.storedBytes.Add(ctx, entry.size)
+	// References left over from an expired blob are dangling and no longer
+	// count, so the caller's reference is the only one.
+	return sh.setRefCount(ctx, entry.digest, 1)
+}
+
+// release drops a reference to a blob and schedules it for collection when
+// none are left. Failures only delay collection, so they are not returned.
+func (sh *dedupShared) release(ctx context.Context, digest []byte) {
+	mu := sh.lock(digest)
+	mu.Lock()
+	defer mu.Unlock()
+
+	count, err := sh.refCount(ctx, digest)
+	if err != nil {
+		sh.gc.logger.Warn().Err(err).Str("blob", blobKey(digest)).Msg("failed to read reference count")
+		return
+	}
+	count = max(count-1, 0)
+	if err := sh.setRefCount(ctx, digest, count); err != nil {
+		sh.gc.logger.Warn().Err(err).Str("blob", blobKey(digest)).Msg("failed to update reference count")
+		return
+	}
+	if count == 0 {
+		sh.gc.schedule(digest)
+	}
+}
+
+// touchRefCount refreshes the reference count of a blob that was just found
+// and thereby refreshed, so that under a sliding expiry policy the count
+// does not expire while the blob and its references are still in use. A
+// lost count would restart at one and let the blob be collected while other
+// keys still refer to it.
+func (sh *dedupShared) touchRefCount(ctx context.Context, digest []byte) {
+	if !sh.expiry.slides(ctx) {
+		return
+	}
+	if _, err := sh.blobs.Exists(ctx, refCountKey(digest)); err != nil {
+		sh.gc.logger.Warn().Err(err).Str("blob", blobKey(digest)).Msg("failed to refresh reference count")
+	}
+}
+
+// refCount must be called with the digest's lock held.
+func (sh *dedupShared) refCount(ctx context.Context, digest []byte) (int64, error) {
+	reader, _, err := sh.blobs.Get(ctx, refCountKey(digest))
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, 32))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read reference count: %w", err)
+	}
+	count, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid reference count for %s: %w", blobKey(digest), err)
+	}
+	return count, nil
+}
+
+// setRefCount must be called with the digest's lock held.
+func (sh *dedupShared) setRefCount(ctx context.Context, digest []byte, count int64) error {
+	data := strconv.FormatInt(count, 10)
+	return sh.blobs.Put(ctx, refCountKey(digest), bytes.NewReader([]byte(data)), int64(len(data)))
+}
+
+// dedupGC deletes blobs that stayed unreferenced for the GC delay. The
+// pending deletions are saved under dedupGCPendingKey whenever they change.
+type dedupGC struct {
+	shared *dedupShared
+	delay  time.Duration
+	logger zerolog.Logger
+
+	mu      sync.Mutex
+	pending map[string]time.Time
+
+	// saveMu orders saves, so an older snapshot never overwrites a newer one.
+	saveMu sync.Mutex
+
+	// loop runs the periodic collections.
+	loop *backgroundLoop
+}
+
+func (gc *dedupGC) schedule(digest []byte) {
+	gc.mu.Lock()
+	gc.pending[string(digest)] = time.Now().Add(gc.delay)
+	gc.mu.Unlock()
+	gc.save(context.Background())
+}
+
+// load adds the pending deletions saved by a previous process. Each line
+// holds a hex digest and the Unix time in nanoseconds it is due at.
+func (gc *dedupGC) load(ctx context.Context) error {
+	reader, _, err := gc.shared.blobs.Get(ctx, dedupGCPendingKey)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	pending := make(map[string]time.Time)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		hexDigest, at, ok := strings.Cut(scanner.Text(), " ")
+		digest, err := hex.DecodeString(hexDigest)
+		if !ok || err != nil || len(digest) != sha256.Size {
+			return fmt.Errorf("invalid pending blob collection %q", scanner.Text())
+		}
+		nanos, err := strconv.ParseInt(at, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid pending blob collection %q", scanner.Text())
+		}
+		pending[string(digest)] = time.Unix(0, nanos)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read pending blob collections: %w", err)
+	}
+
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	for digest, at := range pending {
+		if _, ok := gc.pending[digest]; !ok {
+			gc.pending[digest] = at
+		}
+	}
+	return nil
+}
+
+// save writes the pending deletions. Failures are logged, since they only
+// matter if the process restarts before the blobs are collected.
+func (gc *dedupGC) save(ctx context.Context) {
+	gc.saveMu.Lock()
+	defer gc.saveMu.Unlock()
+
+	var buf bytes.Buffer
+	gc.mu.Lock()
+	for digest, at := range gc.pending {
+		fmt.Fprintf(&buf, "%x %d\n", digest, at.UnixNano())
+	}
+	gc.mu.Unlock()
+
+	var err error
+	if buf.Len() == 0 {
+		err = gc.shared.blobs.Delete(ctx, dedupGCPendingKey)
+	} else {
+		err = gc.shared.blobs.Put(ctx, dedupGCPendingKey, &buf, int64(buf.Len()))
+	}
+	if err != nil {
+		gc.logger.Warn().Err(err).Msg("failed to save pending blob collections")
+	}
+}
+
+// run collects due blobs periodically until ctx is cancelled.
+func (gc *dedupGC) run(ctx context.Context) {
+	interval := min(max(gc.delay/2, time.Second), time.Minute)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			gc.collect(ctx, now)
+		}
+	}
+}
+
+func (gc *dedupGC) collect(ctx context.Context, now time.Time) {
+	var due [][]byte
+	gc.mu.Lock()
+	for digest, at := range gc.pending {
+		if now.After(at) {
+			due = append(due, []byte(digest))
+			delete(gc.pending, digest)
+		}
+	}
+	gc.mu.Unlock()
+
+	for _, digest := range due {
+		gc.collectBlob(ctx, digest)
+	}
+	if len(due) > 0 {
+		gc.save(ctx)
+	}
+}
+
+func (gc *dedupGC) collectBlob(ctx context.Context, digest []byte) {
+	sh := gc.shared
+	mu := sh.lock(digest)
+	mu.Lock()
+	defer mu.Unlock()
+
+	count, err := sh.refCount(ctx, digest)
+	if err != nil {
+		gc.logger.Warn().Err(err).Str("blob", blobKey(digest)).Msg("failed to read reference count")
+		return
+	}
+	if count > 0 {
+		return
+	}
+	if err := sh.blobs.Delete(ctx, blobKey(digest)); err != nil {
+		gc.logger.Warn().Err(err).Str("blob", blobKey(digest)).Msg("failed to delete unreferenced blob")
+		return
+	}
+	if err := sh.blobs.Delete(ctx, refCountKey(digest)); err != nil {
+		gc.logger.Warn().Err(err).Str("blob", blobKey(digest)).Msg("failed to delete reference count")
+	}
+	sh.metrics.collected.Add(ctx, 1)
+}
+
+type dedupMetrics struct {
+	logicalBytes metric.Int64Counter
+	storedBytes  metric.Int64Counter
+	reused       metric.Int64Counter
+	collected    metric.Int64Counter
+}
+
+func newDedupMetrics() (*dedupMetrics, error) {
+	meter := otel.Meter("gradle-cache")
+
+	logicalBytes, err := meter.Int64Counter(
+		"gradle_cache.dedup_logical_bytes",
+		metric.WithDescription("Total bytes uploaded to the deduplicating storage"),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+
+	storedBytes, err := meter.Int64Counter(
+		"gradle_cache.dedup_stored_bytes",
+		metric.WithDescription("Total bytes written as new blobs by the deduplicating storage"),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+
+	reused, err := meter.Int64Counter(
+		"gradle_cache.dedup_reused_blobs",
+		metric.WithDescription("Total number of uploads that referenced an existing blob"))
+	if err != nil {
+		return nil, err
+	}
+
+	collected, err := meter.Int64Counter(
+		"gradle_cache.dedup_collected_blobs",
+		metric.WithDescription("Total number of unreferenced blobs deleted"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &dedupMetrics{
+		logicalBytes: logicalBytes,
+		storedBytes:  storedBytes,
+		reused:       reused,
+		collected:    collected,
+	}, nil
+}
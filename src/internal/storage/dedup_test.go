@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const testGCDelay = time.Minute
+
+func newTestDedup(t *testing.T, backend Storage) *DedupStorage {
+	t.Helper()
+	s, err := NewDedupStorage(backend, DedupConfig{GCDelay: testGCDelay}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewDedupStorage: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func refCountOf(t *testing.T, s *DedupStorage, data []byte) int64 {
+	t.Helper()
+	digest := sha256.Sum256(data)
+	count, err := s.shared.refCount(context.Background(), digest[:])
+	if err != nil {
+		t.Fatalf("refCount: %v", err)
+	}
+	return count
+}
+
+func blobExists(t *testing.T, s *DedupStorage, data []byte) bool {
+	t.Helper()
+	digest := sha256.Sum256(data)
+	return exists(t, s.shared.blobs, blobKey(digest[:]))
+}
+
+func TestDedupSharesBlobs(t *testing.T) {
+	s := newTestDedup(t, newTestFilesystem(t, ExpiryPolicy{}))
+	data := []byte("identical output")
+
+	put(t, s, "a", data)
+	put(t, s.WithNamespace("other"), "b", data)
+	if got := refCountOf(t, s, data); got != 2 {
+		t.Fatalf("reference count = %d, want 2", got)
+	}
+	if got, err := get(t, s, "a"); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Get(a) = %q, %v, want %q", got, err, data)
+	}
+	if got, err := get(t, s.WithNamespace("other"), "b"); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Get(b) = %q, %v, want %q", got, err, data)
+	}
+	if _, err := get(t, s, "b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(b) outside its namespace = %v, want ErrNotFound", err)
+	}
+
+	// Writing the same content again does not add a reference.
+	put(t, s, "a", data)
+	if got := refCountOf(t, s, data); got != 2 {
+		t.Fatalf("reference count after rewrite = %d, want 2", got)
+	}
+}
+
+func TestDedupCollectsUnreferencedBlobs(t *testing.T) {
+	s := newTestDedup(t, newTestFilesystem(t, ExpiryPolicy{}))
+	ctx := context.Background()
+	oldData, newData := []byte("old content"), []byte("new content")
+
+	put(t, s, "a", oldData)
+	put(t, s, "b", oldData)
+	put(t, s, "a", newData)
+	if got := refCountOf(t, s, oldData); got != 1 {
+		t.Fatalf("reference count after overwrite = %d, want 1", got)
+	}
+
+	if err := s.Delete(ctx, "b"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := refCountOf(t, s, oldData); got != 0 {
+		t.Fatalf("reference count after delete = %d, want 0", got)
+	}
+
+	// The blob survives until the GC delay has passed.
+	s.shared.gc.collect(ctx, time.Now())
+	if !blobExists(t, s, oldData) {
+		t.Fatal("blob collected before the GC delay")
+	}
+	s.shared.gc.collect(ctx, time.Now().Add(testGCDelay+time.Second))
+	if blobExists(t, s, oldData) {
+		t.Fatal("unreferenced blob was not collected")
+	}
+	if !blobExists(t, s, newData) {
+		t.Fatal("referenced blob was collected")
+	}
+}
+
+func TestDedupKeepsReferencedAgain(t *testing.T) {
+	s := newTestDedup(t, newTestFilesystem(t, ExpiryPolicy{}))
+	ctx := context.Background()
+	data := []byte("content")
+
+	put(t, s, "a", data)
+	if err := s.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	put(t, s, "b", data)
+
+	s.shared.gc.collect(ctx, time.Now().Add(testGCDelay+time.Second))
+	if got, err := get(t, s, "b"); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Get(b) = %q, %v, want %q", got, err, data)
+	}
+}
+
+func TestDedupPersistsPendingCollections(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{})
+	s := newTestDedup(t, backend)
+	ctx := context.Background()
+	data := []byte("content")
+
+	put(t, s, "a", data)
+	if err := s.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// A restarted process picks up the pending collection.
+	restarted := newTestDedup(t, backend)
+	restarted.shared.gc.collect(ctx, time.Now().Add(testGCDelay+time.Second))
+	if blobExists(t, restarted, data) {
+		t.Fatal("blob scheduled before the restart was not collected")
+	}
+	if exists(t, restarted.shared.blobs, dedupGCPendingKey) {
+		t.Fatal("pending collections were not cleared")
+	}
+}
+
+func TestDedupConcurrentWrites(t *testing.T) {
+	s := newTestDedup(t, newTestFilesystem(t, ExpiryPolicy{}))
+	ctx := context.Background()
+
+	var contents [][]byte
+	for i := range 8 {
+		contents = append(contents, []byte("content "+strconv.Itoa(i)))
+	}
+	var wg sync.WaitGroup
+	for _, data := range contents {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				if err := s.Put(ctx, "key", bytes.NewReader(data), int64(len(data))); err != nil {
+					t.Errorf("Put: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := get(t, s, "key")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	// Exactly one reference remains, to the content the key holds.
+	for _, data := range contents {
+		want := int64(0)
+		if bytes.Equal(data, got) {
+			want = 1
+		}
+		if count := refCountOf(t, s, data); count != want {
+			t.Fatalf("reference count of %q = %d, want %d", data, count, want)
+		}
+	}
+}
+
+func TestDedupReadsLegacyEntries(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{})
+	put(t, backend, "legacy", []byte("written before dedup"))
+	s := newTestDedup(t, backend)
+
+	if got, err := get(t, s, "legacy"); err != nil || string(got) != "written before dedup" {
+		t.Fatalf("Get(legacy) = %q, %v", got, err)
+	}
+	if err := s.Delete(context.Background(), "legacy"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if exists(t, s, "legacy") {
+		t.Fatal("legacy entry was not deleted")
+	}
+}
+
+func TestDedupBlobExpiresBeforeReference(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{TTL: time.Hour})
+	s := newTestDedup(t, backend)
+	data := []byte("cas blob")
+	put(t, s, "key", data)
+
+	// The blob was stored earlier, for another key, and expires first.
+	digest := sha256.Sum256(data)
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(backend.WithNamespace(dedupBlobNamespace).(*FilesystemStorage).path(blobKey(digest[:])), old, old)
+
+	if exists(t, s, "key") {
+		t.Fatal("Exists = true for an entry whose blob expired")
+	}
+	if exists(t, backend, "key") {
+		t.Fatal("dangling reference was kept")
+	}
+}
+
+func TestDedupRefreshesReferenceCounts(t *testing.T) {
+	expiry := ExpiryPolicy{TTL: time.Hour, Sliding: true}
+	backend := newTestFilesystem(t, expiry)
+	s, err := NewDedupStorage(backend, DedupConfig{GCDelay: testGCDelay, Expiry: expiry}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewDedupStorage: %v", err)
+	}
+	data := []byte("hot output")
+	put(t, s, "a", data)
+	put(t, s, "b", data)
+
+	// Age every file by 45 minutes, then read the entries as builds would.
+	age := func() {
+		filepath.WalkDir(backend.root, func(path string, d os.DirEntry, err error) error {
+			if info, err := os.Stat(path); err == nil && !d.IsDir() {
+				old := info.ModTime().Add(-45 * time.Minute)
+				os.Chtimes(path, old, old)
+			}
+			return nil
+		})
+	}
+	for range 2 {
+		age()
+		get(t, s, "a")
+		exists(t, s, "b")
+	}
+
+	// The count outlived the TTL of its last write along with the blob.
+	put(t, s, "c", data)
+	if got := refCountOf(t, s, data); got != 3 {
+		t.Fatalf("reference count = %d, want 3", got)
+	}
+	if err := s.Delete(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	s.shared.gc.collect(context.Background(), time.Now().Add(2*testGCDelay))
+	if !blobExists(t, s, data) {
+		t.Fatal("blob still referenced by b and c was collected")
+	}
+}
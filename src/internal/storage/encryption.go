@@ -0,0 +1,500 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ErrDecryptionFailed is returned while reading an encrypted entry that was
+// modified or truncated in the backend.
+var ErrDecryptionFailed = errors.New("decryption failed")
+
+// encryptionMagic starts every entry written by EncryptionStorage. It is
+// followed by the length of the key ID, the key ID, the entry salt, the
+// nonce prefix and the encrypted segments.
+const encryptionMagic = "\x00gradle-cache:aes-gcm:v1\x00"
+
+const (
+	// EncryptionKeySize is the size of a master key in bytes.
+	EncryptionKeySize = 32
+
+	// encryptionSegmentSize is the plaintext size of all segments but the
+	// last. Each segment is sealed on its own, so entries are encrypted and
+	// decrypted while streaming.
+	encryptionSegmentSize = 64 << 10
+	encryptionSaltSize    = 16
+	// The nonce of a segment is the prefix, the segment counter and a flag
+	// marking the last segment, so segments cannot be reordered, dropped or
+	// truncated without failing authentication.
+	encryptionNoncePrefixSize = 7
+
+	// encryptionCachedNamespaces is the number of namespaces whose keys are
+	// kept after derivation.
+	encryptionCachedNamespaces = 1024
+)
+
+// EncryptionKey is a master key. ID is stored with every entry, so entries
+// stay readable after a new key becomes active as long as their key is still
+// configured.
+type EncryptionKey struct {
+	ID     string
+	Secret []byte
+}
+
+type EncryptionConfig struct {
+	// ActiveKey is the ID of the key new entries are encrypted with.
+	ActiveKey string
+	Keys      []EncryptionKey
+}
+
+// EncryptionStorage encrypts entries with AES-256-GCM. Every namespace uses
+// its own data keys, derived from the master keys with HKDF, and every entry
+// is encrypted with a key derived from its namespace key and a random salt.
+// The cache key is authenticated along with the data, so entries cannot be
+// moved between keys or namespaces unnoticed.
+//
+// Entries that are not encrypted, use an unknown key or fail authentication
+// are reported as ErrNotFound, so clients rebuild and upload them again.
+// Entries that fail authentication are deleted.
+type EncryptionStorage struct {
+	backend Storage
+	// keys holds the namespace keys by master key ID.
+	keys    map[string][]byte
+	masters []EncryptionKey
+	active  string
+	// namespaceKeys caches the keys of recently used namespaces, since
+	// WithNamespace runs for every request. It is bounded, as clients may
+	// choose namespaces freely.
+	namespaceKeys *lruCache
+	metrics       *encryptionMetrics
+	logger        zerolog.Logger
+}
+
+// GenerateEncryptionKey returns a new random master key, base64 encoded.
+func GenerateEncryptionKey() (string, error) {
+	key := make([]byte, EncryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func NewEncryptionStorage(backend Storage, cfg EncryptionConfig, logger zerolog.Logger) (*EncryptionStorage, error) {
+	found := false
+	for _, k := range cfg.Keys {
+		if len(k.Secret) != EncryptionKeySize {
+			return nil, fmt.Errorf("encryption key %q must be %d bytes", k.ID, EncryptionKeySize)
+		}
+		if len(k.ID) == 0 || len(k.ID) > 255 {
+			return nil, fmt.Errorf("encryption key IDs must be 1 to 255 bytes long")
+		}
+		found = found || k.ID == cfg.ActiveKey
+	}
+	if !found {
+		return nil, fmt.Errorf("active encryption key %q is not configured", cfg.ActiveKey)
+	}
+	metrics, err := newEncryptionMetrics()
+	if err != nil {
+		return nil, err
+	}
+	s := &EncryptionStorage{
+		backend:       backend,
+		masters:       cfg.Keys,
+		active:        cfg.ActiveKey,
+		namespaceKeys: newLRUCache(encryptionCachedNamespaces*int64(len(cfg.Keys))*EncryptionKeySize, encryptionCachedNamespaces*len(cfg.Keys), 0),
+		metrics:       metrics,
+		logger:        logger,
+	}
+	if s.keys, err = s.deriveKeys(""); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// deriveKeys returns the namespace keys of all master keys.
+func (s *EncryptionStorage) deriveKeys(namespace string) (map[string][]byte, error) {
+	keys := make(map[string][]byte, len(s.masters))
+	for _, k := range s.masters {
+		cacheKey := namespace + "\x00" + k.ID
+		if cached, ok := s.namespaceKeys.get(cacheKey); ok {
+			keys[k.ID] = cached.data
+			continue
+		}
+		key, err := hkdf.Key(sha256.New, k.Secret, nil, "gradle-cache namespace "+namespace, EncryptionKeySize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive namespace key: %w", err)
+		}
+		s.namespaceKeys.add(cacheKey, key, nil)
+		keys[k.ID] = key
+	}
+	return keys, nil
+}
+
+// entryCipher returns the cipher for an entry encrypted with the namespace
+// key of keyID and salt.
+func (s *EncryptionStorage) entryCipher(keyID string, salt []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, s.keys[keyID], salt, "gradle-cache entry", EncryptionKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive entry key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *EncryptionStorage) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	reader, size, err := s.backend.Get(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	prefix := make([]byte, len(encryptionMagic)+1)
+	if _, err := io.ReadFull(reader, prefix); err != nil || !bytes.HasPrefix(prefix, []byte(encryptionMagic)) {
+		reader.Close()
+		s.metrics.failure(ctx, "unencrypted")
+		return nil, 0, ErrNotFound
+	}
+	rest := make([]byte, int(prefix[len(encryptionMagic)])+encryptionSaltSize+encryptionNoncePrefixSize)
+	if _, err := io.ReadFull(reader, rest); err != nil {
+		reader.Close()
+		s.corrupted(ctx, key)
+		return nil, 0, ErrNotFound
+	}
+	idLen := len(rest) - encryptionSaltSize - encryptionNoncePrefixSize
+	keyID := string(rest[:idLen])
+	salt := rest[idLen : idLen+encryptionSaltSize]
+	noncePrefix := rest[idLen+encryptionSaltSize:]
+
+	if _, ok := s.keys[keyID]; !ok {
+		reader.Close()
+		s.metrics.failure(ctx, "unknown_key")
+		s.logger.Warn().Str("key", key).Str("key_id", keyID).Msg("cache entry is encrypted with an unknown key")
+		return nil, 0, ErrNotFound
+	}
+	aead, err := s.entryCipher(keyID, salt)
+	if err != nil {
+		reader.Close()
+		return nil, 0, err
+	}
+
+	body := size - int64(len(prefix)+len(rest))
+	plainSize, ok := decryptedSize(body, aead.Overhead())
+	if !ok {
+		reader.Close()
+		s.corrupted(ctx, key)
+		return nil, 0, ErrNotFound
+	}
+
+	dr := &decryptingReader{
+		ReadCloser:  reader,
+		aead:        aead,
+		noncePrefix: noncePrefix,
+		aad:         []byte(key),
+		remaining:   body,
+		onFailure:   func() { s.corrupted(ctx, key) },
+	}
+	// Decrypt the first segment right away, so entries that fail
+	// authentication, including all small ones, are caught before a
+	// response is started.
+	if err := dr.fill(); err != nil {
+		reader.Close()
+		if errors.Is(err, ErrDecryptionFailed) {
+			return nil, 0, ErrNotFound
+		}
+		return nil, 0, err
+	}
+	s.metrics.decrypted.Add(ctx, 1, metric.WithAttributes(attribute.String("key_id", keyID)))
+	return dr, plainSize, nil
+}
+
+// Stat checks the header of the entry for a known key and derives the
+// plaintext size from the stored one. Whether the entry authenticates is
+// only found out by Get.
+func (s *EncryptionStorage) Stat(ctx context.Context, key string) (EntryInfo, error) {
+	const maxHeaderSize = len(encryptionMagic) + 1 + math.MaxUint8 + encryptionSaltSize + encryptionNoncePrefixSize
+	info, header, err := statHeader(ctx, s.backend, key, maxHeaderSize)
+	if err != nil {
+		return EntryInfo{}, err
+	}
+	if len(header) <= len(encryptionMagic) || !bytes.HasPrefix(header, []byte(encryptionMagic)) {
+		return EntryInfo{}, ErrNotFound
+	}
+	idLen := int(header[len(encryptionMagic)])
+	headerSize := len(encryptionMagic) + 1 + idLen + encryptionSaltSize + encryptionNoncePrefixSize
+	if len(header) < headerSize {
+		return EntryInfo{}, ErrNotFound
+	}
+	keyID := string(header[len(encryptionMagic)+1:][:idLen])
+	salt := header[len(encryptionMagic)+1+idLen:][:encryptionSaltSize]
+	if _, ok := s.keys[keyID]; !ok {
+		return EntryInfo{}, ErrNotFound
+	}
+	if info.Size < 0 {
+		return EntryInfo{Size: -1, ModTime: info.ModTime}, nil
+	}
+
+	aead, err := s.entryCipher(keyID, salt)
+	if err != nil {
+		return EntryInfo{}, err
+	}
+	size, ok := decryptedSize(info.Size-int64(headerSize), aead.Overhead())
+	if !ok {
+		return EntryInfo{}, ErrNotFound
+	}
+	return EntryInfo{Size: size, ModTime: info.ModTime}, nil
+}
+
+// corrupted deletes an entry that failed authentication.
+func (s *EncryptionStorage) corrupted(ctx context.Context, key string) {
+	s.metrics.failure(ctx, "invalid")
+	// The entry is unusable either way; a failed delete leaves it to be
+	// caught again on the next read.
+	_ = s.backend.Delete(context.WithoutCancel(ctx), key)
+}
+
+func (s *EncryptionStorage) Put(ctx context.Context, key string, reader io.Reader, size int64) error {
+	if size < 0 {
+		// Segments are sealed as they are read, which needs the entry size
+		// to know which segment is the last one.
+		entry, err := spool(reader, size)
+		if err != nil {
+			return err
+		}
+		defer entry.Close()
+		if reader, err = entry.Reader(); err != nil {
+			return err
+		}
+		size = entry.size
+	}
+	salt := make([]byte, encryptionSaltSize)
+	noncePrefix := make([]byte, encryptionNoncePrefixSize)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	if _, err := rand.Read(noncePrefix); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	aead, err := s.entryCipher(s.active, salt)
+	if err != nil {
+		return err
+	}
+
+	header := []byte(encryptionMagic)
+	header = append(header, byte(len(s.active)))
+	header = append(header, s.active...)
+	header = append(header, salt...)
+	header = append(header, noncePrefix...)
+
+	er := &encryptingReader{
+		reader:      reader,
+		aead:        aead,
+		noncePrefix: noncePrefix,
+		aad:         []byte(key),
+		remaining:   size,
+	}
+	total := int64(len(header)) + encryptedSize(size, aead.Overhead())
+	return s.backend.Put(ctx, key, io.MultiReader(bytes.NewReader(header), er), total)
+}
+
+func (s *EncryptionStorage) Exists(ctx context.Context, key string) (bool, error) {
+	return s.backend.Exists(ctx, key)
+}
+
+func (s *EncryptionStorage) Delete(ctx context.Context, key string) error {
+	return s.backend.Delete(ctx, key)
+}
+
+func (s *EncryptionStorage) Ping(ctx context.Context) error {
+	return s.backend.Ping(ctx)
+}
+
+func (s *EncryptionStorage) WithNamespace(namespace string) Storage {
+	ns := &EncryptionStorage{
+		backend:       withNamespace(s.backend, namespace),
+		masters:       s.masters,
+		active:        s.active,
+		namespaceKeys: s.namespaceKeys,
+		metrics:       s.metrics,
+		logger:        s.logger,
+	}
+	// Key derivation only fails for invalid lengths, which
+	// NewEncryptionStorage has ruled out.
+	ns.keys, _ = ns.deriveKeys(namespace)
+	return ns
+}
+
+// encryptedSize returns the stored size of size bytes of plaintext, not
+// counting the header. Empty entries still have one segment.
+func encryptedSize(size int64, overhead int) int64 {
+	segments := max((size+encryptionSegmentSize-1)/encryptionSegmentSize, 1)
+	return size + segments*int64(overhead)
+}
+
+// decryptedSize is the inverse of encryptedSize.
+func decryptedSize(size int64, overhead int) (int64, bool) {
+	full := size / int64(encryptionSegmentSize+overhead)
+	rest := size % int64(encryptionSegmentSize+overhead)
+	switch {
+	case rest == 0 && full > 0:
+		return full * encryptionSegmentSize, true
+	case rest >= int64(overhead):
+		return full*encryptionSegmentSize + rest - int64(overhead), true
+	}
+	return 0, false
+}
+
+func segmentNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 0, encryptionNoncePrefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// encryptingReader reads plaintext from reader and returns it encrypted,
+// one segment at a time.
+type encryptingReader struct {
+	reader      io.Reader
+	aead        cipher.AEAD
+	noncePrefix []byte
+	aad         []byte
+	remaining   int64
+	counter     uint32
+	buf         []byte
+	out         []byte
+	done        bool
+}
+
+func (r *encryptingReader) Read(p []byte) (int, error) {
+	if len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if r.buf == nil {
+			r.buf = make([]byte, encryptionSegmentSize+r.aead.Overhead())
+		}
+		n := min(r.remaining, encryptionSegmentSize)
+		if _, err := io.ReadFull(r.reader, r.buf[:n]); err != nil {
+			return 0, fmt.Errorf("failed to read entry: %w", err)
+		}
+		r.remaining -= n
+		r.done = r.remaining == 0
+		nonce := segmentNonce(r.noncePrefix, r.counter, r.done)
+		r.out = r.aead.Seal(r.buf[:0], nonce, r.buf[:n], r.aad)
+		r.counter++
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// decryptingReader returns the plaintext of an encrypted entry, verifying
+// every segment before any of its bytes are returned.
+type decryptingReader struct {
+	io.ReadCloser
+	aead        cipher.AEAD
+	noncePrefix []byte
+	aad         []byte
+	remaining   int64
+	counter     uint32
+	buf         []byte
+	out         []byte
+	done        bool
+	onFailure   func()
+	err         error
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	if len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// fill decrypts the next segment.
+func (r *decryptingReader) fill() error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.buf == nil {
+		r.buf = make([]byte, encryptionSegmentSize+r.aead.Overhead())
+	}
+	n := min(r.remaining, int64(len(r.buf)))
+	if _, err := io.ReadFull(r.ReadCloser, r.buf[:n]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return r.fail()
+		}
+		return fmt.Errorf("failed to read entry: %w", err)
+	}
+	r.remaining -= n
+	r.done = r.remaining == 0
+	out, err := r.aead.Open(r.buf[:0], segmentNonce(r.noncePrefix, r.counter, r.done), r.buf[:n], r.aad)
+	if err != nil {
+		return r.fail()
+	}
+	r.out = out
+	r.counter++
+	return nil
+}
+
+func (r *decryptingReader) fail() error {
+	r.err = ErrDecryptionFailed
+	r.onFailure()
+	return r.err
+}
+
+type encryptionMetrics struct {
+	decrypted metric.Int64Counter
+	failures  metric.Int64Counter
+}
+
+func newEncryptionMetrics() (*encryptionMetrics, error) {
+	meter := otel.Meter("gradle-cache")
+
+	decrypted, err := meter.Int64Counter(
+		"gradle_cache.decrypted_entries",
+		metric.WithDescription("Total number of entries decrypted, by master key ID"))
+	if err != nil {
+		return nil, err
+	}
+
+	failures, err := meter.Int64Counter(
+		"gradle_cache.decryption_failures",
+		metric.WithDescription("Total number of entries that could not be decrypted"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptionMetrics{decrypted: decrypted, failures: failures}, nil
+}
+
+func (m *encryptionMetrics) failure(ctx context.Context, reason string) {
+	m.failures.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+}
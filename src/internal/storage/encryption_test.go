@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func testEncryptionKey(id string, fill byte) EncryptionKey {
+	return EncryptionKey{ID: id, Secret: bytes.Repeat([]byte{fill}, EncryptionKeySize)}
+}
+
+func newTestEncryption(t *testing.T, backend Storage, active string, keys ...EncryptionKey) *EncryptionStorage {
+	t.Helper()
+	s, err := NewEncryptionStorage(backend, EncryptionConfig{ActiveKey: active, Keys: keys}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewEncryptionStorage: %v", err)
+	}
+	return s
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, encryptionSegmentSize, 2*encryptionSegmentSize + 17} {
+		backend := newTestFilesystem(t, ExpiryPolicy{})
+		s := newTestEncryption(t, backend, "k1", testEncryptionKey("k1", 1))
+		data := bytes.Repeat([]byte("secret solution "), size/16+1)[:size]
+
+		put(t, s, "key", data)
+		got, err := get(t, s, "key")
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("size %d: Get = %d bytes, %v", size, len(got), err)
+		}
+		if raw, _ := get(t, backend, "key"); size > 0 && bytes.Contains(raw, []byte("secret solution")) {
+			t.Fatalf("size %d: backend holds plaintext", size)
+		}
+	}
+}
+
+func TestEncryptionUnknownSize(t *testing.T) {
+	s := newTestEncryption(t, newTestFilesystem(t, ExpiryPolicy{}), "k1", testEncryptionKey("k1", 1))
+	data := bytes.Repeat([]byte("x"), encryptionSegmentSize+1)
+
+	if err := s.Put(context.Background(), "key", bytes.NewReader(data), -1); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got, err := get(t, s, "key"); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Get = %d bytes, %v", len(got), err)
+	}
+}
+
+func TestEncryptionRejectsForeignEntries(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{})
+	s := newTestEncryption(t, backend, "k1", testEncryptionKey("k1", 1))
+	put(t, s.WithNamespace("a"), "key", []byte("exercise a"))
+	raw, err := get(t, backend.WithNamespace("a"), "key")
+	if err != nil {
+		t.Fatalf("Get from backend: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		key       string
+		data      []byte
+	}{
+		{"other namespace", "b", "key", raw},
+		{"other key", "a", "moved", raw},
+		{"modified", "a", "modified", append(bytes.Clone(raw[:len(raw)-1]), raw[len(raw)-1]^1)},
+		{"truncated", "a", "truncated", raw[:len(raw)-4]},
+		{"unencrypted", "a", "plain", []byte("not encrypted")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			put(t, backend.WithNamespace(tt.namespace), tt.key, tt.data)
+			if _, err := get(t, s.WithNamespace(tt.namespace), tt.key); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get = %v, want ErrNotFound", err)
+			}
+		})
+	}
+	if exists(t, backend.WithNamespace("a"), "modified") {
+		t.Fatal("modified entry was not deleted")
+	}
+}
+
+func TestEncryptionKeyRotation(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{})
+	old, current := testEncryptionKey("old", 1), testEncryptionKey("new", 2)
+	put(t, newTestEncryption(t, backend, "old", old), "key", []byte("before rotation"))
+
+	rotated := newTestEncryption(t, backend, "new", current, old)
+	if got, err := get(t, rotated, "key"); err != nil || string(got) != "before rotation" {
+		t.Fatalf("Get after rotation = %q, %v", got, err)
+	}
+
+	removed := newTestEncryption(t, backend, "new", current)
+	if _, err := get(t, removed, "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get without the old key = %v, want ErrNotFound", err)
+	}
+}
+
+func TestEncryptionCachesNamespaceKeys(t *testing.T) {
+	s := newTestEncryption(t, newTestFilesystem(t, ExpiryPolicy{}), "k1", testEncryptionKey("k1", 1))
+
+	a := s.WithNamespace("a").(*EncryptionStorage)
+	again := s.WithNamespace("a").(*EncryptionStorage)
+	b := s.WithNamespace("b").(*EncryptionStorage)
+	if &a.keys["k1"][0] != &again.keys["k1"][0] {
+		t.Fatal("namespace keys were derived again")
+	}
+	if bytes.Equal(a.keys["k1"], b.keys["k1"]) {
+		t.Fatal("namespaces share a key")
+	}
+}
+
+func TestEncryptionBoundsNamespaceKeys(t *testing.T) {
+	s := newTestEncryption(t, newTestFilesystem(t, ExpiryPolicy{}), "k1", testEncryptionKey("k1", 1), testEncryptionKey("k2", 2))
+
+	for i := range 2 * encryptionCachedNamespaces {
+		s.WithNamespace("ns" + strconv.Itoa(i))
+	}
+	if n := len(s.namespaceKeys.items); n > 2*encryptionCachedNamespaces {
+		t.Fatalf("cached %d keys, want at most %d", n, 2*encryptionCachedNamespaces)
+	}
+
+	// Evicted keys are derived again, to the same value.
+	put(t, s.WithNamespace("ns0"), "key", []byte("still readable"))
+	for i := range 2 * encryptionCachedNamespaces {
+		s.WithNamespace("other" + strconv.Itoa(i))
+	}
+	if got, err := get(t, s.WithNamespace("ns0"), "key"); err != nil || string(got) != "still readable" {
+		t.Fatalf("Get after eviction = %q, %v", got, err)
+	}
+}
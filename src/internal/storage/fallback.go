@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// FallbackStorage reads from a primary storage and, on a miss, from an
+// ordered list of fallback storages. Writes and deletes only ever touch the
+// primary, so fallbacks are read-only through this view.
+type FallbackStorage struct {
+	primary   Storage
+	fallbacks []Storage
+}
+
+func NewFallbackStorage(primary Storage, fallbacks ...Storage) *FallbackStorage {
+	return &FallbackStorage{
+		primary:   primary,
+		fallbacks: fallbacks,
+	}
+}
+
+func (s *FallbackStorage) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	reader, size, err := s.primary.Get(ctx, key)
+	if !errors.Is(err, ErrNotFound) {
+		return reader, size, err
+	}
+	for _, fallback := range s.fallbacks {
+		reader, size, err = fallback.Get(ctx, key)
+		if !errors.Is(err, ErrNotFound) {
+			return reader, size, err
+		}
+	}
+	return nil, 0, ErrNotFound
+}
+
+func (s *FallbackStorage) Put(ctx context.Context, key string, reader io.Reader, size int64) error {
+	return s.primary.Put(ctx, key, reader, size)
+}
+
+func (s *FallbackStorage) Exists(ctx context.Context, key string) (bool, error) {
+	exists, err := s.primary.Exists(ctx, key)
+	if err != nil || exists {
+		return exists, err
+	}
+	for _, fallback := range s.fallbacks {
+		exists, err = fallback.Exists(ctx, key)
+		if err != nil || exists {
+			return exists, err
+		}
+	}
+	return false, nil
+}
+
+func (s *FallbackStorage) Stat(ctx context.Context, key string) (EntryInfo, error) {
+	info, err := Stat(ctx, s.primary, key)
+	if !errors.Is(err, ErrNotFound) {
+		return info, err
+	}
+	for _, fallback := range s.fallbacks {
+		info, err = Stat(ctx, fallback, key)
+		if !errors.Is(err, ErrNotFound) {
+			return info, err
+		}
+	}
+	return EntryInfo{}, ErrNotFound
+}
+
+func (s *FallbackStorage) Delete(ctx context.Context, key string) error {
+	return s.primary.Delete(ctx, key)
+}
+
+func (s *FallbackStorage) Ping(ctx context.Context) error {
+	return s.primary.Ping(ctx)
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFallbackReadsInOrder(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{})
+	primary := backend.WithNamespace("exercise")
+	first := backend.WithNamespace("template")
+	second := backend.WithNamespace("shared")
+	s := NewFallbackStorage(primary, first, second)
+
+	put(t, second, "shared-only", []byte("second"))
+	put(t, second, "both", []byte("second"))
+	put(t, first, "both", []byte("first"))
+
+	if got, err := get(t, s, "both"); err != nil || string(got) != "first" {
+		t.Fatalf("Get = %q, %v; want the first fallback", got, err)
+	}
+	if got, err := get(t, s, "shared-only"); err != nil || string(got) != "second" {
+		t.Fatalf("Get = %q, %v; want the second fallback", got, err)
+	}
+	if !exists(t, s, "shared-only") {
+		t.Fatal("Exists misses entries of fallbacks")
+	}
+	if _, err := get(t, s, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of missing key = %v, want ErrNotFound", err)
+	}
+
+	put(t, s, "both", []byte("primary"))
+	if got, _ := get(t, s, "both"); string(got) != "primary" {
+		t.Fatalf("Get = %q, want the primary entry", got)
+	}
+}
+
+func TestFallbackWritesOnlyPrimary(t *testing.T) {
+	backend := newTestFilesystem(t, ExpiryPolicy{})
+	primary := backend.WithNamespace("exercise")
+	fallback := backend.WithNamespace("template")
+	s := NewFallbackStorage(primary, fallback)
+
+	put(t, fallback, "key", []byte("template"))
+	put(t, s, "new", []byte("x"))
+	if exists(t, fallback, "new") {
+		t.Fatal("Put wrote to a fallback")
+	}
+	if err := s.Delete(context.Background(), "key"); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(t, fallback, "key") {
+		t.Fatal("Delete removed an entry of a fallback")
+	}
+}
@@ -0,0 +1,345 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultNamespaceDir is the directory used for entries without a namespace.
+	defaultNamespaceDir = "_default"
+
+	// maxSweepInterval caps how long expired files that are never read
+	// again stay on disk.
+	maxSweepInterval = time.Hour
+
+	// keyFileSuffix names the file next to every entry that records its
+	// namespace and key, which the hashed file names do not reveal.
+	keyFileSuffix = ".key"
+)
+
+// keyFile is the content of an entry's key file.
+type keyFile struct {
+	Namespace string `json:"namespace,omitempty"`
+	Key       string `json:"key"`
+}
+
+// FilesystemStorage stores entries as files. Expiry is tracked through the
+// file modification time, which Put sets and sliding reads bump. Each entry
+// has a key file next to it, from which Scan recovers its namespace and key.
+type FilesystemStorage struct {
+	root      string
+	namespace string
+	expiry    ExpiryPolicy
+	// sweeper removes expired files in the background. Nil without a TTL.
+	sweeper *backgroundLoop
+}
+
+type FilesystemConfig struct {
+	Dir    string
+	Expiry ExpiryPolicy
+}
+
+func NewFilesystemStorage(cfg FilesystemConfig) (*FilesystemStorage, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("filesystem storage directory is required")
+	}
+	root, err := filepath.Abs(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storage directory: %w", err)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	storage := &FilesystemStorage{root: root, expiry: cfg.Expiry}
+
+	// Test that the directory is usable
+	if err := storage.Ping(context.Background()); err != nil {
+		return nil, err
+	}
+
+	if cfg.Expiry.TTL > 0 {
+		interval := min(cfg.Expiry.TTL, maxSweepInterval)
+		storage.sweeper = startLoop(func(ctx context.Context) {
+			storage.sweepLoop(ctx, interval)
+		})
+	}
+	return storage, nil
+}
+
+// path maps a key to its file location. Keys are hashed so that arbitrary
+// client input can never escape the storage root, and the first two bytes of
+// the hash shard entries across 65536 directories to keep listings small.
+func (s *FilesystemStorage) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(s.namespaceDir(), name[0:2], name[2:4], name)
+}
+
+func (s *FilesystemStorage) namespaceDir() string {
+	if s.namespace == "" {
+		return filepath.Join(s.root, defaultNamespaceDir)
+	}
+	sum := sha256.Sum256([]byte(s.namespace))
+	return filepath.Join(s.root, "ns-"+hex.EncodeToString(sum[:8]))
+}
+
+func (s *FilesystemStorage) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	f, err := os.Open(s.path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, 0, ErrNotFound
+		}
+		return nil, 0, fmt.Errorf("failed to open cache file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, fmt.Errorf("failed to stat cache file: %w", err)
+	}
+	if !s.touch(ctx, f.Name(), info) {
+		f.Close()
+		return nil, 0, ErrNotFound
+	}
+	return f, info.Size(), nil
+}
+
+func (s *FilesystemStorage) Put(ctx context.Context, key string, reader io.Reader, size int64) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create shard directory: %w", err)
+	}
+	// The sidecar is written first, so every entry Scan finds has one.
+	if _, err := os.Stat(path + keyFileSuffix); errors.Is(err, os.ErrNotExist) {
+		data, err := json.Marshal(keyFile{Namespace: s.namespace, Key: key})
+		if err != nil {
+			return fmt.Errorf("failed to encode key file: %w", err)
+		}
+		if err := writeFile(path+keyFileSuffix, bytes.NewReader(data), int64(len(data))); err != nil {
+			return err
+		}
+	}
+	return writeFile(path, &contextReader{ctx: ctx, r: reader}, size)
+}
+
+// writeFile writes a temp file in the directory of path and renames it into
+// place, so readers never observe a partially written file.
+func writeFile(path string, reader io.Reader, size int64) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpName := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	written, err := io.Copy(tmp, reader)
+	if err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("short write: expected %d bytes, got %d", size, written)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close cache file: %w", err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("failed to commit cache file: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+func (s *FilesystemStorage) Exists(ctx context.Context, key string) (bool, error) {
+	path := s.path(key)
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to stat cache file: %w", err)
+	}
+	return s.touch(ctx, path, info), nil
+}
+
+func (s *FilesystemStorage) Stat(ctx context.Context, key string) (EntryInfo, error) {
+	path := s.path(key)
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return EntryInfo{}, ErrNotFound
+		}
+		return EntryInfo{}, fmt.Errorf("failed to stat cache file: %w", err)
+	}
+	if !s.touch(ctx, path, info) {
+		return EntryInfo{}, ErrNotFound
+	}
+	return EntryInfo{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// touch applies the expiry policy to a file that was found. It removes the
+// file and returns false if it has expired, and bumps its modification time
+// under a sliding policy.
+func (s *FilesystemStorage) touch(ctx context.Context, path string, info os.FileInfo) bool {
+	if s.expiry.expired(info.ModTime()) {
+		removeEntry(path)
+		return false
+	}
+	if s.expiry.slides(ctx) {
+		now := time.Now()
+		os.Chtimes(path, now, now)
+	}
+	return true
+}
+
+func (s *FilesystemStorage) Delete(ctx context.Context, key string) error {
+	if err := removeEntry(s.path(key)); err != nil {
+		return fmt.Errorf("failed to delete cache file: %w", err)
+	}
+	return nil
+}
+
+// removeEntry removes the file of an entry and its key file.
+func removeEntry(path string) error {
+	err := os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	os.Remove(path + keyFileSuffix)
+	return nil
+}
+
+func (s *FilesystemStorage) Ping(ctx context.Context) error {
+	info, err := os.Stat(s.root)
+	if err != nil {
+		return fmt.Errorf("storage directory unavailable: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("storage path %s is not a directory", s.root)
+	}
+	return nil
+}
+
+func (s *FilesystemStorage) WithNamespace(namespace string) Storage {
+	return &FilesystemStorage{
+		root:      s.root,
+		namespace: namespace,
+		expiry:    s.expiry,
+		sweeper:   s.sweeper,
+	}
+}
+
+// Close stops removing expired files in the background. Entries stay
+// readable, and expired ones are still removed when they are found.
+func (s *FilesystemStorage) Close() error {
+	s.sweeper.stop()
+	return nil
+}
+
+// sweepLoop periodically removes expired files across all namespaces.
+func (s *FilesystemStorage) sweepLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sweep()
+		}
+	}
+}
+
+func (s *FilesystemStorage) sweep() {
+	filepath.WalkDir(s.root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		name := d.Name()
+		switch {
+		case strings.HasPrefix(name, ".tmp-"):
+			// Leftover temp files from interrupted uploads.
+			if time.Since(info.ModTime()) > maxSweepInterval {
+				os.Remove(path)
+			}
+		case strings.HasSuffix(name, keyFileSuffix):
+			// Key files are kept as long as their entry, and are only
+			// written shortly before it.
+			if _, err := os.Stat(strings.TrimSuffix(path, keyFileSuffix)); errors.Is(err, os.ErrNotExist) && time.Since(info.ModTime()) > maxSweepInterval {
+				os.Remove(path)
+			}
+		case s.expiry.expired(info.ModTime()):
+			removeEntry(path)
+		}
+		return nil
+	})
+}
+
+// Scan lists entries through their key files. Entries written before key
+// files were introduced have none and are skipped.
+func (s *FilesystemStorage) Scan(ctx context.Context, fn func(ScannedEntry) error) error {
+	return filepath.WalkDir(s.root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// Removed while the scan ran.
+				return nil
+			}
+			return fmt.Errorf("failed to list cache files: %w", err)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") || strings.HasSuffix(d.Name(), keyFileSuffix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || s.expiry.expired(info.ModTime()) {
+			return nil
+		}
+		data, err := os.ReadFile(path + keyFileSuffix)
+		if err != nil {
+			return nil
+		}
+		var kf keyFile
+		if err := json.Unmarshal(data, &kf); err != nil || internalNamespace(kf.Namespace) {
+			return nil
+		}
+		return fn(ScannedEntry{Namespace: kf.Namespace, Key: kf.Key, Size: info.Size()})
+	})
+}
+
+// contextReader aborts a copy once the request context is cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestFilesystem(t *testing.T, expiry ExpiryPolicy) *FilesystemStorage {
+	t.Helper()
+	s, err := NewFilesystemStorage(FilesystemConfig{Dir: t.TempDir(), Expiry: expiry})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// put stores data under key and fails the test on error.
+func put(t *testing.T, s Storage, key string, data []byte) {
+	t.Helper()
+	if err := s.Put(context.Background(), key, bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("Put(%q): %v", key, err)
+	}
+}
+
+// get reads key fully and checks the reported size.
+func get(t *testing.T, s Storage, key string) ([]byte, error) {
+	t.Helper()
+	r, size, err := s.Get(context.Background(), key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if size != int64(len(data)) {
+		t.Fatalf("Get(%q) reported size %d, read %d bytes", key, size, len(data))
+	}
+	return data, nil
+}
+
+func TestFilesystemRoundTrip(t *testing.T) {
+	s := newTestFilesystem(t, ExpiryPolicy{})
+	ctx := context.Background()
+
+	if _, err := get(t, s, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of missing key = %v, want ErrNotFound", err)
+	}
+
+	data := []byte("hello cache")
+	put(t, s, "key", data)
+	got, err := get(t, s, "key")
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Get = %q, %v; want %q", got, err, data)
+	}
+	if ok, err := s.Exists(ctx, "key"); !ok || err != nil {
+		t.Fatalf("Exists = %v, %v; want true", ok, err)
+	}
+
+	if err := s.Delete(ctx, "key"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if ok, _ := s.Exists(ctx, "key"); ok {
+		t.Fatal("entry exists after Delete")
+	}
+	if err := s.Delete(ctx, "key"); err != nil {
+		t.Fatalf("Delete of missing key: %v", err)
+	}
+}
+
+func TestFilesystemUnknownSize(t *testing.T) {
+	s := newTestFilesystem(t, ExpiryPolicy{})
+	if err := s.Put(context.Background(), "key", strings.NewReader("streamed"), -1); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got, err := get(t, s, "key"); err != nil || string(got) != "streamed" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+}
+
+func TestFilesystemShortWrite(t *testing.T) {
+	s := newTestFilesystem(t, ExpiryPolicy{})
+	if err := s.Put(context.Background(), "key", strings.NewReader("abc"), 10); err == nil {
+		t.Fatal("Put with short body succeeded")
+	}
+	if ok, _ := s.Exists(context.Background(), "key"); ok {
+		t.Fatal("short write left an entry behind")
+	}
+}
+
+func TestFilesystemNamespaces(t *testing.T) {
+	s := newTestFilesystem(t, ExpiryPolicy{})
+	a := s.WithNamespace("a")
+	b := s.WithNamespace("b")
+
+	put(t, a, "key", []byte("from a"))
+	if _, err := get(t, b, "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("namespace b sees entry of a: %v", err)
+	}
+	if _, err := get(t, s, "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("default namespace sees entry of a: %v", err)
+	}
+	if got, err := get(t, s.WithNamespace("a"), "key"); err != nil || string(got) != "from a" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+}
+
+func TestFilesystemKeysStayInRoot(t *testing.T) {
+	s := newTestFilesystem(t, ExpiryPolicy{})
+	put(t, s, "../../escape", []byte("x"))
+
+	err := filepath.WalkDir(filepath.Dir(s.root), func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && !strings.HasPrefix(path, s.root) {
+			t.Errorf("file %s written outside the storage root", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFilesystemExpiry(t *testing.T) {
+	s := newTestFilesystem(t, ExpiryPolicy{TTL: time.Hour})
+	put(t, s, "key", []byte("old"))
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(s.path("key"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.Exists(context.Background(), "key"); ok {
+		t.Fatal("expired entry exists")
+	}
+	if _, err := os.Stat(s.path("key")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expired file was not removed: %v", err)
+	}
+}
+
+func TestFilesystemSlidingExpiry(t *testing.T) {
+	s := newTestFilesystem(t, ExpiryPolicy{TTL: time.Hour, Sliding: true})
+	put(t, s, "key", []byte("data"))
+
+	old := time.Now().Add(-30 * time.Minute)
+	if err := os.Chtimes(s.path("key"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(t, s, "key"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	info, err := os.Stat(s.path("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(info.ModTime()) > time.Minute {
+		t.Fatal("sliding read did not refresh the entry")
+	}
+}
+
+func TestFilesystemSweep(t *testing.T) {
+	s := newTestFilesystem(t, ExpiryPolicy{TTL: time.Hour})
+	put(t, s, "expired", []byte("x"))
+	put(t, s, "fresh", []byte("y"))
+
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(s.path("expired"), old, old)
+	s.sweep()
+
+	if _, err := os.Stat(s.path("expired")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("sweep kept an expired file")
+	}
+	if _, err := os.Stat(s.path("fresh")); err != nil {
+		t.Fatalf("sweep removed a fresh file: %v", err)
+	}
+}
+
+func TestFilesystemClose(t *testing.T) {
+	s := newTestFilesystem(t, ExpiryPolicy{TTL: time.Hour})
+	put(t, s, "key", []byte("data"))
+
+	done := make(chan struct{})
+	go func() {
+		s.WithNamespace("team").(*FilesystemStorage).Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop the sweep loop")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+	if data, err := get(t, s, "key"); err != nil || string(data) != "data" {
+		t.Fatalf("Get after Close = %q, %v", data, err)
+	}
+}
+
+func TestFilesystemScan(t *testing.T) {
+	s := newTestFilesystem(t, ExpiryPolicy{TTL: time.Hour})
+	put(t, s, "plain", []byte("1234"))
+	put(t, s.WithNamespace("team"), "a/b", []byte("12"))
+	put(t, s.WithNamespace(dedupBlobNamespace), "blob", []byte("internal"))
+	put(t, s, "expired", []byte("x"))
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(s.path("expired"), old, old)
+
+	// Entries written before key files existed cannot be listed.
+	put(t, s, "legacy", []byte("x"))
+	os.Remove(s.path("legacy") + keyFileSuffix)
+
+	found := map[ScannedEntry]bool{}
+	err := s.Scan(context.Background(), func(e ScannedEntry) error {
+		found[e] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	want := map[ScannedEntry]bool{
+		{Key: "plain", Size: 4}:                  true,
+		{Namespace: "team", Key: "a/b", Size: 2}: true,
+	}
+	if len(found) != len(want) {
+		t.Fatalf("Scan found %v, want %v", found, want)
+	}
+	for e := range want {
+		if !found[e] {
+			t.Fatalf("Scan found %v, want %v", found, want)
+		}
+	}
+
+	// Key files go with their entries.
+	if err := s.Delete(context.Background(), "plain"); err != nil {
+		t.Fatal(err)
+	}
+	s.sweep()
+	for _, key := range []string{"plain", "expired"} {
+		if _, err := os.Stat(s.path(key) + keyFileSuffix); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("key file of %s was kept: %v", key, err)
+		}
+	}
+}
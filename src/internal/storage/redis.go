@@ -3,20 +3,72 @@ package storage
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	// DefaultRedisChunkSize is used when RedisConfig.ChunkSize is not set.
+	DefaultRedisChunkSize = 1024 * 1024
+
+	// chunksPerPipeline is the number of chunks queued before a pipeline is
+	// flushed. Peak memory per upload is chunksPerPipeline * chunk size.
+	chunksPerPipeline = 4
+
+	// staleChunkTTL keeps the chunks of a replaced entry around briefly so
+	// that readers still streaming the old generation can finish.
+	staleChunkTTL = time.Minute
+
+	// redisScanBatch is the number of keys Scan asks for and sizes at once.
+	redisScanBatch = 1000
+
+	// redisScanPeek is how much of a value Scan reads to find manifests.
+	redisScanPeek = 1024
+)
+
+// redisChunkKey matches the keys chunkKey generates.
+var redisChunkKey = regexp.MustCompile(`:chunk:[0-9a-f]{16}:[0-9]+$`)
+
+// manifestMagic prefixes values that describe a chunked entry rather than
+// holding the entry data itself.
+var manifestMagic = []byte("\x00gradle-cache:chunked:v1\x00")
+
+// chunkManifest is stored under the entry key of a chunked entry. The chunks
+// themselves live under "<key>:chunk:<generation>:<index>".
+type chunkManifest struct {
+	Generation string `json:"gen"`
+	Size       int64  `json:"size"`
+	ChunkSize  int64  `json:"chunk_size"`
+	Chunks     int    `json:"chunks"`
+}
+
 type RedisStorage struct {
 	client    *redis.Client
 	namespace string
+	chunkSize int64
+	expiry    ExpiryPolicy
+	// buffers recycles chunk-sized upload buffers across requests.
+	buffers *sync.Pool
 }
 
 type RedisConfig struct {
 	Addr     string
 	Password string
+	// ChunkSize is the maximum size of a single Redis value. Larger entries
+	// are split into chunks plus a manifest.
+	ChunkSize int64
+	Expiry    ExpiryPolicy
 }
 
 func NewRedisStorage(cfg RedisConfig) (*RedisStorage, error) {
@@ -25,7 +77,19 @@ func NewRedisStorage(cfg RedisConfig) (*RedisStorage, error) {
 		Password: cfg.Password,
 		DB:       0,
 	})
-	storage := &RedisStorage{client: client}
+	chunkSize := cfg.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultRedisChunkSize
+	}
+	storage := &RedisStorage{
+		client:    client,
+		chunkSize: chunkSize,
+		expiry:    cfg.Expiry,
+		buffers: &sync.Pool{New: func() any {
+			buf := make([]byte, chunkSize)
+			return &buf
+		}},
+	}
 
 	// Test connection
 	if err := storage.Ping(context.Background()); err != nil {
@@ -41,45 +105,433 @@ func (s *RedisStorage) redisKey(key string) string {
 	return s.namespace + ":" + key
 }
 
+// chunkTTL outlives the manifest TTL so a manifest never points at chunks
+// that expired before it.
+func (s *RedisStorage) chunkTTL() time.Duration {
+	if s.expiry.TTL <= 0 {
+		return 0
+	}
+	return s.expiry.TTL + staleChunkTTL
+}
+
+func chunkKey(redisKey, generation string, index int) string {
+	return redisKey + ":chunk:" + generation + ":" + strconv.Itoa(index)
+}
+
 func (s *RedisStorage) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
-	data, err := s.client.Get(ctx, s.redisKey(key)).Bytes()
+	rk := s.redisKey(key)
+	var cmd *redis.StringCmd
+	if s.expiry.slides(ctx) {
+		cmd = s.client.GetEx(ctx, rk, s.expiry.TTL)
+	} else {
+		cmd = s.client.Get(ctx, rk)
+	}
+	data, err := cmd.Bytes()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, 0, ErrNotFound
 		}
 		return nil, 0, fmt.Errorf("failed to get key from Redis: %w", err)
 	}
-	return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+
+	manifest, ok, err := parseManifest(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !ok {
+		return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+	}
+	if s.expiry.slides(ctx) {
+		s.refreshChunks(ctx, rk, manifest)
+	}
+	return &chunkReader{
+		ctx:      ctx,
+		client:   s.client,
+		redisKey: rk,
+		manifest: manifest,
+	}, manifest.Size, nil
 }
 
+// Put stores entries up to the chunk size as a single value. Larger entries
+// are streamed into chunk keys and published by swapping in the manifest.
 func (s *RedisStorage) Put(ctx context.Context, key string, reader io.Reader, size int64) error {
-	data, err := io.ReadAll(reader)
+	if size >= 0 && size < s.chunkSize {
+		// The entry is known to fit a single value, so a buffer of its
+		// size is enough.
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return fmt.Errorf("failed to read data: %w", err)
+		}
+		// Values that look like a manifest are chunked so they can never be
+		// mistaken for one.
+		if !bytes.HasPrefix(buf, manifestMagic) {
+			return s.swap(ctx, key, buf)
+		}
+		return s.putChunked(ctx, key, buf, reader)
+	}
 
-	if err != nil {
+	buf := s.buffers.Get().(*[]byte)
+	defer s.buffers.Put(buf)
+	n, err := io.ReadFull(reader, *buf)
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		if !bytes.HasPrefix((*buf)[:n], manifestMagic) {
+			return s.swap(ctx, key, (*buf)[:n])
+		}
+	case err != nil:
 		return fmt.Errorf("failed to read data: %w", err)
 	}
-	return s.client.Set(ctx, s.redisKey(key), data, 0).Err()
+	return s.putChunked(ctx, key, (*buf)[:n], reader)
 }
 
-func (s *RedisStorage) Exists(ctx context.Context, key string) (bool, error) {
-	n, err := s.client.Exists(ctx, s.redisKey(key)).Result()
+func (s *RedisStorage) putChunked(ctx context.Context, key string, first []byte, reader io.Reader) error {
+	rk := s.redisKey(key)
+	generation, err := newGeneration()
 	if err != nil {
+		return err
+	}
+	manifest := chunkManifest{Generation: generation, ChunkSize: s.chunkSize}
+
+	// go-redis keeps a reference to queued values, so every chunk needs its
+	// own buffer until the pipeline is flushed. The buffers go back to the
+	// pool once it has been.
+	var queuedBuffers []*[]byte
+	recycle := func() {
+		for _, buf := range queuedBuffers {
+			s.buffers.Put(buf)
+		}
+		queuedBuffers = queuedBuffers[:0]
+	}
+	defer recycle()
+
+	pipe := s.client.Pipeline()
+	queued := 0
+	chunk := first
+	for {
+		pipe.Set(ctx, chunkKey(rk, generation, manifest.Chunks), chunk, s.chunkTTL())
+		manifest.Chunks++
+		manifest.Size += int64(len(chunk))
+		queued++
+
+		if queued == chunksPerPipeline {
+			_, err := pipe.Exec(ctx)
+			recycle()
+			if err != nil {
+				s.deleteChunks(rk, manifest)
+				return fmt.Errorf("failed to write chunks to Redis: %w", err)
+			}
+			queued = 0
+		}
+
+		next := s.buffers.Get().(*[]byte)
+		n, err := io.ReadFull(reader, *next)
+		if err == io.EOF {
+			s.buffers.Put(next)
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			s.buffers.Put(next)
+			pipe.Discard()
+			s.deleteChunks(rk, manifest)
+			return fmt.Errorf("failed to read data: %w", err)
+		}
+		queuedBuffers = append(queuedBuffers, next)
+		chunk = (*next)[:n]
+	}
+	if queued > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			s.deleteChunks(rk, manifest)
+			return fmt.Errorf("failed to write chunks to Redis: %w", err)
+		}
+	}
+
+	value, err := json.Marshal(manifest)
+	if err != nil {
+		s.deleteChunks(rk, manifest)
+		return fmt.Errorf("failed to encode chunk manifest: %w", err)
+	}
+	if err := s.swap(ctx, key, append(append([]byte{}, manifestMagic...), value...)); err != nil {
+		s.deleteChunks(rk, manifest)
+		return err
+	}
+	return nil
+}
+
+// swap replaces the value under key and retires the chunks of the entry it
+// replaced, if that entry was chunked.
+func (s *RedisStorage) swap(ctx context.Context, key string, value []byte) error {
+	rk := s.redisKey(key)
+	old, err := s.client.SetArgs(ctx, rk, value, redis.SetArgs{Get: true, TTL: s.expiry.TTL}).Bytes()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to set key in Redis: %w", err)
+	}
+	if manifest, ok, _ := parseManifest(old); ok {
+		s.expireChunks(ctx, rk, manifest)
+	}
+	return nil
+}
+
+func (s *RedisStorage) Exists(ctx context.Context, key string) (bool, error) {
+	rk := s.redisKey(key)
+	if !s.expiry.slides(ctx) {
+		n, err := s.client.Exists(ctx, rk).Result()
+		if err != nil {
+			return false, fmt.Errorf("failed to check key existence in Redis: %w", err)
+		}
+		return n > 0, nil
+	}
+
+	// EXPIRE doubles as the existence check. The value prefix tells whether
+	// the chunks of a chunked entry need refreshing as well.
+	pipe := s.client.Pipeline()
+	expire := pipe.Expire(ctx, rk, s.expiry.TTL)
+	prefix := pipe.GetRange(ctx, rk, 0, int64(len(manifestMagic)-1))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
 		return false, fmt.Errorf("failed to check key existence in Redis: %w", err)
 	}
-	return n > 0, nil
+	if !expire.Val() {
+		return false, nil
+	}
+	if prefix.Val() == string(manifestMagic) {
+		data, err := s.client.Get(ctx, rk).Bytes()
+		if err == nil {
+			if manifest, ok, _ := parseManifest(data); ok {
+				s.refreshChunks(ctx, rk, manifest)
+			}
+		}
+	}
+	return true, nil
+}
+
+// Stat reads the length and prefix of the value, and the value itself only
+// for chunked entries, whose size is in the manifest. Redis does not record
+// when a key was set, so ModTime is left unknown.
+func (s *RedisStorage) Stat(ctx context.Context, key string) (EntryInfo, error) {
+	rk := s.redisKey(key)
+	pipe := s.client.Pipeline()
+	var found func() bool
+	if s.expiry.slides(ctx) {
+		found = pipe.Expire(ctx, rk, s.expiry.TTL).Val
+	} else {
+		exists := pipe.Exists(ctx, rk)
+		found = func() bool { return exists.Val() > 0 }
+	}
+	length := pipe.StrLen(ctx, rk)
+	prefix := pipe.GetRange(ctx, rk, 0, int64(len(manifestMagic)-1))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return EntryInfo{}, fmt.Errorf("failed to stat key in Redis: %w", err)
+	}
+	if !found() {
+		return EntryInfo{}, ErrNotFound
+	}
+	if prefix.Val() != string(manifestMagic) {
+		return EntryInfo{Size: length.Val()}, nil
+	}
+
+	data, err := s.client.Get(ctx, rk).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return EntryInfo{}, ErrNotFound
+		}
+		return EntryInfo{}, fmt.Errorf("failed to get key from Redis: %w", err)
+	}
+	manifest, ok, err := parseManifest(data)
+	if err != nil {
+		return EntryInfo{}, err
+	}
+	if !ok {
+		// Replaced by a single value since the pipeline ran.
+		return EntryInfo{Size: int64(len(data))}, nil
+	}
+	if s.expiry.slides(ctx) {
+		s.refreshChunks(ctx, rk, manifest)
+	}
+	return EntryInfo{Size: manifest.Size}, nil
 }
 
 func (s *RedisStorage) Delete(ctx context.Context, key string) error {
-	return s.client.Del(ctx, s.redisKey(key)).Err()
+	rk := s.redisKey(key)
+	old, err := s.client.GetDel(ctx, rk).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil
+		}
+		return err
+	}
+	if manifest, ok, _ := parseManifest(old); ok {
+		s.expireChunks(ctx, rk, manifest)
+	}
+	return nil
 }
 
 func (s *RedisStorage) Ping(ctx context.Context) error {
 	return s.client.Ping(ctx).Err()
 }
 
+// Scan lists the entries of all namespaces. Chunked entries are reported
+// once, with the size recorded in their manifest. Keys are split into
+// namespace and key at the first colon, so default namespace keys containing
+// one are attributed to a namespace; they still name the same Redis key.
+func (s *RedisStorage) Scan(ctx context.Context, fn func(ScannedEntry) error) error {
+	var batch []string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		pipe := s.client.Pipeline()
+		lengths := make([]*redis.IntCmd, len(batch))
+		prefixes := make([]*redis.StringCmd, len(batch))
+		for i, rk := range batch {
+			lengths[i] = pipe.StrLen(ctx, rk)
+			prefixes[i] = pipe.GetRange(ctx, rk, 0, redisScanPeek-1)
+		}
+		// Keys may expire or change type between SCAN and the pipeline;
+		// their commands fail individually and they are skipped.
+		pipe.Exec(ctx)
+		for i, rk := range batch {
+			if lengths[i].Err() != nil || prefixes[i].Err() != nil {
+				continue
+			}
+			entry := ScannedEntry{Key: rk, Size: lengths[i].Val()}
+			if manifest, ok, _ := parseManifest([]byte(prefixes[i].Val())); ok {
+				entry.Size = manifest.Size
+			}
+			if ns, key, ok := strings.Cut(rk, ":"); ok && validRedisNamespace(ns) {
+				entry.Namespace, entry.Key = ns, key
+			}
+			if internalNamespace(entry.Namespace) {
+				continue
+			}
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	iter := s.client.Scan(ctx, 0, "*", redisScanBatch).Iterator()
+	for iter.Next(ctx) {
+		if rk := iter.Val(); !redisChunkKey.MatchString(rk) {
+			batch = append(batch, rk)
+		}
+		if len(batch) == redisScanBatch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to scan Redis keys: %w", err)
+	}
+	return flush()
+}
+
+// validRedisNamespace reports whether ns can be the namespace part of a key.
+func validRedisNamespace(ns string) bool {
+	if ns == "" {
+		return false
+	}
+	for _, r := range ns {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("._-", r)) {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *RedisStorage) WithNamespace(namespace string) Storage {
 	return &RedisStorage{
 		client:    s.client,
 		namespace: namespace,
+		chunkSize: s.chunkSize,
+		expiry:    s.expiry,
+		buffers:   s.buffers,
+	}
+}
+
+// refreshChunks extends the chunk lifetimes of a manifest that was refreshed.
+func (s *RedisStorage) refreshChunks(ctx context.Context, rk string, manifest chunkManifest) {
+	pipe := s.client.Pipeline()
+	for i := 0; i < manifest.Chunks; i++ {
+		pipe.Expire(ctx, chunkKey(rk, manifest.Generation, i), s.chunkTTL())
+	}
+	pipe.Exec(ctx)
+}
+
+// expireChunks schedules the chunks of a retired manifest for removal.
+func (s *RedisStorage) expireChunks(ctx context.Context, rk string, manifest chunkManifest) {
+	pipe := s.client.Pipeline()
+	for i := 0; i < manifest.Chunks; i++ {
+		pipe.Expire(ctx, chunkKey(rk, manifest.Generation, i), staleChunkTTL)
+	}
+	pipe.Exec(ctx)
+}
+
+// deleteChunks removes chunks of an upload that was never published. It uses
+// a fresh context because the request context may already be cancelled.
+func (s *RedisStorage) deleteChunks(rk string, manifest chunkManifest) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pipe := s.client.Pipeline()
+	for i := 0; i < manifest.Chunks; i++ {
+		pipe.Del(ctx, chunkKey(rk, manifest.Generation, i))
+	}
+	pipe.Exec(ctx)
+}
+
+func parseManifest(data []byte) (chunkManifest, bool, error) {
+	var manifest chunkManifest
+	if !bytes.HasPrefix(data, manifestMagic) {
+		return manifest, false, nil
+	}
+	if err := json.Unmarshal(data[len(manifestMagic):], &manifest); err != nil {
+		return manifest, false, fmt.Errorf("failed to decode chunk manifest: %w", err)
+	}
+	return manifest, true, nil
+}
+
+func newGeneration() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate chunk generation: %w", err)
 	}
+	return hex.EncodeToString(b), nil
+}
+
+// chunkReader streams a chunked entry, fetching one chunk at a time.
+type chunkReader struct {
+	ctx      context.Context
+	client   *redis.Client
+	redisKey string
+	manifest chunkManifest
+	next     int
+	buf      []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.next >= r.manifest.Chunks {
+			return 0, io.EOF
+		}
+		data, err := r.client.Get(r.ctx, chunkKey(r.redisKey, r.manifest.Generation, r.next)).Bytes()
+		if err != nil {
+			if err == redis.Nil {
+				return 0, errors.New("chunk missing from Redis, entry was replaced or evicted")
+			}
+			return 0, fmt.Errorf("failed to get chunk from Redis: %w", err)
+		}
+		r.buf = data
+		r.next++
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *chunkReader) Close() error {
+	r.buf = nil
+	r.next = r.manifest.Chunks
+	return nil
 }
@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedis(t *testing.T, chunkSize int64, expiry ExpiryPolicy) (*RedisStorage, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	s, err := NewRedisStorage(RedisConfig{Addr: mr.Addr(), ChunkSize: chunkSize, Expiry: expiry})
+	if err != nil {
+		t.Fatalf("NewRedisStorage: %v", err)
+	}
+	t.Cleanup(func() { s.client.Close() })
+	return s, mr
+}
+
+// chunkKeys returns the chunk keys stored for rk.
+func chunkKeys(mr *miniredis.Miniredis, rk string) []string {
+	var keys []string
+	for _, k := range mr.Keys() {
+		if strings.HasPrefix(k, rk+":chunk:") {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func TestRedisSingleValue(t *testing.T) {
+	s, mr := newTestRedis(t, 64, ExpiryPolicy{})
+	ctx := context.Background()
+
+	if _, err := get(t, s, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of missing key = %v, want ErrNotFound", err)
+	}
+
+	put(t, s, "key", []byte("small"))
+	if v, _ := mr.Get("key"); v != "small" {
+		t.Fatalf("stored value = %q, want the entry itself", v)
+	}
+	if got, err := get(t, s, "key"); err != nil || string(got) != "small" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+	if ok, err := s.Exists(ctx, "key"); !ok || err != nil {
+		t.Fatalf("Exists = %v, %v", ok, err)
+	}
+	if err := s.Delete(ctx, "key"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if ok, _ := s.Exists(ctx, "key"); ok {
+		t.Fatal("entry exists after Delete")
+	}
+}
+
+func TestRedisChunked(t *testing.T) {
+	s, mr := newTestRedis(t, 16, ExpiryPolicy{})
+	data := bytes.Repeat([]byte("0123456789"), 20)
+
+	put(t, s, "key", data)
+	if n := len(chunkKeys(mr, "key")); n != 13 {
+		t.Fatalf("stored %d chunks, want 13", n)
+	}
+	if got, err := get(t, s, "key"); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+
+	// Replacing the entry retires the old chunks instead of leaking them.
+	put(t, s, "key", []byte("short"))
+	for _, k := range chunkKeys(mr, "key") {
+		if mr.TTL(k) <= 0 || mr.TTL(k) > staleChunkTTL {
+			t.Fatalf("old chunk %s has TTL %v, want at most %v", k, mr.TTL(k), staleChunkTTL)
+		}
+	}
+	if got, err := get(t, s, "key"); err != nil || string(got) != "short" {
+		t.Fatalf("Get after replace = %q, %v", got, err)
+	}
+}
+
+func TestRedisStat(t *testing.T) {
+	s, _ := newTestRedis(t, 16, ExpiryPolicy{})
+	ctx := context.Background()
+	put(t, s, "small", []byte("small"))
+	put(t, s, "chunked", bytes.Repeat([]byte("0123456789"), 20))
+
+	for key, want := range map[string]int64{"small": 5, "chunked": 200} {
+		if info, err := s.Stat(ctx, key); err != nil || info.Size != want {
+			t.Fatalf("Stat(%s) = %+v, %v, want size %d", key, info, err, want)
+		}
+	}
+	if _, err := s.Stat(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Stat of missing key = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRedisUnknownSize(t *testing.T) {
+	s, _ := newTestRedis(t, 16, ExpiryPolicy{})
+	ctx := context.Background()
+
+	for _, data := range []string{"", "fits", strings.Repeat("x", 100)} {
+		if err := s.Put(ctx, "key", strings.NewReader(data), -1); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if got, err := get(t, s, "key"); err != nil || string(got) != data {
+			t.Fatalf("Get = %q, %v; want %q", got, err, data)
+		}
+	}
+}
+
+func TestRedisManifestLookalike(t *testing.T) {
+	s, mr := newTestRedis(t, 1024, ExpiryPolicy{})
+	data := append(append([]byte{}, manifestMagic...), `{"gen":"x","chunks":99}`...)
+
+	put(t, s, "key", data)
+	if v, _ := mr.Get("key"); v == string(data) {
+		t.Fatal("value that looks like a manifest was stored verbatim")
+	}
+	if got, err := get(t, s, "key"); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+}
+
+func TestRedisNamespaces(t *testing.T) {
+	s, mr := newTestRedis(t, 64, ExpiryPolicy{})
+	put(t, s.WithNamespace("a"), "key", []byte("from a"))
+
+	if !mr.Exists("a:key") {
+		t.Fatalf("namespaced entry not stored under a:key, have %v", mr.Keys())
+	}
+	if _, err := get(t, s.WithNamespace("b"), "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("namespace b sees entry of a: %v", err)
+	}
+	if _, err := get(t, s, "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("default namespace sees entry of a: %v", err)
+	}
+}
+
+func TestRedisSlidingExpiry(t *testing.T) {
+	s, mr := newTestRedis(t, 16, ExpiryPolicy{TTL: time.Hour, Sliding: true})
+	put(t, s, "key", bytes.Repeat([]byte("x"), 40))
+
+	mr.FastForward(45 * time.Minute)
+	if ok, err := s.Exists(context.Background(), "key"); !ok || err != nil {
+		t.Fatalf("Exists = %v, %v", ok, err)
+	}
+	if ttl := mr.TTL("key"); ttl != time.Hour {
+		t.Fatalf("manifest TTL = %v after a hit, want %v", ttl, time.Hour)
+	}
+	for _, k := range chunkKeys(mr, "key") {
+		if mr.TTL(k) < time.Hour {
+			t.Fatalf("chunk %s was not refreshed, TTL %v", k, mr.TTL(k))
+		}
+	}
+
+	mr.FastForward(2 * time.Hour)
+	if _, err := get(t, s, "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of expired entry = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRedisScan(t *testing.T) {
+	s, _ := newTestRedis(t, 16, ExpiryPolicy{})
+	put(t, s, "plain", []byte("1234"))
+	put(t, s, "chunked", bytes.Repeat([]byte("x"), 40))
+	put(t, s.WithNamespace("team"), "key", []byte("12"))
+	put(t, s.WithNamespace(dedupBlobNamespace), "blob", []byte("internal"))
+
+	found := map[ScannedEntry]bool{}
+	err := s.Scan(context.Background(), func(e ScannedEntry) error {
+		found[e] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	want := map[ScannedEntry]bool{
+		{Key: "plain", Size: 4}:                  true,
+		{Key: "chunked", Size: 40}:               true,
+		{Namespace: "team", Key: "key", Size: 2}: true,
+	}
+	if len(found) != len(want) {
+		t.Fatalf("Scan found %v, want %v", found, want)
+	}
+	for e := range want {
+		if !found[e] {
+			t.Fatalf("Scan found %v, want %v", found, want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/rs/zerolog"
+)
+
+// s3PartSize is the multipart chunk size used for uploads. It bounds the
+// memory minio-go buffers per upload when the content length is unknown.
+const s3PartSize = 16 * 1024 * 1024
+
+// S3Storage stores entries as objects. Expiry is derived from the object's
+// LastModified time. Objects that are never read again are not removed by the
+// server, so buckets with a TTL should also carry a matching lifecycle rule.
+type S3Storage struct {
+	client    *minio.Client
+	bucket    string
+	prefix    string
+	namespace string
+	expiry    ExpiryPolicy
+	logger    zerolog.Logger
+}
+
+type S3Config struct {
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	// PathStyle forces path-style bucket addressing, which Ceph RGW and
+	// MinIO deployments without wildcard DNS require.
+	PathStyle bool
+	// Prefix is prepended to every object key.
+	Prefix string
+	Expiry ExpiryPolicy
+}
+
+func NewS3Storage(cfg S3Config, logger zerolog.Logger) (*S3Storage, error) {
+	lookup := minio.BucketLookupAuto
+	if cfg.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure:       cfg.UseSSL,
+		Region:       cfg.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	storage := &S3Storage{
+		client: client,
+		bucket: cfg.Bucket,
+		prefix: strings.Trim(cfg.Prefix, "/"),
+		expiry: cfg.Expiry,
+		logger: logger,
+	}
+
+	// Test connection
+	if err := storage.Ping(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to connect to S3: %w", err)
+	}
+	return storage, nil
+}
+
+func (s *S3Storage) objectKey(key string) string {
+	parts := make([]string, 0, 3)
+	if s.prefix != "" {
+		parts = append(parts, s.prefix)
+	}
+	if s.namespace != "" {
+		parts = append(parts, s.namespace)
+	}
+	parts = append(parts, key)
+	return strings.Join(parts, "/")
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, s.objectKey(key), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get object from S3: %w", err)
+	}
+
+	// GetObject is lazy; Stat issues the request and surfaces a missing key.
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		if isS3NotFound(err) {
+			return nil, 0, ErrNotFound
+		}
+		return nil, 0, fmt.Errorf("failed to get object from S3: %w", err)
+	}
+	if !s.touch(ctx, key, info.LastModified) {
+		obj.Close()
+		return nil, 0, ErrNotFound
+	}
+	return obj, info.Size, nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, reader io.Reader, size int64) error {
+	_, err := s.client.PutObject(ctx, s.bucket, s.objectKey(key), reader, size, minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+		PartSize:    s3PartSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put object to S3: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Storage) Exists(ctx context.Context, key string) (bool, error) {
+	info, err := s.client.StatObject(ctx, s.bucket, s.objectKey(key), minio.StatObjectOptions{})
+	if err != nil {
+		if isS3NotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check object existence in S3: %w", err)
+	}
+	return s.touch(ctx, key, info.LastModified), nil
+}
+
+func (s *S3Storage) Stat(ctx context.Context, key string) (EntryInfo, error) {
+	info, err := s.client.StatObject(ctx, s.bucket, s.objectKey(key), minio.StatObjectOptions{})
+	if err != nil {
+		if isS3NotFound(err) {
+			return EntryInfo{}, ErrNotFound
+		}
+		return EntryInfo{}, fmt.Errorf("failed to stat object in S3: %w", err)
+	}
+	if !s.touch(ctx, key, info.LastModified) {
+		return EntryInfo{}, ErrNotFound
+	}
+	return EntryInfo{Size: info.Size, ModTime: info.LastModified}, nil
+}
+
+// touch applies the expiry policy to an object that was found. It removes the
+// object and returns false if it has expired. Under a sliding policy the
+// object is copied onto itself to reset LastModified; that copy is skipped
+// while less than half of the TTL has passed to keep hot keys cheap.
+func (s *S3Storage) touch(ctx context.Context, key string, lastModified time.Time) bool {
+	if s.expiry.expired(lastModified) {
+		if err := s.Delete(ctx, key); err != nil {
+			s.logger.Warn().Err(err).Str("object", s.objectKey(key)).Msg("failed to delete expired S3 object")
+		}
+		return false
+	}
+	if s.expiry.slides(ctx) && time.Since(lastModified) > s.expiry.TTL/2 {
+		objectKey := s.objectKey(key)
+		_, err := s.client.CopyObject(ctx,
+			minio.CopyDestOptions{Bucket: s.bucket, Object: objectKey, ReplaceMetadata: true},
+			minio.CopySrcOptions{Bucket: s.bucket, Object: objectKey})
+		if err != nil {
+			// The object stays readable; it just expires on its old schedule.
+			s.logger.Warn().Err(err).Str("object", objectKey).Msg("failed to refresh S3 object")
+		}
+	}
+	return true
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	err := s.client.RemoveObject(ctx, s.bucket, s.objectKey(key), minio.RemoveObjectOptions{})
+	if err != nil && !isS3NotFound(err) {
+		return fmt.Errorf("failed to delete object from S3: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Storage) Ping(ctx context.Context) error {
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("bucket %q does not exist", s.bucket)
+	}
+	return nil
+}
+
+// Scan lists the objects under the prefix. The first path segment below
+// the prefix is taken as the namespace, so default namespace keys containing
+// a slash are attributed to a namespace; they still name the same object.
+func (s *S3Storage) Scan(ctx context.Context, fn func(ScannedEntry) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	prefix := ""
+	if s.prefix != "" {
+		prefix = s.prefix + "/"
+	}
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return fmt.Errorf("failed to list S3 objects: %w", obj.Err)
+		}
+		entry := ScannedEntry{Key: strings.TrimPrefix(obj.Key, prefix), Size: obj.Size}
+		if ns, key, ok := strings.Cut(entry.Key, "/"); ok {
+			entry.Namespace, entry.Key = ns, key
+		}
+		if internalNamespace(entry.Namespace) {
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *S3Storage) WithNamespace(namespace string) Storage {
+	return &S3Storage{
+		client:    s.client,
+		bucket:    s.bucket,
+		prefix:    s.prefix,
+		namespace: namespace,
+		expiry:    s.expiry,
+		logger:    s.logger,
+	}
+}
+
+func isS3NotFound(err error) bool {
+	resp := minio.ToErrorResponse(err)
+	return resp.Code == minio.NoSuchKey || resp.StatusCode == 404
+}
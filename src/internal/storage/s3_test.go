@@ -0,0 +1,391 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// fakeS3 is an in-process S3 endpoint with just enough of the API for
+// S3Storage: bucket HEAD, object GET, HEAD, PUT, copy and DELETE, and the
+// multipart uploads minio-go uses for bodies of unknown length.
+type fakeS3 struct {
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+	uploads map[string][][]byte
+}
+
+type fakeObject struct {
+	data    []byte
+	modTime time.Time
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, string) {
+	t.Helper()
+	f := &fakeS3{
+		bucket:  bucket,
+		objects: make(map[string]fakeObject),
+		uploads: make(map[string][][]byte),
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL)
+	return f, u.Host
+}
+
+func (f *fakeS3) object(key string) (fakeObject, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, ok := f.objects[key]
+	return obj, ok
+}
+
+func (f *fakeS3) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.objects))
+	for k := range f.objects {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (f *fakeS3) setModTime(key string, t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj := f.objects[key]
+	obj.modTime = t
+	f.objects[key] = obj
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		f.error(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	if key == "" {
+		switch {
+		case r.Method == http.MethodHead:
+		case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+			f.list(w, r.URL.Query().Get("prefix"))
+		default:
+			f.error(w, r, http.StatusNotImplemented, "NotImplemented")
+		}
+		return
+	}
+
+	if r.URL.Query().Has("uploads") || r.URL.Query().Has("uploadId") {
+		f.multipart(w, r, key)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		obj, ok := f.object(key)
+		if !ok {
+			f.error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		w.Header().Set("Last-Modified", obj.modTime.UTC().Format(http.TimeFormat))
+		w.Header().Set("ETag", `"etag"`)
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+	case http.MethodPut:
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			src, _ = url.PathUnescape(src)
+			_, srcKey, _ := strings.Cut(strings.TrimPrefix(src, "/"), "/")
+			obj, ok := f.object(srcKey)
+			if !ok {
+				f.error(w, r, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			f.store(key, obj.data)
+			fmt.Fprintf(w, `<CopyObjectResult><ETag>"etag"</ETag><LastModified>%s</LastModified></CopyObjectResult>`,
+				time.Now().UTC().Format(time.RFC3339))
+			return
+		}
+		data, err := readS3Body(r)
+		if err != nil {
+			f.error(w, r, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		f.store(key, data)
+		w.Header().Set("ETag", `"etag"`)
+	case http.MethodDelete:
+		f.mu.Lock()
+		delete(f.objects, key)
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.error(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// list answers a ListObjectsV2 request with all matching objects at once.
+func (f *fakeS3) list(w http.ResponseWriter, prefix string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fmt.Fprintf(w, `<ListBucketResult><Name>%s</Name><Prefix>%s</Prefix><IsTruncated>false</IsTruncated>`, f.bucket, prefix)
+	for key, obj := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			fmt.Fprintf(w, `<Contents><Key>%s</Key><Size>%d</Size><LastModified>%s</LastModified><ETag>"etag"</ETag></Contents>`,
+				key, len(obj.data), obj.modTime.UTC().Format(time.RFC3339))
+		}
+	}
+	fmt.Fprint(w, `</ListBucketResult>`)
+}
+
+func (f *fakeS3) multipart(w http.ResponseWriter, r *http.Request, key string) {
+	query := r.URL.Query()
+	id := query.Get("uploadId")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		id = strconv.Itoa(len(f.uploads) + 1)
+		f.uploads[id] = nil
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`,
+			f.bucket, key, id)
+	case r.Method == http.MethodPut:
+		part, _ := strconv.Atoi(query.Get("partNumber"))
+		data, err := readS3Body(r)
+		if err != nil || part < 1 {
+			f.error(w, r, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+		parts := f.uploads[id]
+		for len(parts) < part {
+			parts = append(parts, nil)
+		}
+		parts[part-1] = data
+		f.uploads[id] = parts
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, part))
+	case r.Method == http.MethodPost:
+		io.Copy(io.Discard, r.Body)
+		f.objects[key] = fakeObject{data: bytes.Join(f.uploads[id], nil), modTime: time.Now()}
+		delete(f.uploads, id)
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>"etag"</ETag></CompleteMultipartUploadResult>`,
+			f.bucket, key)
+	case r.Method == http.MethodDelete:
+		delete(f.uploads, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.error(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (f *fakeS3) store(key string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[key] = fakeObject{data: data, modTime: time.Now()}
+}
+
+func (f *fakeS3) error(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		fmt.Fprintf(w, `<Error><Code>%s</Code><Resource>%s</Resource></Error>`, code, r.URL.Path)
+	}
+}
+
+// readS3Body reads an upload body, decoding the aws-chunked encoding that
+// minio-go uses for streaming signatures over plain HTTP.
+func readS3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+	var data bytes.Buffer
+	br := bufio.NewReader(r.Body)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data.Bytes(), nil
+		}
+		if _, err := io.CopyN(&data, br, size); err != nil {
+			return nil, err
+		}
+		if _, err := br.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func newTestS3(t *testing.T, prefix string, expiry ExpiryPolicy) (*S3Storage, *fakeS3) {
+	t.Helper()
+	fake, endpoint := newFakeS3(t, "cache")
+	s, err := NewS3Storage(S3Config{
+		Endpoint:  endpoint,
+		Bucket:    "cache",
+		Region:    "us-east-1",
+		AccessKey: "access",
+		SecretKey: "secret",
+		PathStyle: true,
+		Prefix:    prefix,
+		Expiry:    expiry,
+	}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	return s, fake
+}
+
+func TestS3MissingBucket(t *testing.T) {
+	_, endpoint := newFakeS3(t, "cache")
+	_, err := NewS3Storage(S3Config{
+		Endpoint:  endpoint,
+		Bucket:    "other",
+		Region:    "us-east-1",
+		AccessKey: "access",
+		SecretKey: "secret",
+		PathStyle: true,
+	}, zerolog.Nop())
+	if err == nil {
+		t.Fatal("NewS3Storage succeeded for a missing bucket")
+	}
+}
+
+func TestS3RoundTrip(t *testing.T) {
+	s, fake := newTestS3(t, "", ExpiryPolicy{})
+	ctx := context.Background()
+
+	if _, err := get(t, s, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of missing key = %v, want ErrNotFound", err)
+	}
+	if ok, err := s.Exists(ctx, "missing"); ok || err != nil {
+		t.Fatalf("Exists of missing key = %v, %v", ok, err)
+	}
+
+	data := []byte("hello s3")
+	put(t, s, "key", data)
+	if got, err := get(t, s, "key"); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Get = %q, %v; want %q", got, err, data)
+	}
+	if ok, err := s.Exists(ctx, "key"); !ok || err != nil {
+		t.Fatalf("Exists = %v, %v; want true", ok, err)
+	}
+	if _, ok := fake.object("key"); !ok {
+		t.Fatalf("object stored under %v, want key", fake.keys())
+	}
+
+	if err := s.Delete(ctx, "key"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if ok, _ := s.Exists(ctx, "key"); ok {
+		t.Fatal("entry exists after Delete")
+	}
+	if err := s.Delete(ctx, "key"); err != nil {
+		t.Fatalf("Delete of missing key: %v", err)
+	}
+}
+
+func TestS3UnknownSize(t *testing.T) {
+	s, _ := newTestS3(t, "", ExpiryPolicy{})
+	if err := s.Put(context.Background(), "key", strings.NewReader("streamed"), -1); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got, err := get(t, s, "key"); err != nil || string(got) != "streamed" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+}
+
+func TestS3NamespacePrefix(t *testing.T) {
+	s, fake := newTestS3(t, "/builds/", ExpiryPolicy{})
+	ns := s.WithNamespace("exercise-1")
+
+	put(t, s, "key", []byte("default"))
+	put(t, ns, "key", []byte("namespaced"))
+
+	for _, key := range []string{"builds/key", "builds/exercise-1/key"} {
+		if _, ok := fake.object(key); !ok {
+			t.Fatalf("object %s missing, have %v", key, fake.keys())
+		}
+	}
+	if got, err := get(t, ns, "key"); err != nil || string(got) != "namespaced" {
+		t.Fatalf("namespaced Get = %q, %v", got, err)
+	}
+	if _, err := get(t, s.WithNamespace("exercise-2"), "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("other namespace Get = %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3Expiry(t *testing.T) {
+	s, fake := newTestS3(t, "", ExpiryPolicy{TTL: time.Hour})
+	put(t, s, "key", []byte("data"))
+	fake.setModTime("key", time.Now().Add(-2*time.Hour))
+
+	if _, err := get(t, s, "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of expired entry = %v, want ErrNotFound", err)
+	}
+	if _, ok := fake.object("key"); ok {
+		t.Fatal("expired object was not deleted")
+	}
+}
+
+func TestS3SlidingExpiry(t *testing.T) {
+	s, fake := newTestS3(t, "", ExpiryPolicy{TTL: time.Hour, Sliding: true})
+	put(t, s, "key", []byte("data"))
+	fake.setModTime("key", time.Now().Add(-45*time.Minute))
+
+	if ok, err := s.Exists(context.Background(), "key"); !ok || err != nil {
+		t.Fatalf("Exists = %v, %v", ok, err)
+	}
+	obj, ok := fake.object("key")
+	if !ok || time.Since(obj.modTime) > time.Minute {
+		t.Fatal("sliding hit did not refresh the object")
+	}
+	if string(obj.data) != "data" {
+		t.Fatalf("refresh changed the object to %q", obj.data)
+	}
+}
+
+func TestS3Scan(t *testing.T) {
+	s, fake := newTestS3(t, "builds", ExpiryPolicy{})
+	put(t, s, "plain", []byte("1234"))
+	put(t, s.WithNamespace("team"), "key", []byte("12"))
+	put(t, s.WithNamespace(dedupBlobNamespace), "blob", []byte("internal"))
+	fake.store("other/key", []byte("outside the prefix"))
+
+	var found []ScannedEntry
+	err := s.Scan(context.Background(), func(e ScannedEntry) error {
+		found = append(found, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Namespace < found[j].Namespace })
+	want := []ScannedEntry{{Key: "plain", Size: 4}, {Namespace: "team", Key: "key", Size: 2}}
+	if !reflect.DeepEqual(found, want) {
+		t.Fatalf("Scan found %v, want %v", found, want)
+	}
+}
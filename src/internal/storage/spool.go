@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+)
+
+// spoolMemoryLimit is the largest entry spooled in memory. Larger entries
+// go to a temporary file.
+const spoolMemoryLimit = 1 << 20
+
+// spooledEntry holds data that has to be read to the end before it can be
+// written to the backend, for example to know its SHA-256 or its size.
+// Data is kept in memory up to spoolMemoryLimit and in a temporary file
+// beyond that.
+type spooledEntry struct {
+	digest []byte
+	size   int64
+	buf    bytes.Buffer
+	file   *os.File
+}
+
+// spool reads reader to the end while hashing it.
+func spool(reader io.Reader, size int64) (*spooledEntry, error) {
+	e := &spooledEntry{}
+	if size >= 0 && size <= spoolMemoryLimit {
+		e.buf.Grow(int(size))
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(e, h), reader); err != nil {
+		e.Close()
+		return nil, fmt.Errorf("failed to spool entry: %w", err)
+	}
+	e.digest = h.Sum(nil)
+	return e, nil
+}
+
+func (e *spooledEntry) Write(p []byte) (int, error) {
+	if e.file == nil && int64(e.buf.Len()+len(p)) > spoolMemoryLimit {
+		f, err := os.CreateTemp("", "gradle-cache-spool-*")
+		if err != nil {
+			return 0, fmt.Errorf("failed to create spool file: %w", err)
+		}
+		e.file = f
+		if _, err := e.buf.WriteTo(f); err != nil {
+			return 0, err
+		}
+		e.buf = bytes.Buffer{}
+	}
+
+	var n int
+	var err error
+	if e.file != nil {
+		n, err = e.file.Write(p)
+	} else {
+		n, err = e.buf.Write(p)
+	}
+	e.size += int64(n)
+	return n, err
+}
+
+// Reader returns a reader over the spooled content from the start.
+func (e *spooledEntry) Reader() (io.Reader, error) {
+	if e.file == nil {
+		return bytes.NewReader(e.buf.Bytes()), nil
+	}
+	if _, err := e.file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind spool file: %w", err)
+	}
+	return e.file, nil
+}
+
+// Close removes the spool file, if any.
+func (e *spooledEntry) Close() error {
+	if e.file == nil {
+		return nil
+	}
+	e.file.Close()
+	return os.Remove(e.file.Name())
+}
@@ -3,26 +3,46 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"strings"
+	"time"
 )
 
 var (
-	ErrNotFound = errors.New("cache entry not found")
+	ErrNotFound      = errors.New("cache entry not found")
+	ErrQuotaExceeded = errors.New("namespace quota exceeded")
 )
 
+// ExpiryPolicy controls how long cache entries live. Every backend takes one
+// in its configuration and must honour it as described on Storage.
+type ExpiryPolicy struct {
+	// TTL is the lifetime of an entry after it was stored. Zero disables expiry.
+	TTL time.Duration
+	// Sliding restarts the lifetime whenever Get or Exists finds the entry.
+	Sliding bool
+}
+
 // Storage defines the interface for cache storage backends.
 // This abstraction allows for different implementations (MinIO, S3, filesystem, etc.)
+//
+// Backends must apply their ExpiryPolicy: an entry expires TTL after Put, or
+// after the last Get or Exists hit when the policy is sliding. Expired
+// entries must behave exactly like missing ones.
 type Storage interface {
 	// Get retrieves a cache entry by key.
 	// Returns the content reader, content size, and any error.
-	// Returns ErrNotFound if the entry does not exist.
+	// Returns ErrNotFound if the entry does not exist or has expired.
+	// Refreshes the entry's lifetime under a sliding expiry policy.
 	Get(ctx context.Context, key string) (io.ReadCloser, int64, error)
 
 	// Put stores a cache entry.
 	// The size parameter is the content length for the upload.
+	// The entry expires after the configured TTL.
 	Put(ctx context.Context, key string, reader io.Reader, size int64) error
 
-	// Exists checks if a cache entry exists.
+	// Exists checks if a cache entry exists and has not expired.
+	// Refreshes the entry's lifetime under a sliding expiry policy.
 	Exists(ctx context.Context, key string) (bool, error)
 
 	// Delete removes a cache entry.
@@ -33,10 +53,150 @@ type Storage interface {
 	Ping(ctx context.Context) error
 }
 
-// NamespacedStorage extends Storage with namespace support.
-// This will be used for per-exercise cache isolation in the future.
+// NamespacedStorage extends Storage with namespace support. Namespaces
+// isolate the entries of different exercises, teams or protocol instances
+// from each other; storage wrappers forward them to their backend.
 type NamespacedStorage interface {
 	Storage
 	// WithNamespace returns a new Storage instance scoped to the given namespace.
 	WithNamespace(namespace string) Storage
 }
+
+// Stater is implemented by storages that can describe an entry without
+// reading its content.
+type Stater interface {
+	// Stat returns ErrNotFound like Get, and refreshes the entry's lifetime
+	// under a sliding expiry policy like Exists.
+	Stat(ctx context.Context, key string) (EntryInfo, error)
+}
+
+// EntryInfo describes a stored entry.
+type EntryInfo struct {
+	// Size is the content size, or -1 if it is unknown.
+	Size int64
+	// Digest is the SHA-256 of the content, or nil if it is unknown.
+	Digest []byte
+	// ModTime is when the entry was stored, or last refreshed under a
+	// sliding expiry policy. It is zero if unknown.
+	ModTime time.Time
+}
+
+// Stat describes the entry at key. Storages that do not implement Stater
+// are asked through Get, and the content is closed unread.
+func Stat(ctx context.Context, s Storage, key string) (EntryInfo, error) {
+	if st, ok := s.(Stater); ok {
+		return st.Stat(ctx, key)
+	}
+	reader, size, err := s.Get(ctx, key)
+	if err != nil {
+		return EntryInfo{}, err
+	}
+	defer reader.Close()
+	info := EntryInfo{Size: size}
+	if d, ok := reader.(Digester); ok {
+		info.Digest = d.Digest()
+	}
+	return info, nil
+}
+
+// statHeader describes the entry at key in backend and reads up to n bytes
+// from the start of its content, for wrappers that keep a header in front
+// of the content they store. The header is shorter than n if the content is.
+func statHeader(ctx context.Context, backend Storage, key string, n int) (EntryInfo, []byte, error) {
+	info, err := Stat(ctx, backend, key)
+	if err != nil {
+		return EntryInfo{}, nil, err
+	}
+	reader, _, err := backend.Get(ctx, key)
+	if err != nil {
+		return EntryInfo{}, nil, err
+	}
+	defer reader.Close()
+
+	header := make([]byte, n)
+	read, err := io.ReadFull(reader, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return EntryInfo{}, nil, fmt.Errorf("failed to read entry header: %w", err)
+	}
+	return info, header[:read], nil
+}
+
+// Scanner is implemented by backends that can list the entries they hold.
+// The capacity manager uses it to account for entries it did not write
+// itself, such as those stored before a restart or by another replica.
+type Scanner interface {
+	// Scan calls fn for every entry in every namespace. Sizes are the
+	// stored sizes, which include the framing added by storage wrappers.
+	// Namespaces used internally by storage wrappers are skipped.
+	Scan(ctx context.Context, fn func(ScannedEntry) error) error
+}
+
+// ScannedEntry is an entry found by Scanner.Scan.
+type ScannedEntry struct {
+	Namespace string
+	Key       string
+	Size      int64
+}
+
+// internalNamespace reports whether namespace is used by a storage wrapper
+// for its own bookkeeping. Namespaces from requests never start with "_".
+func internalNamespace(namespace string) bool {
+	return strings.HasPrefix(namespace, "_")
+}
+
+type noRefreshKey struct{}
+
+// withoutRefresh marks ctx so that backends leave the lifetime of the entries
+// they find untouched, for reads the server makes on its own behalf rather
+// than for clients.
+func withoutRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRefreshKey{}, true)
+}
+
+// slides reports whether finding an entry restarts its lifetime, which it
+// does under a sliding policy unless ctx was marked by withoutRefresh.
+func (p ExpiryPolicy) slides(ctx context.Context) bool {
+	return p.Sliding && p.TTL > 0 && ctx.Value(noRefreshKey{}) == nil
+}
+
+// expired reports whether an entry last stored or refreshed at modTime has
+// outlived the policy's TTL.
+func (p ExpiryPolicy) expired(modTime time.Time) bool {
+	return p.TTL > 0 && time.Since(modTime) > p.TTL
+}
+
+// withNamespace scopes s to namespace if it supports namespaces and returns
+// it unchanged otherwise. Storage wrappers use it to forward WithNamespace.
+func withNamespace(s Storage, namespace string) Storage {
+	if ns, ok := s.(NamespacedStorage); ok {
+		return ns.WithNamespace(namespace)
+	}
+	return s
+}
+
+// backgroundLoop is a goroutine run by a storage until the storage is closed.
+type backgroundLoop struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startLoop runs loop in a new goroutine. Its context is cancelled by stop.
+func startLoop(loop func(ctx context.Context)) *backgroundLoop {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &backgroundLoop{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(l.done)
+		loop(ctx)
+	}()
+	return l
+}
+
+// stop cancels the loop and waits for it to return. It is safe to call on a
+// nil loop and more than once.
+func (l *backgroundLoop) stop() {
+	if l == nil {
+		return
+	}
+	l.cancel()
+	<-l.done
+}
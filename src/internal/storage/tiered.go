@@ -0,0 +1,312 @@
+package storage
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// TieredStorage keeps small, frequently used entries in a bounded in-process
+// LRU and falls back to the backing storage on a miss. Writes go through to
+// the backend, so the memory tier never holds data the backend lacks.
+//
+// Memory hits do not reach the backend and so do not refresh a sliding
+// expiry there. Memory entries therefore live at most TTL after they were
+// loaded, after which the next lookup goes to the backend again.
+type TieredStorage struct {
+	backend   Storage
+	namespace string
+	memory    *lruCache
+	cfg       TieredConfig
+	metrics   *tierMetrics
+}
+
+type TieredConfig struct {
+	// MaxBytes bounds the total size of entries held in memory.
+	MaxBytes int64
+	// MaxEntries bounds the number of entries held in memory.
+	MaxEntries int
+	// MaxEntrySize is the largest entry that is cached in memory.
+	MaxEntrySize int64
+	// TTL bounds how long an entry is served from memory. Zero keeps entries
+	// until they are evicted.
+	TTL time.Duration
+}
+
+func NewTieredStorage(backend Storage, cfg TieredConfig) (*TieredStorage, error) {
+	if cfg.MaxBytes <= 0 || cfg.MaxEntries <= 0 {
+		return nil, fmt.Errorf("memory tier requires positive byte and entry limits")
+	}
+	if cfg.MaxEntrySize <= 0 || cfg.MaxEntrySize > cfg.MaxBytes {
+		cfg.MaxEntrySize = cfg.MaxBytes
+	}
+	metrics, err := newTierMetrics()
+	if err != nil {
+		return nil, err
+	}
+	return &TieredStorage{
+		backend: backend,
+		memory:  newLRUCache(cfg.MaxBytes, cfg.MaxEntries, cfg.TTL),
+		cfg:     cfg,
+		metrics: metrics,
+	}, nil
+}
+
+func (s *TieredStorage) memoryKey(key string) string {
+	return s.namespace + "\x00" + key
+}
+
+func (s *TieredStorage) cacheable(size int64) bool {
+	return size >= 0 && size <= s.cfg.MaxEntrySize
+}
+
+func (s *TieredStorage) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	if entry, ok := s.memory.get(s.memoryKey(key)); ok {
+		s.metrics.hit(ctx, tierMemory, "get")
+		return &digestReadCloser{
+			ReadCloser: io.NopCloser(bytes.NewReader(entry.data)),
+			digest:     entry.digest,
+		}, int64(len(entry.data)), nil
+	}
+	s.metrics.miss(ctx, tierMemory, "get")
+
+	reader, size, err := s.backend.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			s.metrics.miss(ctx, tierBackend, "get")
+		}
+		return nil, 0, err
+	}
+	s.metrics.hit(ctx, tierBackend, "get")
+
+	if !s.cacheable(size) {
+		return reader, size, nil
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, size+1))
+	if errors.Is(err, ErrChecksumMismatch) || errors.Is(err, ErrDecryptionFailed) {
+		// The corrupted entry is gone from the backend now.
+		return nil, 0, ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read entry from backend: %w", err)
+	}
+	if int64(len(data)) != size {
+		return nil, 0, fmt.Errorf("backend returned %d bytes, expected %d", len(data), size)
+	}
+	var digest []byte
+	if d, ok := reader.(Digester); ok {
+		digest = d.Digest()
+	}
+	if digest == nil {
+		sum := sha256.Sum256(data)
+		digest = sum[:]
+	}
+	s.memory.add(s.memoryKey(key), data, digest)
+	return &digestReadCloser{ReadCloser: io.NopCloser(bytes.NewReader(data)), digest: digest}, size, nil
+}
+
+func (s *TieredStorage) Put(ctx context.Context, key string, reader io.Reader, size int64) error {
+	mk := s.memoryKey(key)
+	if !s.cacheable(size) {
+		s.memory.remove(mk)
+		return s.backend.Put(ctx, key, reader, size)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(int(size))
+	if err := s.backend.Put(ctx, key, io.TeeReader(reader, &buf), size); err != nil {
+		s.memory.remove(mk)
+		return err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	s.memory.add(mk, buf.Bytes(), sum[:])
+	return nil
+}
+
+func (s *TieredStorage) Exists(ctx context.Context, key string) (bool, error) {
+	if s.memory.contains(s.memoryKey(key)) {
+		s.metrics.hit(ctx, tierMemory, "exists")
+		return true, nil
+	}
+	s.metrics.miss(ctx, tierMemory, "exists")
+
+	exists, err := s.backend.Exists(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		s.metrics.hit(ctx, tierBackend, "exists")
+	} else {
+		s.metrics.miss(ctx, tierBackend, "exists")
+	}
+	return exists, nil
+}
+
+func (s *TieredStorage) Stat(ctx context.Context, key string) (EntryInfo, error) {
+	if entry, ok := s.memory.get(s.memoryKey(key)); ok {
+		s.metrics.hit(ctx, tierMemory, "exists")
+		return EntryInfo{Size: int64(len(entry.data)), Digest: entry.digest}, nil
+	}
+	s.metrics.miss(ctx, tierMemory, "exists")
+
+	info, err := Stat(ctx, s.backend, key)
+	if errors.Is(err, ErrNotFound) {
+		s.metrics.miss(ctx, tierBackend, "exists")
+	} else if err == nil {
+		s.metrics.hit(ctx, tierBackend, "exists")
+	}
+	return info, err
+}
+
+func (s *TieredStorage) Delete(ctx context.Context, key string) error {
+	s.memory.remove(s.memoryKey(key))
+	return s.backend.Delete(ctx, key)
+}
+
+func (s *TieredStorage) Ping(ctx context.Context) error {
+	return s.backend.Ping(ctx)
+}
+
+func (s *TieredStorage) WithNamespace(namespace string) Storage {
+	return &TieredStorage{
+		backend:   withNamespace(s.backend, namespace),
+		namespace: namespace,
+		memory:    s.memory,
+		cfg:       s.cfg,
+		metrics:   s.metrics,
+	}
+}
+
+// lruCache is a byte- and count-bounded LRU of immutable values.
+type lruCache struct {
+	mu         sync.Mutex
+	maxBytes   int64
+	maxEntries int
+	ttl        time.Duration
+	bytes      int64
+	order      *list.List
+	items      map[string]*list.Element
+}
+
+type lruEntry struct {
+	key    string
+	data   []byte
+	digest []byte
+	loaded time.Time
+}
+
+func newLRUCache(maxBytes int64, maxEntries int, ttl time.Duration) *lruCache {
+	return &lruCache{
+		maxBytes:   maxBytes,
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (c *lruCache) get(key string) (*lruEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if c.ttl > 0 && time.Since(entry.loaded) > c.ttl {
+		c.removeElement(elem)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry, true
+}
+
+func (c *lruCache) contains(key string) bool {
+	_, ok := c.get(key)
+	return ok
+}
+
+func (c *lruCache) add(key string, data, digest []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+	elem := c.order.PushFront(&lruEntry{key: key, data: data, digest: digest, loaded: time.Now()})
+	c.items[key] = elem
+	c.bytes += int64(len(data))
+
+	for c.bytes > c.maxBytes || len(c.items) > c.maxEntries {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *lruCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+func (c *lruCache) removeElement(elem *list.Element) {
+	entry := c.order.Remove(elem).(*lruEntry)
+	delete(c.items, entry.key)
+	c.bytes -= int64(len(entry.data))
+}
+
+const (
+	tierMemory  = "memory"
+	tierBackend = "backend"
+)
+
+type tierMetrics struct {
+	hits   metric.Int64Counter
+	misses metric.Int64Counter
+}
+
+func newTierMetrics() (*tierMetrics, error) {
+	meter := otel.Meter("gradle-cache")
+
+	hits, err := meter.Int64Counter(
+		"gradle_cache.tier_hits",
+		metric.WithDescription("Total number of lookups answered by a storage tier"))
+	if err != nil {
+		return nil, err
+	}
+
+	misses, err := meter.Int64Counter(
+		"gradle_cache.tier_misses",
+		metric.WithDescription("Total number of lookups a storage tier could not answer"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &tierMetrics{hits: hits, misses: misses}, nil
+}
+
+func (m *tierMetrics) hit(ctx context.Context, tier, op string) {
+	m.hits.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("tier", tier),
+		attribute.String("operation", op)))
+}
+
+func (m *tierMetrics) miss(ctx context.Context, tier, op string) {
+	m.misses.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("tier", tier),
+		attribute.String("operation", op)))
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+)
+
+// countingStorage counts the Get calls that reach the wrapped storage.
+type countingStorage struct {
+	Storage
+	gets *atomic.Int64
+}
+
+func (s countingStorage) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	s.gets.Add(1)
+	return s.Storage.Get(ctx, key)
+}
+
+func (s countingStorage) WithNamespace(namespace string) Storage {
+	return countingStorage{Storage: withNamespace(s.Storage, namespace), gets: s.gets}
+}
+
+func newTestTiered(t *testing.T, cfg TieredConfig) (*TieredStorage, *atomic.Int64) {
+	t.Helper()
+	gets := &atomic.Int64{}
+	s, err := NewTieredStorage(countingStorage{Storage: newTestFilesystem(t, ExpiryPolicy{}), gets: gets}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, gets
+}
+
+func TestTieredServesFromMemory(t *testing.T) {
+	s, gets := newTestTiered(t, TieredConfig{MaxBytes: 1024, MaxEntries: 10, MaxEntrySize: 64})
+	put(t, s, "key", []byte("small"))
+
+	for range 3 {
+		if got, err := get(t, s, "key"); err != nil || string(got) != "small" {
+			t.Fatalf("Get = %q, %v", got, err)
+		}
+	}
+	if n := gets.Load(); n != 0 {
+		t.Fatalf("backend saw %d gets, want 0", n)
+	}
+
+	r, _, err := s.Get(context.Background(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if d, ok := r.(Digester); !ok || len(d.Digest()) != 32 {
+		t.Fatal("memory hit does not carry a digest")
+	}
+}
+
+func TestTieredLargeEntriesBypassMemory(t *testing.T) {
+	s, gets := newTestTiered(t, TieredConfig{MaxBytes: 1024, MaxEntries: 10, MaxEntrySize: 4})
+	put(t, s, "key", []byte("too large"))
+
+	for range 2 {
+		if got, err := get(t, s, "key"); err != nil || string(got) != "too large" {
+			t.Fatalf("Get = %q, %v", got, err)
+		}
+	}
+	if n := gets.Load(); n != 2 {
+		t.Fatalf("backend saw %d gets, want 2", n)
+	}
+}
+
+func TestTieredLoadsOnMiss(t *testing.T) {
+	s, gets := newTestTiered(t, TieredConfig{MaxBytes: 1024, MaxEntries: 10})
+	put(t, s.backend, "key", []byte("backend only"))
+
+	for range 2 {
+		if got, err := get(t, s, "key"); err != nil || string(got) != "backend only" {
+			t.Fatalf("Get = %q, %v", got, err)
+		}
+	}
+	if n := gets.Load(); n != 1 {
+		t.Fatalf("backend saw %d gets, want 1", n)
+	}
+}
+
+func TestTieredDeleteAndNamespaces(t *testing.T) {
+	s, _ := newTestTiered(t, TieredConfig{MaxBytes: 1024, MaxEntries: 10})
+	a := s.WithNamespace("a")
+	put(t, a, "key", []byte("from a"))
+
+	if _, err := get(t, s.WithNamespace("b"), "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("namespace b sees entry of a: %v", err)
+	}
+	if err := a.Delete(context.Background(), "key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(t, a, "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestTieredEvictsLeastRecentlyUsed(t *testing.T) {
+	s, gets := newTestTiered(t, TieredConfig{MaxBytes: 1024, MaxEntries: 2})
+	put(t, s, "a", []byte("a"))
+	put(t, s, "b", []byte("b"))
+	get(t, s, "a")
+	put(t, s, "c", []byte("c"))
+
+	get(t, s, "a")
+	get(t, s, "c")
+	if n := gets.Load(); n != 0 {
+		t.Fatalf("recently used entries were evicted, backend saw %d gets", n)
+	}
+	if got, err := get(t, s, "b"); err != nil || !bytes.Equal(got, []byte("b")) {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+	if n := gets.Load(); n != 1 {
+		t.Fatalf("backend saw %d gets, want 1 for the evicted entry", n)
+	}
+}